@@ -0,0 +1,47 @@
+#!/bin/sh
+# Prints a price table for the given regions, from the AWS Pricing API.
+# Needs the aws cli and jq. Save it to ~/.docker-remote/prices.json to refresh
+# the prices used by docker-remote, or paste it in pkg/pricing/prices.go.
+#
+#   bin/update_prices.sh us-east-1 ca-central-1 > ~/.docker-remote/prices.json
+
+set -e
+
+products() {
+  aws pricing get-products \
+    --region us-east-1 \
+    --service-code AmazonEC2 \
+    --output json \
+    "$@" \
+    | jq -c '.PriceList[] | fromjson'
+}
+
+echo '{"regions": {'
+
+separator=""
+
+for region in "$@"; do
+  instances=$(products --filters \
+    "Type=TERM_MATCH,Field=regionCode,Value=${region}" \
+    "Type=TERM_MATCH,Field=operatingSystem,Value=Linux" \
+    "Type=TERM_MATCH,Field=tenancy,Value=Shared" \
+    "Type=TERM_MATCH,Field=preInstalledSw,Value=NA" \
+    "Type=TERM_MATCH,Field=capacitystatus,Value=Used" \
+    | jq -s 'map({
+        key: .product.attributes.instanceType,
+        value: ([.terms.OnDemand[].priceDimensions[].pricePerUnit.USD | tonumber] | first)
+      }) | from_entries')
+
+  volumes=$(products --filters \
+    "Type=TERM_MATCH,Field=regionCode,Value=${region}" \
+    "Type=TERM_MATCH,Field=productFamily,Value=Storage" \
+    | jq -s 'map(select(.product.attributes.volumeApiName != null) | {
+        key: .product.attributes.volumeApiName,
+        value: ([.terms.OnDemand[].priceDimensions[].pricePerUnit.USD | tonumber] | first)
+      }) | from_entries')
+
+  echo "${separator}\"${region}\": {\"instances\": ${instances}, \"volumes\": ${volumes}}"
+  separator=","
+done
+
+echo '}}'
@@ -0,0 +1,11 @@
+package cmd
+
+import (
+	"github.com/knlambert/docker-remote.git/pkg/host"
+	"github.com/spf13/cobra"
+)
+
+func createAttachCmd(requestedDriver string) *cobra.Command {
+	impl := host.BuildHostImplementation(requestedDriver)
+	return impl.CobraCommand(host.Attach)
+}
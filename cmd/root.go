@@ -2,6 +2,13 @@ package cmd
 
 import (
 	"fmt"
+	"github.com/knlambert/docker-remote.git/pkg/host/aws"
+	"github.com/knlambert/docker-remote.git/pkg/host/digitalocean"
+	"github.com/knlambert/docker-remote.git/pkg/host/gcp"
+	"github.com/knlambert/docker-remote.git/pkg/host/openstack"
+	"github.com/knlambert/docker-remote.git/pkg/host/proxmox"
+	"github.com/knlambert/docker-remote.git/pkg/output"
+	"github.com/knlambert/docker-remote.git/pkg/plugin"
 	"github.com/spf13/cobra"
 	"log"
 )
@@ -14,8 +21,26 @@ var rootCmd = &cobra.Command{
 
 
 func Execute() {
+	rootCmd.PersistentFlags().StringP(
+		output.FlagName, "o", string(output.Text), "The output format (text, json, yaml)",
+	)
 
-	for _, requestedDriver := range []string{"ec2"} {
+	aws.AddFlags(rootCmd.PersistentFlags())
+	gcp.AddFlags(rootCmd.PersistentFlags())
+	digitalocean.AddFlags(rootCmd.PersistentFlags())
+	openstack.AddFlags(rootCmd.PersistentFlags())
+	proxmox.AddFlags(rootCmd.PersistentFlags())
+
+	drivers := []string{"digitalocean", "ec2", "gce", "openstack", "proxmox", "ssh"}
+
+	//Built in drivers win over plugins with the same name.
+	for _, driverPlugin := range plugin.Discover() {
+		if !containsString(drivers, driverPlugin.Name) {
+			drivers = append(drivers, driverPlugin.Name)
+		}
+	}
+
+	for _, requestedDriver := range drivers {
 		driverCmd := cobra.Command{
 			Use:   requestedDriver,
 			Short: fmt.Sprintf("%s implementation", requestedDriver),
@@ -25,14 +50,39 @@ func Execute() {
 
 		rootCmd.AddCommand(&driverCmd)
 
-		driverCmd.AddCommand(createUpCmd(requestedDriver))
-		driverCmd.AddCommand(createDownCmd(requestedDriver))
-		driverCmd.AddCommand(createShellCmd(requestedDriver))
-		driverCmd.AddCommand(createPortForwardCmd(requestedDriver))
-
+		//Commands a driver does not support are not created.
+		for _, createCmd := range []func(string) *cobra.Command{
+			createUpCmd,
+			createAdoptCmd,
+			createAttachCmd,
+			createCostCmd,
+			createDownCmd,
+			createEcrLoginCmd,
+			createExtendCmd,
+			createListCmd,
+			createShellCmd,
+			createPortForwardCmd,
+			createReapCmd,
+			createRegistrySyncCmd,
+			createSSMProxyCmd,
+			createStatusCmd,
+		} {
+			if subCmd := createCmd(requestedDriver); subCmd != nil {
+				driverCmd.AddCommand(subCmd)
+			}
+		}
 	}
 
 	if err := rootCmd.Execute(); err != nil {
 		log.Fatal(err)
 	}
 }
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
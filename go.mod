@@ -8,7 +8,9 @@ require (
 	github.com/golang/mock v1.4.4
 	github.com/pkg/errors v0.9.1
 	github.com/spf13/cobra v1.1.1
+	github.com/spf13/pflag v1.0.5
 	github.com/stretchr/testify v1.3.0
 	golang.org/x/crypto v0.0.0-20190605123033-f99c8df09eb5
+	gopkg.in/yaml.v2 v2.4.0
 	rsc.io/quote/v3 v3.1.0 // indirect
 )
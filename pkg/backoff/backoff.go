@@ -0,0 +1,53 @@
+package backoff
+
+import (
+	"math/rand"
+	"time"
+)
+
+//Exponential backoff between two attempts of an operation.
+type Backoff struct {
+	//The first delay returned.
+	Initial time.Duration
+	//The maximum delay returned.
+	Max time.Duration
+	//The multiplier applied to the delay after each attempt.
+	Factor float64
+	//The fraction of the delay randomly removed, so that concurrent clients do not retry together.
+	Jitter float64
+
+	current time.Duration
+}
+
+//Creates a backoff starting at initial and capped to max.
+func CreateBackoff(initial time.Duration, max time.Duration) *Backoff {
+	return &Backoff{
+		Initial: initial,
+		Max:     max,
+		Factor:  2,
+	}
+}
+
+//Returns the delay to wait before the next attempt.
+func (b *Backoff) Next() time.Duration {
+	if b.current == 0 {
+		b.current = b.Initial
+	} else {
+		b.current = time.Duration(float64(b.current) * b.Factor)
+	}
+
+	if b.current > b.Max {
+		b.current = b.Max
+	}
+
+	if b.Jitter > 0 {
+		return b.current - time.Duration(b.Jitter*rand.Float64()*float64(b.current))
+	}
+
+	return b.current
+}
+
+//Restarts the sequence from the initial delay.
+func (b *Backoff) Reset() {
+	b.current = 0
+}
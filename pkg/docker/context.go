@@ -11,22 +11,15 @@ import (
 func (d *dockerImpl) ContextSet(
 	name string,
 	dockerHost string,
+	metadata map[string]string,
 ) error {
-	dockerConfigPath, err := d.dockerConfigFolderPath()
+	folderPath, err := d.contextFolderPath(name)
 
 	if err != nil {
-		return errors.Wrap(err, "failed to get docker config path")
-	}
-
-	contextFolderName, err := d.contextFolderHashedName(name)
-
-	if err != nil {
-		return errors.Wrap(err, "failed to generate context folder name")
+		return err
 	}
 
-	contextFolderPath := filepath.Join(
-		*dockerConfigPath, "contexts", "meta", *contextFolderName,
-	)
+	contextFolderPath := *folderPath
 	metaFilePath := filepath.Join(contextFolderPath, "meta.json")
 
 	if contextFolderExists, err := d.os.PathExists(contextFolderPath); err != nil {
@@ -37,7 +30,7 @@ func (d *dockerImpl) ContextSet(
 		}
 	}
 
-	context := d.createContextMeta(name, dockerHost)
+	context := d.createContextMeta(name, dockerHost, metadata)
 	serialized, err := context.JSON()
 
 	if err != nil {
@@ -53,6 +46,42 @@ func (d *dockerImpl) ContextSet(
 	return nil
 }
 
+//Removes the docker context of a host, if it exists.
+func (d *dockerImpl) ContextRemove(name string) error {
+	contextFolderPath, err := d.contextFolderPath(name)
+
+	if err != nil {
+		return err
+	}
+
+	if err := d.os.RemoveAll(*contextFolderPath); err != nil {
+		return errors.Wrapf(err, "failed to remove context folder %s", *contextFolderPath)
+	}
+
+	return nil
+}
+
+//Returns the path of the folder storing a context metadata.
+func (d *dockerImpl) contextFolderPath(name string) (*string, error) {
+	dockerConfigPath, err := d.dockerConfigFolderPath()
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get docker config path")
+	}
+
+	contextFolderName, err := d.contextFolderHashedName(name)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate context folder name")
+	}
+
+	contextFolderPath := filepath.Join(
+		*dockerConfigPath, "contexts", "meta", *contextFolderName,
+	)
+
+	return &contextFolderPath, nil
+}
+
 //Gets the hashed value for a given context name.
 func (d *dockerImpl) contextFolderHashedName(name string) (*string, error) {
 	h := sha256.New()
@@ -59,7 +59,7 @@ func TestContextSetFromNothing(t *testing.T) {
 
 	runtimeMock.EXPECT().CurrentOS().Return("linux")
 
-	context := s.createContextMeta(expectedContextName, expectedDockerHost)
+	context := s.createContextMeta(expectedContextName, expectedDockerHost, map[string]string{"Architecture": "arm64"})
 	serialized, _ := context.JSON()
 
 	osMock.EXPECT().PathExists(expectedContextFolderPath).Return(false, nil)
@@ -67,7 +67,7 @@ func TestContextSetFromNothing(t *testing.T) {
 	ioMock.EXPECT().WriteFile(expectedContextMetadataFilePath, serialized, os.FileMode(0644))
 
 	//Assertions
-	err := s.ContextSet("remote-1", expectedDockerHost)
+	err := s.ContextSet("remote-1", expectedDockerHost, map[string]string{"Architecture": "arm64"})
 	assert.Nil(t, err)
 
 	ctrl.Finish()
@@ -85,7 +85,7 @@ func TestContextSetFailWithUnknownOS(t *testing.T) {
 	runtimeMock.EXPECT().CurrentOS().Return("unknown")
 
 	//Assertions
-	err := s.ContextSet("remote-1", "127.0.0.1")
+	err := s.ContextSet("remote-1", "127.0.0.1", nil)
 
 	assert.Errorf(t, err, "ContextSet should return an error on unknown")
 	assert.Equal(
@@ -0,0 +1,173 @@
+package docker
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+//The credentials of a registry, as stored in the auths of config.json.
+type RegistryAuth struct {
+	//base64("username:password").
+	Auth string `json:"auth"`
+}
+
+//The credentials related parts of config.json.
+type credentialsConfig struct {
+	Auths       map[string]RegistryAuth `json:"auths"`
+	CredsStore  string                  `json:"credsStore"`
+	CredHelpers map[string]string       `json:"credHelpers"`
+}
+
+//Returns the local credentials of the registries (all the known ones when none is given),
+//resolved through the credential helpers when config.json delegates them.
+func (d *dockerImpl) RegistryAuths(registries []string) (map[string]RegistryAuth, error) {
+	folderPath, err := d.dockerConfigFolderPath()
+
+	if err != nil {
+		return nil, err
+	}
+
+	configPath := filepath.Join(*folderPath, "config.json")
+	config := credentialsConfig{}
+
+	if exists, err := d.os.PathExists(configPath); err != nil {
+		return nil, errors.Wrapf(err, "failed to check %s path existence", configPath)
+	} else if exists {
+		content, err := d.io.ReadFile(configPath)
+
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s", configPath)
+		}
+
+		if err := json.Unmarshal(content, &config); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse %s", configPath)
+		}
+	}
+
+	helpers := map[string]string{}
+
+	if config.CredsStore != "" {
+		listed, err := d.credentialHelper(config.CredsStore, "list", nil)
+
+		if err != nil {
+			return nil, err
+		}
+
+		stored := map[string]string{}
+
+		if err := json.Unmarshal(listed, &stored); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse the registries of docker-credential-%s", config.CredsStore)
+		}
+
+		for registry := range stored {
+			if !desktopToken(registry) {
+				helpers[registry] = config.CredsStore
+			}
+		}
+	}
+
+	for registry, helper := range config.CredHelpers {
+		helpers[registry] = helper
+	}
+
+	wanted := func(registry string) bool {
+		if len(registries) == 0 {
+			return true
+		}
+
+		for _, name := range registries {
+			if normalizeRegistry(name) == normalizeRegistry(registry) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	auths := map[string]RegistryAuth{}
+
+	for registry, auth := range config.Auths {
+		if auth.Auth != "" && wanted(registry) {
+			auths[registry] = auth
+		}
+	}
+
+	var delegated []string
+
+	for registry := range helpers {
+		if wanted(registry) {
+			delegated = append(delegated, registry)
+		}
+	}
+
+	sort.Strings(delegated)
+
+	for _, registry := range delegated {
+		got, err := d.credentialHelper(helpers[registry], "get", []byte(registry))
+
+		if err != nil {
+			return nil, err
+		}
+
+		var credentials struct {
+			Username string
+			Secret   string
+		}
+
+		if err := json.Unmarshal(got, &credentials); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse the credentials of %s", registry)
+		}
+
+		auths[registry] = RegistryAuth{
+			Auth: base64.StdEncoding.EncodeToString([]byte(credentials.Username + ":" + credentials.Secret)),
+		}
+	}
+
+	for _, name := range registries {
+		found := false
+
+		for registry := range auths {
+			found = found || normalizeRegistry(registry) == normalizeRegistry(name)
+		}
+
+		if !found {
+			return nil, errors.Errorf("no local credentials for registry %s", name)
+		}
+	}
+
+	return auths, nil
+}
+
+//Runs a docker credential helper, as the docker command line does.
+func execCredentialHelper(helper string, action string, input []byte) ([]byte, error) {
+	cmd := exec.Command(fmt.Sprintf("docker-credential-%s", helper), action)
+	cmd.Stdin = bytes.NewReader(input)
+
+	out, err := cmd.Output()
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "docker-credential-%s %s failed", helper, action)
+	}
+
+	return out, nil
+}
+
+//Docker Desktop keeps the tokens of its own session in the credentials store, next to the
+//registries, as "https://index.docker.io/v1/access-token" and ".../refresh-token".
+func desktopToken(registry string) bool {
+	return strings.HasSuffix(registry, "/access-token") || strings.HasSuffix(registry, "/refresh-token")
+}
+
+//Strips the scheme and path of a registry, "https://index.docker.io/v1/" being "index.docker.io".
+func normalizeRegistry(registry string) string {
+	registry = strings.TrimPrefix(strings.TrimPrefix(registry, "https://"), "http://")
+	return strings.SplitN(registry, "/", 2)[0]
+}
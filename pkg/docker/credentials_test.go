@@ -0,0 +1,89 @@
+package docker
+
+import (
+	"os/user"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistryAuthsResolvesHelpers(t *testing.T) {
+	// Tear up.
+	ctrl := gomock.NewController(t)
+	s, ioMock, osMock, runtimeMock, userMock := stubbedDocker(ctrl)
+
+	expectedConfigPath := filepath.Join(expectedHomePath, ".docker", "config.json")
+
+	userMock.EXPECT().Current().Return(&user.User{HomeDir: expectedHomePath}, nil)
+	runtimeMock.EXPECT().CurrentOS().Return("linux")
+	osMock.EXPECT().PathExists(expectedConfigPath).Return(true, nil)
+	ioMock.EXPECT().ReadFile(expectedConfigPath).Return([]byte(`{
+		"auths": {"registry.local": {"auth": "YmFybmV5OnNlY3JldA=="}, "ghcr.io": {}},
+		"credsStore": "desktop",
+		"credHelpers": {"artifactory.corp": "vault"}
+	}`), nil)
+
+	s.credentialHelper = func(helper string, action string, input []byte) ([]byte, error) {
+		switch helper + " " + action + " " + string(input) {
+		case "desktop list ":
+			return []byte(`{"ghcr.io": "barney", "https://index.docker.io/v1/": "barney"}`), nil
+		case "desktop get ghcr.io":
+			return []byte(`{"ServerURL": "ghcr.io", "Username": "barney", "Secret": "ghp_token"}`), nil
+		case "vault get artifactory.corp":
+			return []byte(`{"ServerURL": "artifactory.corp", "Username": "ci", "Secret": "key"}`), nil
+		}
+		return nil, errors.Errorf("unexpected call %s %s %s", helper, action, input)
+	}
+
+	//Assertions
+	auths, err := s.RegistryAuths([]string{"registry.local", "ghcr.io", "https://artifactory.corp/"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]RegistryAuth{
+		"registry.local":   {Auth: "YmFybmV5OnNlY3JldA=="},
+		"ghcr.io":          {Auth: "YmFybmV5OmdocF90b2tlbg=="},
+		"artifactory.corp": {Auth: "Y2k6a2V5"},
+	}, auths)
+
+	ctrl.Finish()
+}
+
+func TestRegistryAuthsSkipsDockerDesktopTokens(t *testing.T) {
+	// Tear up.
+	ctrl := gomock.NewController(t)
+	s, ioMock, osMock, runtimeMock, userMock := stubbedDocker(ctrl)
+
+	expectedConfigPath := filepath.Join(expectedHomePath, ".docker", "config.json")
+
+	userMock.EXPECT().Current().Return(&user.User{HomeDir: expectedHomePath}, nil)
+	runtimeMock.EXPECT().CurrentOS().Return("linux")
+	osMock.EXPECT().PathExists(expectedConfigPath).Return(true, nil)
+	ioMock.EXPECT().ReadFile(expectedConfigPath).Return([]byte(`{"credsStore": "desktop"}`), nil)
+
+	s.credentialHelper = func(helper string, action string, input []byte) ([]byte, error) {
+		switch helper + " " + action + " " + string(input) {
+		case "desktop list ":
+			return []byte(`{
+				"https://index.docker.io/v1/": "barney",
+				"https://index.docker.io/v1/access-token": "barney",
+				"https://index.docker.io/v1/refresh-token": "barney"
+			}`), nil
+		case "desktop get https://index.docker.io/v1/":
+			return []byte(`{"Username": "barney", "Secret": "dckr_pat"}`), nil
+		}
+		return nil, errors.Errorf("unexpected call %s %s %s", helper, action, input)
+	}
+
+	//Assertions
+	auths, err := s.RegistryAuths(nil)
+
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]RegistryAuth{
+		"https://index.docker.io/v1/": {Auth: "YmFybmV5OmRja3JfcGF0"},
+	}, auths)
+
+	ctrl.Finish()
+}
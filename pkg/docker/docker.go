@@ -12,11 +12,16 @@ import (
 )
 
 type Docker interface {
-	//Sets a docker context for a specific host.
+	//Sets a docker context for a specific host, with metadata tools can read (docker context inspect).
 	ContextSet(
 		name string,
 		dockerHost string,
+		metadata map[string]string,
 	) error
+	//Removes the docker context of a host, if it exists.
+	ContextRemove(name string) error
+	//Returns the local credentials of the registries, all the known ones when none is given.
+	RegistryAuths(registries []string) (map[string]RegistryAuth, error)
 }
 
 func CreateDocker() Docker {
@@ -25,6 +30,7 @@ func CreateDocker() Docker {
 		os:      os.CreateOS(),
 		runtime: runtime.CreateRuntime(),
 		user:    user.CreateUser(),
+		credentialHelper: execCredentialHelper,
 	}
 }
 
@@ -33,6 +39,8 @@ type dockerImpl struct {
 	os      os.OS
 	runtime runtime.Runtime
 	user    user.User
+	//Runs a docker-credential-<helper> program with an action and its input.
+	credentialHelper func(helper string, action string, input []byte) ([]byte, error)
 }
 
 //Returns the path to the user's docker config folder.
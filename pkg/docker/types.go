@@ -8,10 +8,16 @@ import (
 func (d *dockerImpl) createContextMeta(
 	name string,
 	host string,
+	metadata map[string]string,
 ) ContextMeta {
+	//Docker expects an object.
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+
 	return ContextMeta{
 		Name:     name,
-		Metadata: struct{}{},
+		Metadata: metadata,
 		Endpoints: ContextEndpoints{
 			Docker: ContextEndpoint{
 				Host:          host,
@@ -22,9 +28,9 @@ func (d *dockerImpl) createContextMeta(
 }
 
 type ContextMeta struct {
-	Name      string           `json:"Name"`
-	Metadata  struct{}         `json:"Metadata"`
-	Endpoints ContextEndpoints `json:"Endpoints"`
+	Name      string            `json:"Name"`
+	Metadata  map[string]string `json:"Metadata"`
+	Endpoints ContextEndpoints  `json:"Endpoints"`
 }
 
 func (c *ContextMeta) JSON() ([]byte, error){
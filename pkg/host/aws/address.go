@@ -0,0 +1,150 @@
+package aws
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/pkg/errors"
+)
+
+//An Elastic IP.
+type AddressDescription struct {
+	AllocationId string
+	PublicIp     string
+	//Empty when the address is not associated.
+	AssociationId string
+	InstanceId    string
+}
+
+//Allocates a VPC Elastic IP and tags it.
+func (a *awsImpl) AddressAllocate(tags map[string]string) (*AddressDescription, error) {
+	c, err := a.factory.EC2()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var res *ec2.AllocateAddressOutput
+
+	err = a.retry(func() (err error) {
+		res, err = c.AllocateAddress(&ec2.AllocateAddressInput{
+			Domain: aws.String(ec2.DomainTypeVpc),
+		})
+		return err
+	})
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to allocate an elastic IP")
+	}
+
+	address := AddressDescription{
+		AllocationId: aws.StringValue(res.AllocationId),
+		PublicIp:     aws.StringValue(res.PublicIp),
+	}
+
+	if err := a.retry(func() error {
+		_, err := c.CreateTags(&ec2.CreateTagsInput{
+			Resources: []*string{res.AllocationId},
+			Tags:      mapToTags(tags),
+		})
+		return err
+	}); err != nil {
+		_ = a.AddressRelease(address.AllocationId)
+		return nil, errors.Wrapf(err, "failed to tag elastic IP %s", address.PublicIp)
+	}
+
+	return &address, nil
+}
+
+//Associates an Elastic IP with an instance, replacing its public IP.
+func (a *awsImpl) AddressAssociate(allocationId string, instanceId string) error {
+	c, err := a.factory.EC2()
+
+	if err != nil {
+		return err
+	}
+
+	if err := a.retry(func() error {
+		_, err := c.AssociateAddress(&ec2.AssociateAddressInput{
+			AllocationId: aws.String(allocationId),
+			InstanceId:   aws.String(instanceId),
+		})
+		return err
+	}); err != nil {
+		return errors.Wrapf(err, "failed to associate elastic IP %s with %s", allocationId, instanceId)
+	}
+
+	return nil
+}
+
+//Returns the first Elastic IP matching the tags, nil if there is none.
+func (a *awsImpl) AddressDescribe(tags map[string]string) (*AddressDescription, error) {
+	c, err := a.factory.EC2()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var res *ec2.DescribeAddressesOutput
+
+	err = a.retry(func() (err error) {
+		res, err = c.DescribeAddresses(&ec2.DescribeAddressesInput{
+			Filters: mapToTagFilter(tags),
+		})
+		return err
+	})
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to describe elastic IPs")
+	}
+
+	if len(res.Addresses) == 0 {
+		return nil, nil
+	}
+
+	return &AddressDescription{
+		AllocationId:  aws.StringValue(res.Addresses[0].AllocationId),
+		PublicIp:      aws.StringValue(res.Addresses[0].PublicIp),
+		AssociationId: aws.StringValue(res.Addresses[0].AssociationId),
+		InstanceId:    aws.StringValue(res.Addresses[0].InstanceId),
+	}, nil
+}
+
+//Disassociates an Elastic IP from its instance.
+func (a *awsImpl) AddressDisassociate(associationId string) error {
+	c, err := a.factory.EC2()
+
+	if err != nil {
+		return err
+	}
+
+	if err := a.retry(func() error {
+		_, err := c.DisassociateAddress(&ec2.DisassociateAddressInput{
+			AssociationId: aws.String(associationId),
+		})
+		return err
+	}); err != nil {
+		return errors.Wrapf(err, "failed to disassociate elastic IP %s", associationId)
+	}
+
+	return nil
+}
+
+//Releases an Elastic IP, which must not be associated anymore.
+func (a *awsImpl) AddressRelease(allocationId string) error {
+	c, err := a.factory.EC2()
+
+	if err != nil {
+		return err
+	}
+
+	if err := a.retry(func() error {
+		_, err := c.ReleaseAddress(&ec2.ReleaseAddressInput{
+			AllocationId: aws.String(allocationId),
+		})
+		return err
+	}); err != nil {
+		return errors.Wrapf(err, "failed to release elastic IP %s", allocationId)
+	}
+
+	return nil
+}
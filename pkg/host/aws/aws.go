@@ -4,124 +4,375 @@ import (
 	"encoding/base64"
 	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/pkg/errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
 )
 
-var initScript string = `#!/bin/bash
-sudo yum update -y
-sudo amazon-linux-extras install docker -y
-sudo yum install docker -y
-sudo service docker start
-sudo usermod -a -G docker ec2-user
-`
-
 type AWS interface {
-	InstanceCreate(
-		ami string,
-		instanceType string,
-		keyName string,
-		securityGroup string,
-		tags map[string]string,
-	) (*string, error)
+	AddressAllocate(tags map[string]string) (*AddressDescription, error)
+	AddressAssociate(allocationId string, instanceId string) error
+	//Returns the first Elastic IP matching the tags, nil if there is none.
+	AddressDescribe(tags map[string]string) (*AddressDescription, error)
+	AddressDisassociate(associationId string) error
+	AddressRelease(allocationId string) error
+	//Returns the ARN of the identity the AWS calls are made with.
+	CallerIdentity() (string, error)
+	//Creates an instance with the first instance type and subnet combination having capacity.
+	InstanceCreate(params *InstanceCreateParams) (*InstanceCreated, error)
+	//Returns the ID of the latest Amazon Linux 2 AMI of an architecture (x86_64 or arm64).
+	ImageLatest(architecture string) (string, error)
 	InstanceDescribe(
 		tags map[string]string,
 		states []string,
 	) (*InstanceDescription, error)
+	//Creates an instance profile and its role, or completes them when a part is missing.
+	InstanceProfileEnsure(name string, policyARNs []string) (bool, error)
+	//Returns an instance by its ID, nil if it does not exist.
+	InstanceGet(instanceId string) (*InstanceDescription, error)
 	InstanceIsReady(instanceId string) (bool, error)
+	InstanceList(
+		tags map[string]string,
+		states []string,
+	) ([]*InstanceDescription, error)
+	InstanceStart(instanceId string) error
+	InstanceStatusChecksPassed(instanceId string) (bool, error)
+	InstanceTag(instanceId string, tags map[string]string) error
 	InstanceTerminate(instanceId string) error
+	InstanceVolumes(instanceId string) ([]*VolumeDescription, error)
+	//Returns the docker credentials of ECR registries, the caller's account one when none is given.
+	RegistryAuthorizations(registryIds []string) ([]*RegistryAuthorization, error)
+	//Records the region of the session, used by the next commands when none is given.
+	RegionRecord() error
+	//Opens an SSM session streaming the SSH port of an instance.
+	SessionStart(instanceId string, port int) (*SessionDescription, error)
+	SessionTerminate(sessionId string) error
+	SpotPrice(instanceType string, availabilityZone string) (*float64, error)
 }
 
 func Create() AWS {
+	return CreateFromConfig(&CommandLineConfig)
+}
+
+//Returns the AWS calls made with a session configuration rather than the command line one,
+//an emulator endpoint for instance.
+func CreateFromConfig(config *SessionConfig) AWS {
 	return &awsImpl{
-		CreateFactory(),
+		factory: CreateFactory(config),
+		sleep:   time.Sleep,
 	}
 }
 
 type awsImpl struct {
 	factory Factory
+	//Waits between two attempts of a call.
+	sleep func(d time.Duration)
+}
+
+type InstanceCreateParams struct {
+	AMI string
+	//The instance types to try, in order of preference.
+	InstanceTypes []string
+	KeyName       string
+	SecurityGroup string
+	UserData      string
+	Tags          map[string]string
+	//What a shutdown from the instance does: "stop" or "terminate", EC2's default when empty.
+	ShutdownBehavior string
+	//The subnets to try for each instance type, in order, the default VPC's when empty.
+	SubnetIds []string
+	//Launches the instance without a public IP.
+	Private bool
+	//The name of the instance profile given to the instance.
+	IamInstanceProfile string
+	//Tags given to the volumes and network interfaces of the instance.
+	ResourceTags map[string]string
+}
+
+//The instance created, with the instance type and subnet combination that had capacity.
+type InstanceCreated struct {
+	Id           string
+	InstanceType string
+	//Empty in the default VPC.
+	SubnetId string
 }
 
-func (a *awsImpl) InstanceCreate(
-	ami string,
-	instanceType string,
-	keyName string,
-	securityGroup string,
-	tags map[string]string,
-) (*string, error) {
+//The errors of RunInstances worth trying the next instance type and subnet combination for.
+var capacityErrorCodes = []string{"InsufficientInstanceCapacity", "Unsupported"}
+
+func (a *awsImpl) InstanceCreate(params *InstanceCreateParams) (*InstanceCreated, error) {
 	c, err := a.factory.EC2()
 
 	if err != nil {
 		return nil, err
 	}
 
-	res, err := c.RunInstances(&ec2.RunInstancesInput{
-		ImageId:          aws.String(ami),
-		InstanceType:     aws.String(instanceType),
+	input := ec2.RunInstancesInput{
+		ImageId:          aws.String(params.AMI),
 		MaxCount:         aws.Int64(1),
 		MinCount:         aws.Int64(1),
-		UserData:         aws.String(base64.StdEncoding.EncodeToString([]byte(initScript))),
-		SecurityGroupIds: []*string{aws.String(securityGroup)},
-		KeyName:          aws.String(keyName),
+		UserData:         aws.String(base64.StdEncoding.EncodeToString([]byte(params.UserData))),
+		SecurityGroupIds: []*string{aws.String(params.SecurityGroup)},
+		KeyName:          aws.String(params.KeyName),
 		TagSpecifications: []*ec2.TagSpecification{{
-			ResourceType: aws.String("instance"),
-			Tags:         mapToTags(tags),
+			ResourceType: aws.String(ec2.ResourceTypeInstance),
+			Tags:         mapToTags(params.Tags),
 		}},
-	})
+	}
 
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to kick a VM in EC2")
+	if len(params.ResourceTags) > 0 {
+		for _, resourceType := range []string{ec2.ResourceTypeVolume, ec2.ResourceTypeNetworkInterface} {
+			input.TagSpecifications = append(input.TagSpecifications, &ec2.TagSpecification{
+				ResourceType: aws.String(resourceType),
+				Tags:         mapToTags(params.ResourceTags),
+			})
+		}
+	}
+
+	if len(params.SubnetIds) > 0 || params.Private {
+		input.SecurityGroupIds = nil
+		input.NetworkInterfaces = []*ec2.InstanceNetworkInterfaceSpecification{{
+			DeviceIndex:              aws.Int64(0),
+			Groups:                   []*string{aws.String(params.SecurityGroup)},
+			AssociatePublicIpAddress: aws.Bool(!params.Private),
+		}}
 	}
 
-	return res.Instances[0].InstanceId, nil
+	if params.IamInstanceProfile != "" {
+		input.IamInstanceProfile = &ec2.IamInstanceProfileSpecification{
+			Name: aws.String(params.IamInstanceProfile),
+		}
+	}
+
+	if params.ShutdownBehavior != "" {
+		input.InstanceInitiatedShutdownBehavior = aws.String(params.ShutdownBehavior)
+	}
+
+	combinations := instanceCombinations(params.InstanceTypes, params.SubnetIds)
+
+	for i, combination := range combinations {
+		input.InstanceType = aws.String(combination.InstanceType)
+
+		if combination.SubnetId != "" {
+			input.NetworkInterfaces[0].SubnetId = aws.String(combination.SubnetId)
+		}
+
+		//Makes the retries of a throttled call idempotent.
+		input.ClientToken = aws.String(strconv.FormatInt(time.Now().UnixNano(), 36))
+
+		var res *ec2.Reservation
+
+		err = a.retry(func() (err error) {
+			res, err = c.RunInstances(&input)
+			return err
+		})
+
+		if err == nil {
+			combination.Id = aws.StringValue(res.Instances[0].InstanceId)
+			return combination, nil
+		}
+
+		awsErr, ok := err.(awserr.Error)
+
+		if !ok || !sliceContainsString(capacityErrorCodes, awsErr.Code()) {
+			return nil, errors.Wrap(err, "failed to kick a VM in EC2")
+		}
+
+		if i == len(combinations)-1 {
+			return nil, errors.Wrapf(err, "failed to kick a VM in EC2, no instance type and subnet combination is available")
+		}
+
+		log.Printf(
+			"%s is not available (%s), falling back to %s",
+			combination, awsErr.Code(), combinations[i+1],
+		)
+	}
+
+	return nil, errors.New("no instance type to create the instance with")
+}
+
+//Returns the instance type and subnet combinations, each subnet for the first instance type, then
+//for the next one.
+func instanceCombinations(instanceTypes []string, subnetIds []string) []*InstanceCreated {
+	if len(subnetIds) == 0 {
+		subnetIds = []string{""}
+	}
+
+	var combinations []*InstanceCreated
+
+	for _, instanceType := range instanceTypes {
+		for _, subnetId := range subnetIds {
+			combinations = append(combinations, &InstanceCreated{InstanceType: instanceType, SubnetId: subnetId})
+		}
+	}
+
+	return combinations
+}
+
+func (c *InstanceCreated) String() string {
+	if c.SubnetId == "" {
+		return c.InstanceType
+	}
+	return fmt.Sprintf("%s in %s", c.InstanceType, c.SubnetId)
 }
 
 type InstanceDescription struct {
-	Id *string
-	PublicIp *string
+	Id           *string
+	PublicIp     *string
+	PrivateIp    *string
+	State        string
+	InstanceType string
+	LaunchTime   *time.Time
+	Tags         map[string]string
+	//The availability zone the instance runs in.
+	AvailabilityZone string
+	//"spot" or "scheduled", empty for on-demand instances.
+	Lifecycle string
+	//As named by EC2: x86_64 or arm64.
+	Architecture string
 }
 
+//Returned when several instances match where at most one is expected.
+type AmbiguousInstanceError struct {
+	Candidates []*InstanceDescription
+}
+
+func (e *AmbiguousInstanceError) Error() string {
+	var candidates []string
+
+	for _, candidate := range e.Candidates {
+		candidates = append(candidates, fmt.Sprintf("%s (%s)", aws.StringValue(candidate.Id), candidate.State))
+	}
+
+	return fmt.Sprintf(
+		"%d instances match where one is expected: %s", len(e.Candidates), strings.Join(candidates, ", "),
+	)
+}
+
+//Returns the instance matching the tags, in one of the states, nil if there is none. Fails with
+//an AmbiguousInstanceError when there are several.
 func (a *awsImpl) InstanceDescribe(
 	tags map[string]string, states []string,
 ) (*InstanceDescription, error) {
+	instances, err := a.InstanceList(tags, states)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(instances) > 1 {
+		return nil, &AmbiguousInstanceError{Candidates: instances}
+	}
+
+	if len(instances) == 1 {
+		return instances[0], nil
+	}
+
+	return nil, nil
+}
+
+//Returns an instance by its ID, nil if it does not exist.
+func (a *awsImpl) InstanceGet(instanceId string) (*InstanceDescription, error) {
 	c, err := a.factory.EC2()
 
 	if err != nil {
 		return nil, err
 	}
 
-	var filters = mapToTagFilter(tags)
+	var res *ec2.DescribeInstancesOutput
 
-	res, err := c.DescribeInstances(&ec2.DescribeInstancesInput{
-		Filters: filters,
+	err = a.retry(func() (err error) {
+		res, err = c.DescribeInstances(&ec2.DescribeInstancesInput{
+			InstanceIds: []*string{aws.String(instanceId)},
+		})
+		return err
 	})
 
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == instanceNotFoundCode {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "failed to describe instance %s", instanceId)
+	}
+
+	for _, reservation := range res.Reservations {
+		for _, instance := range reservation.Instances {
+			return describeInstance(instance), nil
+		}
+	}
+
+	return nil, nil
+}
+
+//Returns the instances matching the tags, in one of the states, reading every page.
+func (a *awsImpl) InstanceList(
+	tags map[string]string, states []string,
+) ([]*InstanceDescription, error) {
+	c, err := a.factory.EC2()
+
 	if err != nil {
 		return nil, err
 	}
 
-	var instances []*ec2.Instance
+	input := ec2.DescribeInstancesInput{
+		Filters: mapToTagFilter(tags),
+	}
 
-	for r := range res.Reservations {
+	var instances []*InstanceDescription
 
-		for i := range res.Reservations[r].Instances {
-			instanceState := *res.Reservations[r].Instances[i].State.Name
+	for {
+		var res *ec2.DescribeInstancesOutput
 
-			if sliceContainsString(states, instanceState) {
-				instances = append(instances, res.Reservations[r].Instances[i])
+		err := a.retry(func() (err error) {
+			res, err = c.DescribeInstances(&input)
+			return err
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		for r := range res.Reservations {
+
+			for i := range res.Reservations[r].Instances {
+				instance := res.Reservations[r].Instances[i]
+
+				if sliceContainsString(states, *instance.State.Name) {
+					instances = append(instances, describeInstance(instance))
+				}
 			}
 		}
+
+		if aws.StringValue(res.NextToken) == "" {
+			return instances, nil
+		}
+
+		input.NextToken = res.NextToken
 	}
+}
 
-	if len(instances) > 0 {
-		return &InstanceDescription{
-			Id: instances[0].InstanceId,
-			PublicIp: instances[0].PublicIpAddress,
-		}, nil
+func describeInstance(instance *ec2.Instance) *InstanceDescription {
+	description := InstanceDescription{
+		Id:           instance.InstanceId,
+		PublicIp:     instance.PublicIpAddress,
+		PrivateIp:    instance.PrivateIpAddress,
+		State:        aws.StringValue(instance.State.Name),
+		InstanceType: aws.StringValue(instance.InstanceType),
+		LaunchTime:   instance.LaunchTime,
+		Tags:         tagsToMap(instance.Tags),
+		Lifecycle:    aws.StringValue(instance.InstanceLifecycle),
+		Architecture: aws.StringValue(instance.Architecture),
 	}
 
-	return nil, nil
+	if instance.Placement != nil {
+		description.AvailabilityZone = aws.StringValue(instance.Placement.AvailabilityZone)
+	}
 
+	return &description
 }
 
 func (a *awsImpl) InstanceIsReady(instanceId string) (bool, error) {
@@ -131,34 +382,214 @@ func (a *awsImpl) InstanceIsReady(instanceId string) (bool, error) {
 		return false, err
 	}
 
-	res, err := c.DescribeInstances(&ec2.DescribeInstancesInput{
-		InstanceIds: []*string{aws.String(instanceId)},
-	})
+	var res *ec2.DescribeInstancesOutput
+
+	err = a.retry(func() (err error) {
+		res, err = c.DescribeInstances(&ec2.DescribeInstancesInput{
+			InstanceIds: []*string{aws.String(instanceId)},
+		})
+		return err
+	}, instanceNotFoundCode)
 
 	if err != nil {
 		return false, errors.Wrap(err, "can't describe instance status")
 	}
 
-	return *res.Reservations[0].Instances[0].State.Name == "running", nil
+	for _, reservation := range res.Reservations {
+		for _, instance := range reservation.Instances {
+			return aws.StringValue(instance.State.Name) == "running", nil
+		}
+	}
+
+	return false, nil
 }
 
-func (a *awsImpl) InstanceTerminate(instanceId string) error {
+//Tells if both the system and instance EC2 status checks are ok.
+func (a *awsImpl) InstanceStatusChecksPassed(instanceId string) (bool, error) {
+	c, err := a.factory.EC2()
+
+	if err != nil {
+		return false, err
+	}
+
+	var res *ec2.DescribeInstanceStatusOutput
+
+	err = a.retry(func() (err error) {
+		res, err = c.DescribeInstanceStatus(&ec2.DescribeInstanceStatusInput{
+			InstanceIds:         []*string{aws.String(instanceId)},
+			IncludeAllInstances: aws.Bool(true),
+		})
+		return err
+	}, instanceNotFoundCode)
+
+	if err != nil {
+		return false, errors.Wrap(err, "can't describe instance status checks")
+	}
+
+	if len(res.InstanceStatuses) == 0 {
+		return false, nil
+	}
+
+	status := res.InstanceStatuses[0]
+
+	return status.SystemStatus != nil && aws.StringValue(status.SystemStatus.Status) == "ok" &&
+		status.InstanceStatus != nil && aws.StringValue(status.InstanceStatus.Status) == "ok", nil
+}
+
+//Adds or overwrites tags of an instance.
+func (a *awsImpl) InstanceTag(instanceId string, tags map[string]string) error {
+	c, err := a.factory.EC2()
+
+	if err != nil {
+		return err
+	}
+
+	err = a.retry(func() error {
+		_, err := c.CreateTags(&ec2.CreateTagsInput{
+			Resources: []*string{aws.String(instanceId)},
+			Tags:      mapToTags(tags),
+		})
+		return err
+	}, instanceNotFoundCode)
+
+	if err != nil {
+		return errors.Wrapf(err, "failed to tag instance %s", instanceId)
+	}
+
+	return nil
+}
+
+//Starts a stopped instance.
+func (a *awsImpl) InstanceStart(instanceId string) error {
 	c, err := a.factory.EC2()
 
 	if err != nil {
 		return err
 	}
 
-	_, err = c.TerminateInstances(&ec2.TerminateInstancesInput{
-		InstanceIds: []*string{&instanceId},
+	err = a.retry(func() error {
+		_, err := c.StartInstances(&ec2.StartInstancesInput{
+			InstanceIds: []*string{aws.String(instanceId)},
+		})
+		return err
 	})
 
+	if err != nil {
+		return errors.Wrapf(err, "failed to start instance %s", instanceId)
+	}
+
+	return nil
+}
+
+func (a *awsImpl) InstanceTerminate(instanceId string) error {
+	c, err := a.factory.EC2()
+
+	if err != nil {
+		return err
+	}
+
+	//A rollback may terminate an instance right after its creation.
+	err = a.retry(func() error {
+		_, err := c.TerminateInstances(&ec2.TerminateInstancesInput{
+			InstanceIds: []*string{&instanceId},
+		})
+		return err
+	}, instanceNotFoundCode)
+
 	if err != nil {
 		return err
 	}
 
 	return nil
 }
+
+//A volume attached to an instance.
+type VolumeDescription struct {
+	Id   string
+	Type string
+	//The size in GB.
+	Size int64
+}
+
+//Returns the volumes attached to an instance.
+func (a *awsImpl) InstanceVolumes(instanceId string) ([]*VolumeDescription, error) {
+	c, err := a.factory.EC2()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var res *ec2.DescribeVolumesOutput
+
+	err = a.retry(func() (err error) {
+		res, err = c.DescribeVolumes(&ec2.DescribeVolumesInput{
+			Filters: []*ec2.Filter{{
+				Name:   aws.String("attachment.instance-id"),
+				Values: []*string{aws.String(instanceId)},
+			}},
+		})
+		return err
+	})
+
+	if err != nil {
+		return nil, errors.Wrap(err, "can't describe instance volumes")
+	}
+
+	var volumes []*VolumeDescription
+
+	for _, volume := range res.Volumes {
+		volumes = append(volumes, &VolumeDescription{
+			Id:   aws.StringValue(volume.VolumeId),
+			Type: aws.StringValue(volume.VolumeType),
+			Size: aws.Int64Value(volume.Size),
+		})
+	}
+
+	return volumes, nil
+}
+
+//Returns the current Linux spot price of an instance type in an availability zone, nil if unknown.
+func (a *awsImpl) SpotPrice(instanceType string, availabilityZone string) (*float64, error) {
+	c, err := a.factory.EC2()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var res *ec2.DescribeSpotPriceHistoryOutput
+
+	err = a.retry(func() (err error) {
+		res, err = c.DescribeSpotPriceHistory(&ec2.DescribeSpotPriceHistoryInput{
+			AvailabilityZone:    aws.String(availabilityZone),
+			InstanceTypes:       []*string{aws.String(instanceType)},
+			ProductDescriptions: []*string{aws.String("Linux/UNIX")},
+			StartTime:           aws.Time(time.Now()),
+		})
+		return err
+	})
+
+	if err != nil {
+		return nil, errors.Wrap(err, "can't describe spot price history")
+	}
+
+	if len(res.SpotPriceHistory) == 0 {
+		return nil, nil
+	}
+
+	price, err := strconv.ParseFloat(aws.StringValue(res.SpotPriceHistory[0].SpotPrice), 64)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "can't parse spot price")
+	}
+
+	return &price, nil
+}
+
+//Records the region of the session, used by the next commands when none is given.
+func (a *awsImpl) RegionRecord() error {
+	return a.factory.RegionRecord()
+}
+
 func mapToTags(t map[string]string) []*ec2.Tag {
 	var r []*ec2.Tag
 
@@ -172,6 +603,16 @@ func mapToTags(t map[string]string) []*ec2.Tag {
 	return r
 }
 
+func tagsToMap(tags []*ec2.Tag) map[string]string {
+	var r = map[string]string{}
+
+	for _, tag := range tags {
+		r[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+
+	return r
+}
+
 func mapToTagFilter(t map[string]string) []*ec2.Filter {
 	var r []*ec2.Filter
 
@@ -0,0 +1,201 @@
+package aws
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+)
+
+//Answers DescribeInstances with the queued errors, then the pages.
+type fakeEC2 struct {
+	EC2
+	errors []error
+	pages  []*ec2.DescribeInstancesOutput
+	inputs []*ec2.DescribeInstancesInput
+}
+
+func (f *fakeEC2) DescribeInstances(input *ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error) {
+	copied := *input
+	f.inputs = append(f.inputs, &copied)
+
+	if len(f.errors) > 0 {
+		err := f.errors[0]
+		f.errors = f.errors[1:]
+		return nil, err
+	}
+
+	page := f.pages[0]
+	f.pages = f.pages[1:]
+
+	return page, nil
+}
+
+func instancePage(nextToken string, ids ...string) *ec2.DescribeInstancesOutput {
+	page := &ec2.DescribeInstancesOutput{}
+
+	if nextToken != "" {
+		page.NextToken = aws.String(nextToken)
+	}
+
+	for _, id := range ids {
+		page.Reservations = append(page.Reservations, &ec2.Reservation{
+			Instances: []*ec2.Instance{{
+				InstanceId: aws.String(id),
+				State:      &ec2.InstanceState{Name: aws.String("running")},
+			}},
+		})
+	}
+
+	return page
+}
+
+func stubbedAWS(ec2Fake *fakeEC2) (*awsImpl, *[]time.Duration) {
+	var sleeps []time.Duration
+
+	return &awsImpl{
+		factory: &fakeFactory{ec2: ec2Fake},
+		sleep: func(d time.Duration) {
+			sleeps = append(sleeps, d)
+		},
+	}, &sleeps
+}
+
+func TestInstanceListPaginates(t *testing.T) {
+	// Tear up.
+	ec2Fake := &fakeEC2{pages: []*ec2.DescribeInstancesOutput{
+		instancePage("page-2", "i-1"),
+		instancePage("", "i-2"),
+	}}
+	a, _ := stubbedAWS(ec2Fake)
+
+	//Assertions
+	instances, err := a.InstanceList(map[string]string{"owner": "barney"}, []string{"running"})
+
+	assert.Nil(t, err)
+	assert.Len(t, instances, 2)
+	assert.Equal(t, "i-2", *instances[1].Id)
+	assert.Nil(t, ec2Fake.inputs[0].NextToken)
+	assert.Equal(t, "page-2", aws.StringValue(ec2Fake.inputs[1].NextToken))
+}
+
+func TestInstanceDescribeAmbiguous(t *testing.T) {
+	// Tear up.
+	a, _ := stubbedAWS(&fakeEC2{pages: []*ec2.DescribeInstancesOutput{
+		instancePage("page-2", "i-1"),
+		instancePage("", "i-2"),
+	}})
+
+	//Assertions
+	instance, err := a.InstanceDescribe(map[string]string{"owner": "barney"}, []string{"running"})
+
+	assert.Nil(t, instance)
+	assert.EqualError(t, err, "2 instances match where one is expected: i-1 (running), i-2 (running)")
+	assert.Len(t, err.(*AmbiguousInstanceError).Candidates, 2)
+}
+
+func TestInstanceIsReadyRetries(t *testing.T) {
+	// Tear up.
+	ec2Fake := &fakeEC2{
+		errors: []error{
+			awserr.New("RequestLimitExceeded", "Request limit exceeded.", nil),
+			awserr.New("InvalidInstanceID.NotFound", "The instance ID 'i-1' does not exist", nil),
+		},
+		pages: []*ec2.DescribeInstancesOutput{instancePage("", "i-1")},
+	}
+	a, sleeps := stubbedAWS(ec2Fake)
+
+	//Assertions
+	ready, err := a.InstanceIsReady("i-1")
+
+	assert.Nil(t, err)
+	assert.True(t, ready)
+	assert.Len(t, *sleeps, 2)
+	assert.True(t, (*sleeps)[0] >= 250*time.Millisecond && (*sleeps)[0] <= 500*time.Millisecond)
+
+	ec2Fake.pages = []*ec2.DescribeInstancesOutput{{}}
+
+	ready, err = a.InstanceIsReady("i-1")
+
+	assert.Nil(t, err)
+	assert.False(t, ready, "an empty answer should not be ready")
+}
+
+func TestInstanceGetDoesNotRetryNotFound(t *testing.T) {
+	// Tear up.
+	a, sleeps := stubbedAWS(&fakeEC2{errors: []error{
+		awserr.New("InvalidInstanceID.NotFound", "The instance ID 'i-1' does not exist", nil),
+	}})
+
+	//Assertions
+	instance, err := a.InstanceGet("i-1")
+
+	assert.Nil(t, err)
+	assert.Nil(t, instance)
+	assert.Empty(t, *sleeps)
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	// Tear up.
+	a, sleeps := stubbedAWS(&fakeEC2{})
+	calls := 0
+
+	//Assertions
+	err := a.retry(func() error {
+		calls++
+		return awserr.New("Throttling", "Rate exceeded", nil)
+	})
+
+	assert.EqualError(t, err, "Throttling: Rate exceeded")
+	assert.Equal(t, maxAttempts, calls)
+	assert.Len(t, *sleeps, maxAttempts-1)
+}
+
+func TestInstanceCombinations(t *testing.T) {
+	//Assertions
+	assert.Equal(t, []*InstanceCreated{
+		{InstanceType: "t3.large", SubnetId: "subnet-a"},
+		{InstanceType: "t3.large", SubnetId: "subnet-b"},
+		{InstanceType: "m5.large", SubnetId: "subnet-a"},
+		{InstanceType: "m5.large", SubnetId: "subnet-b"},
+	}, instanceCombinations([]string{"t3.large", "m5.large"}, []string{"subnet-a", "subnet-b"}))
+
+	assert.Equal(t, []*InstanceCreated{{InstanceType: "t3.large"}}, instanceCombinations([]string{"t3.large"}, nil))
+	assert.Equal(t, "t3.large in subnet-a", (&InstanceCreated{InstanceType: "t3.large", SubnetId: "subnet-a"}).String())
+}
+
+type fakeImagesEC2 struct {
+	EC2
+	input  *ec2.DescribeImagesInput
+	images []*ec2.Image
+}
+
+func (f *fakeImagesEC2) DescribeImages(input *ec2.DescribeImagesInput) (*ec2.DescribeImagesOutput, error) {
+	f.input = input
+	return &ec2.DescribeImagesOutput{Images: f.images}, nil
+}
+
+func TestImageLatest(t *testing.T) {
+	// Tear up.
+	ec2Fake := &fakeImagesEC2{images: []*ec2.Image{
+		{ImageId: aws.String("ami-old"), CreationDate: aws.String("2020-09-01T10:00:00.000Z")},
+		{ImageId: aws.String("ami-new"), CreationDate: aws.String("2020-10-01T10:00:00.000Z")},
+	}}
+	a := awsImpl{factory: &fakeFactory{ec2: ec2Fake}}
+
+	//Assertions
+	image, err := a.ImageLatest("arm64")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "ami-new", image)
+	assert.Equal(t, "arm64", aws.StringValue(ec2Fake.input.Filters[1].Values[0]))
+
+	ec2Fake.images = nil
+
+	_, err = a.ImageLatest("arm64")
+
+	assert.EqualError(t, err, "no arm64 Amazon Linux 2 image found in the region")
+}
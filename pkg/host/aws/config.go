@@ -0,0 +1,60 @@
+package aws
+
+import (
+	"github.com/spf13/pflag"
+)
+
+//Options of the AWS sessions, the SDK defaults (environment, shared config) apply to empty ones.
+type SessionConfig struct {
+	Region  string
+	Profile string
+	//A role assumed on top of the profile credentials.
+	RoleARN    string
+	ExternalID string
+	//The serial number (or ARN) of the MFA device the role requires, its token is prompted.
+	MFASerial string
+	//Overrides the endpoint of the AWS services, for proxies and emulators.
+	EndpointURL string
+}
+
+//The session options given on the command line, see AddFlags.
+var CommandLineConfig = SessionConfig{}
+
+//Adds the flags filling CommandLineConfig.
+func AddFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(
+		&CommandLineConfig.Region, "region", "", "",
+		"The AWS region (default: the one recorded by up, then the AWS configuration)",
+	)
+	flags.StringVarP(&CommandLineConfig.Profile, "profile", "", "", "The AWS profile to use")
+	flags.StringVarP(&CommandLineConfig.RoleARN, "role-arn", "", "", "An AWS role to assume")
+	flags.StringVarP(&CommandLineConfig.ExternalID, "external-id", "", "", "The external ID of the role to assume")
+	flags.StringVarP(
+		&CommandLineConfig.MFASerial, "mfa-serial", "", "",
+		"The MFA device required by the role to assume, its token is prompted",
+	)
+	flags.StringVarP(&CommandLineConfig.EndpointURL, "endpoint-url", "", "", "Overrides the AWS endpoint URL")
+}
+
+//Returns the command line flags reproducing the configuration, for sub-processes.
+func (c *SessionConfig) Args() []string {
+	var args []string
+
+	for _, flag := range []struct {
+		name  string
+		value string
+	}{
+		{"region", c.Region},
+		{"profile", c.Profile},
+		{"role-arn", c.RoleARN},
+		{"external-id", c.ExternalID},
+		{"mfa-serial", c.MFASerial},
+		{"endpoint-url", c.EndpointURL},
+	} {
+		if flag.value != "" {
+			args = append(args, "--"+flag.name, flag.value)
+		}
+	}
+
+	return args
+}
@@ -0,0 +1,427 @@
+//Package ec2test provides an in-memory EC2 API, to run the ec2 driver without an AWS account.
+package ec2test
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//The identity every call is made with, as answered by STS GetCallerIdentity.
+const CallerArn = "arn:aws:iam::123456789012:user/barney"
+
+//The codes of the instance states, by name.
+var stateCodes = map[string]int{
+	"pending":       0,
+	"running":       16,
+	"shutting-down": 32,
+	"terminated":    48,
+	"stopping":      64,
+	"stopped":       80,
+}
+
+//An EC2 API emulator, listening on a local HTTP endpoint to give as the endpoint URL of the AWS
+//SDK. It implements the instance lifecycle calls (RunInstances, DescribeInstances,
+//DescribeInstanceStatus, StartInstances, TerminateInstances, CreateTags) along with STS
+//GetCallerIdentity, which shares the endpoint.
+type Server struct {
+	//The endpoint of the emulator.
+	URL string
+	//How long an instance stays pending before running, and shutting down before terminated.
+	Delay time.Duration
+	//The instance types without capacity, RunInstances fails with InsufficientInstanceCapacity.
+	Unavailable []string
+
+	server    *httptest.Server
+	mutex     sync.Mutex
+	instances []*instance
+	now       func() time.Time
+}
+
+type instance struct {
+	id           string
+	imageId      string
+	instanceType string
+	state        string
+	//When the instance entered its state.
+	changedAt  time.Time
+	launchTime time.Time
+	privateIp  string
+	publicIp   string
+	tags       map[string]string
+}
+
+//Starts an emulator, Close stops it.
+func CreateServer() *Server {
+	s := &Server{now: time.Now}
+	s.server = httptest.NewServer(http.HandlerFunc(s.handle))
+	s.URL = s.server.URL
+
+	return s
+}
+
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+//Returns the state of every instance launched, by ID.
+func (s *Server) States() map[string]string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.refresh()
+
+	states := map[string]string{}
+
+	for _, i := range s.instances {
+		states[i.id] = i.state
+	}
+
+	return states
+}
+
+//Moves the instances whose transition is over to their next state.
+func (s *Server) refresh() {
+	now := s.now()
+
+	for _, i := range s.instances {
+		if now.Sub(i.changedAt) < s.Delay {
+			continue
+		}
+
+		switch i.state {
+		case "pending":
+			i.setState("running", now)
+		case "shutting-down":
+			i.setState("terminated", now)
+		case "stopping":
+			i.setState("stopped", now)
+		}
+	}
+}
+
+func (i *instance) setState(state string, now time.Time) {
+	i.state = state
+	i.changedAt = now
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, "MalformedQueryString", err.Error())
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.refresh()
+
+	switch action := r.Form.Get("Action"); action {
+	case "CreateTags":
+		s.createTags(w, r.Form)
+	case "DescribeAddresses":
+		//Elastic IPs are not emulated.
+		writeResponse(w, &describeAddressesResponse{})
+	case "DescribeInstanceStatus":
+		s.describeInstanceStatus(w, r.Form)
+	case "DescribeInstances":
+		s.describeInstances(w, r.Form)
+	case "GetCallerIdentity":
+		writeResponse(w, &getCallerIdentityResponse{
+			Arn:     CallerArn,
+			Account: "123456789012",
+			UserId:  "AIDAEXAMPLE",
+		})
+	case "RunInstances":
+		s.runInstances(w, r.Form)
+	case "StartInstances":
+		s.changeStates(w, r.Form, "startInstancesResponse", "pending", "stopped")
+	case "TerminateInstances":
+		s.changeStates(w, r.Form, "terminateInstancesResponse", "shutting-down", "")
+	default:
+		writeError(w, http.StatusBadRequest, "InvalidAction", fmt.Sprintf("%s is not emulated", action))
+	}
+}
+
+func (s *Server) runInstances(w http.ResponseWriter, form url.Values) {
+	if form.Get("ImageId") == "" {
+		writeError(w, http.StatusBadRequest, "MissingParameter", "The request must contain the parameter ImageId")
+		return
+	}
+
+	if contains(s.Unavailable, form.Get("InstanceType")) {
+		writeError(
+			w, http.StatusInternalServerError, "InsufficientInstanceCapacity",
+			fmt.Sprintf("We currently do not have sufficient %s capacity.", form.Get("InstanceType")),
+		)
+		return
+	}
+
+	now := s.now()
+	number := len(s.instances) + 1
+	created := &instance{
+		id:           fmt.Sprintf("i-%017x", number),
+		imageId:      form.Get("ImageId"),
+		instanceType: form.Get("InstanceType"),
+		state:        "pending",
+		changedAt:    now,
+		launchTime:   now,
+		privateIp:    fmt.Sprintf("10.0.%d.%d", number/250, number%250+4),
+		tags:         map[string]string{},
+	}
+
+	if form.Get("NetworkInterface.1.AssociatePublicIpAddress") != "false" {
+		created.publicIp = fmt.Sprintf("203.0.113.%d", number%250+4)
+	}
+
+	for n := 1; form.Get(fmt.Sprintf("TagSpecification.%d.ResourceType", n)) != ""; n++ {
+		prefix := fmt.Sprintf("TagSpecification.%d.", n)
+
+		if form.Get(prefix+"ResourceType") == "instance" {
+			addTags(created.tags, form, prefix+"Tag.")
+		}
+	}
+
+	s.instances = append(s.instances, created)
+
+	writeResponse(w, &runInstancesResponse{
+		ReservationId: "r-" + created.id[2:],
+		Instances:     []instanceItem{created.item()},
+	})
+}
+
+func (s *Server) describeInstances(w http.ResponseWriter, form url.Values) {
+	matching, err := s.lookup(list(form, "InstanceId."))
+
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidInstanceID.NotFound", err.Error())
+		return
+	}
+
+	filters := map[string][]string{}
+
+	for n := 1; form.Get(fmt.Sprintf("Filter.%d.Name", n)) != ""; n++ {
+		prefix := fmt.Sprintf("Filter.%d.", n)
+		filters[form.Get(prefix+"Name")] = list(form, prefix+"Value.")
+	}
+
+	res := describeInstancesResponse{}
+
+	for _, i := range matching {
+		if i.matches(filters) {
+			res.Reservations = append(res.Reservations, reservationItem{
+				ReservationId: "r-" + i.id[2:],
+				Instances:     []instanceItem{i.item()},
+			})
+		}
+	}
+
+	writeResponse(w, &res)
+}
+
+func (s *Server) describeInstanceStatus(w http.ResponseWriter, form url.Values) {
+	matching, err := s.lookup(list(form, "InstanceId."))
+
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidInstanceID.NotFound", err.Error())
+		return
+	}
+
+	res := describeInstanceStatusResponse{}
+
+	for _, i := range matching {
+		if i.state != "running" && form.Get("IncludeAllInstances") != "true" {
+			continue
+		}
+
+		//The status checks pass as soon as the instance runs.
+		status := "not-applicable"
+
+		if i.state == "running" {
+			status = "ok"
+		}
+
+		res.Statuses = append(res.Statuses, statusItem{
+			InstanceId:     i.id,
+			InstanceState:  stateItem{Code: stateCodes[i.state], Name: i.state},
+			SystemStatus:   summaryItem{Status: status},
+			InstanceStatus: summaryItem{Status: status},
+		})
+	}
+
+	writeResponse(w, &res)
+}
+
+//Moves instances to a state, from the given one only when not empty.
+func (s *Server) changeStates(w http.ResponseWriter, form url.Values, name string, state string, from string) {
+	matching, err := s.lookup(list(form, "InstanceId."))
+
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidInstanceID.NotFound", err.Error())
+		return
+	}
+
+	res := stateChangeResponse{XMLName: xml.Name{Local: name}}
+
+	for _, i := range matching {
+		if from != "" && i.state != from {
+			writeError(
+				w, http.StatusBadRequest, "IncorrectInstanceState",
+				fmt.Sprintf("The instance '%s' is not in the '%s' state.", i.id, from),
+			)
+			return
+		}
+
+		previous := i.state
+
+		if previous != "terminated" {
+			i.setState(state, s.now())
+		}
+
+		res.Instances = append(res.Instances, stateChangeItem{
+			InstanceId:    i.id,
+			CurrentState:  stateItem{Code: stateCodes[i.state], Name: i.state},
+			PreviousState: stateItem{Code: stateCodes[previous], Name: previous},
+		})
+	}
+
+	writeResponse(w, &res)
+}
+
+func (s *Server) createTags(w http.ResponseWriter, form url.Values) {
+	matching, err := s.lookup(list(form, "ResourceId."))
+
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidID", err.Error())
+		return
+	}
+
+	for _, i := range matching {
+		addTags(i.tags, form, "Tag.")
+	}
+
+	writeResponse(w, &createTagsResponse{Return: true})
+}
+
+//Returns the instances with the given IDs, all of them when none is given.
+func (s *Server) lookup(ids []string) ([]*instance, error) {
+	if len(ids) == 0 {
+		return s.instances, nil
+	}
+
+	var found []*instance
+
+	for _, id := range ids {
+		var match *instance
+
+		for _, i := range s.instances {
+			if i.id == id {
+				match = i
+			}
+		}
+
+		if match == nil {
+			return nil, fmt.Errorf("The instance ID '%s' does not exist", id)
+		}
+
+		found = append(found, match)
+	}
+
+	return found, nil
+}
+
+//Tells if an instance matches the tag:<key>, instance-id and instance-state-name filters.
+func (i *instance) matches(filters map[string][]string) bool {
+	for name, values := range filters {
+		var value string
+
+		switch {
+		case strings.HasPrefix(name, "tag:"):
+			tag, ok := i.tags[strings.TrimPrefix(name, "tag:")]
+
+			if !ok {
+				return false
+			}
+
+			value = tag
+		case name == "instance-id":
+			value = i.id
+		case name == "instance-state-name":
+			value = i.state
+		default:
+			continue
+		}
+
+		if !contains(values, value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (i *instance) item() instanceItem {
+	item := instanceItem{
+		InstanceId:       i.id,
+		ImageId:          i.imageId,
+		InstanceType:     i.instanceType,
+		InstanceState:    stateItem{Code: stateCodes[i.state], Name: i.state},
+		LaunchTime:       i.launchTime.UTC().Format(time.RFC3339),
+		AvailabilityZone: "us-east-1a",
+		//The images are not emulated, they are all x86_64.
+		Architecture: "x86_64",
+	}
+
+	//Terminated instances lose their addresses.
+	if i.state != "terminated" {
+		item.PrivateIpAddress = i.privateIp
+		item.IpAddress = i.publicIp
+	}
+
+	keys := make([]string, 0, len(i.tags))
+
+	for key := range i.tags {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		item.Tags = append(item.Tags, tagItem{Key: key, Value: i.tags[key]})
+	}
+
+	return item
+}
+
+//Reads the <prefix>N.Key and <prefix>N.Value parameters.
+func addTags(tags map[string]string, form url.Values, prefix string) {
+	for n := 1; form.Get(fmt.Sprintf("%s%d.Key", prefix, n)) != ""; n++ {
+		tags[form.Get(fmt.Sprintf("%s%d.Key", prefix, n))] = form.Get(fmt.Sprintf("%s%d.Value", prefix, n))
+	}
+}
+
+//Reads the <prefix>N parameters.
+func list(form url.Values, prefix string) []string {
+	var values []string
+
+	for n := 1; form.Get(fmt.Sprintf("%s%d", prefix, n)) != ""; n++ {
+		values = append(values, form.Get(fmt.Sprintf("%s%d", prefix, n)))
+	}
+
+	return values
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
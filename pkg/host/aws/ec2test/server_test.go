@@ -0,0 +1,81 @@
+package ec2test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/knlambert/docker-remote.git/pkg/host/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstanceLifecycle(t *testing.T) {
+	// Tear up.
+	os.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	os.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	server := CreateServer()
+	defer server.Close()
+
+	now := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	server.now = func() time.Time { return now }
+	server.Delay = time.Minute
+	server.Unavailable = []string{"t3.large"}
+
+	client := aws.CreateFromConfig(&aws.SessionConfig{Region: "us-east-1", EndpointURL: server.URL})
+	tags := map[string]string{"owner": CallerArn, "managed_by": "docker-remote"}
+
+	//Assertions
+	identity, err := client.CallerIdentity()
+
+	assert.Nil(t, err)
+	assert.Equal(t, CallerArn, identity)
+
+	created, err := client.InstanceCreate(&aws.InstanceCreateParams{
+		AMI:           "ami-0123",
+		InstanceTypes: []string{"t3.large", "t3.medium"},
+		Tags:          tags,
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "t3.medium", created.InstanceType)
+
+	id := &created.Id
+
+	ready, err := client.InstanceIsReady(*id)
+
+	assert.Nil(t, err)
+	assert.False(t, ready, "the instance should be pending for a minute")
+
+	now = now.Add(time.Minute)
+
+	instance, err := client.InstanceDescribe(tags, []string{"running"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, *id, *instance.Id)
+	assert.Equal(t, "t3.medium", instance.InstanceType)
+	assert.Equal(t, "203.0.113.5", *instance.PublicIp)
+	assert.Equal(t, tags, instance.Tags)
+
+	passed, err := client.InstanceStatusChecksPassed(*id)
+
+	assert.Nil(t, err)
+	assert.True(t, passed)
+
+	other, err := client.InstanceDescribe(map[string]string{"owner": "fred"}, []string{"running"})
+
+	assert.Nil(t, err)
+	assert.Nil(t, other)
+
+	assert.Nil(t, client.InstanceTerminate(*id))
+	assert.Equal(t, map[string]string{*id: "shutting-down"}, server.States())
+
+	now = now.Add(time.Minute)
+
+	assert.Equal(t, map[string]string{*id: "terminated"}, server.States())
+
+	missing, err := client.InstanceGet("i-404")
+
+	assert.Nil(t, err)
+	assert.Nil(t, missing)
+}
@@ -0,0 +1,110 @@
+package ec2test
+
+import (
+	"encoding/xml"
+	"net/http"
+)
+
+//The documents of the EC2 query protocol, the items of a list are <item> elements.
+
+type stateItem struct {
+	Code int    `xml:"code"`
+	Name string `xml:"name"`
+}
+
+type tagItem struct {
+	Key   string `xml:"key"`
+	Value string `xml:"value"`
+}
+
+type instanceItem struct {
+	InstanceId       string    `xml:"instanceId"`
+	ImageId          string    `xml:"imageId"`
+	InstanceState    stateItem `xml:"instanceState"`
+	PrivateIpAddress string    `xml:"privateIpAddress,omitempty"`
+	IpAddress        string    `xml:"ipAddress,omitempty"`
+	InstanceType     string    `xml:"instanceType"`
+	Architecture     string    `xml:"architecture"`
+	LaunchTime       string    `xml:"launchTime"`
+	AvailabilityZone string    `xml:"placement>availabilityZone"`
+	Tags             []tagItem `xml:"tagSet>item"`
+}
+
+type reservationItem struct {
+	ReservationId string         `xml:"reservationId"`
+	Instances     []instanceItem `xml:"instancesSet>item"`
+}
+
+type runInstancesResponse struct {
+	XMLName       xml.Name       `xml:"RunInstancesResponse"`
+	ReservationId string         `xml:"reservationId"`
+	Instances     []instanceItem `xml:"instancesSet>item"`
+}
+
+type describeInstancesResponse struct {
+	XMLName      xml.Name          `xml:"DescribeInstancesResponse"`
+	Reservations []reservationItem `xml:"reservationSet>item"`
+}
+
+type summaryItem struct {
+	Status string `xml:"status"`
+}
+
+type statusItem struct {
+	InstanceId     string      `xml:"instanceId"`
+	InstanceState  stateItem   `xml:"instanceState"`
+	SystemStatus   summaryItem `xml:"systemStatus"`
+	InstanceStatus summaryItem `xml:"instanceStatus"`
+}
+
+type describeInstanceStatusResponse struct {
+	XMLName  xml.Name     `xml:"DescribeInstanceStatusResponse"`
+	Statuses []statusItem `xml:"instanceStatusSet>item"`
+}
+
+type stateChangeItem struct {
+	InstanceId    string    `xml:"instanceId"`
+	CurrentState  stateItem `xml:"currentState"`
+	PreviousState stateItem `xml:"previousState"`
+}
+
+type stateChangeResponse struct {
+	XMLName   xml.Name
+	Instances []stateChangeItem `xml:"instancesSet>item"`
+}
+
+type createTagsResponse struct {
+	XMLName xml.Name `xml:"CreateTagsResponse"`
+	Return  bool     `xml:"return"`
+}
+
+type describeAddressesResponse struct {
+	XMLName   xml.Name `xml:"DescribeAddressesResponse"`
+	Addresses struct{} `xml:"addressesSet"`
+}
+
+//STS answers with the query protocol, the fields are wrapped in a result element.
+type getCallerIdentityResponse struct {
+	XMLName xml.Name `xml:"GetCallerIdentityResponse"`
+	Arn     string   `xml:"GetCallerIdentityResult>Arn"`
+	Account string   `xml:"GetCallerIdentityResult>Account"`
+	UserId  string   `xml:"GetCallerIdentityResult>UserId"`
+}
+
+type errorResponse struct {
+	XMLName   xml.Name `xml:"Response"`
+	Code      string   `xml:"Errors>Error>Code"`
+	Message   string   `xml:"Errors>Error>Message"`
+	RequestID string   `xml:"RequestID"`
+}
+
+func writeResponse(w http.ResponseWriter, document interface{}) {
+	w.Header().Set("Content-Type", "text/xml;charset=UTF-8")
+	_ = xml.NewEncoder(w).Encode(document)
+}
+
+func writeError(w http.ResponseWriter, status int, code string, message string) {
+	w.Header().Set("Content-Type", "text/xml;charset=UTF-8")
+	w.WriteHeader(status)
+	_ = xml.NewEncoder(w).Encode(&errorResponse{Code: code, Message: message, RequestID: "ec2test"})
+}
@@ -0,0 +1,63 @@
+package aws
+
+import (
+	"encoding/base64"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/pkg/errors"
+)
+
+//The docker credentials of an ECR registry.
+type RegistryAuthorization struct {
+	//The registry URL, "https://<account>.dkr.ecr.<region>.amazonaws.com".
+	Endpoint string
+	Username string
+	Password string
+}
+
+//Returns the docker credentials of ECR registries, the caller's account one when none is given.
+func (a *awsImpl) RegistryAuthorizations(registryIds []string) ([]*RegistryAuthorization, error) {
+	c, err := a.factory.ECR()
+
+	if err != nil {
+		return nil, err
+	}
+
+	input := ecr.GetAuthorizationTokenInput{}
+
+	if len(registryIds) > 0 {
+		input.RegistryIds = aws.StringSlice(registryIds)
+	}
+
+	res, err := c.GetAuthorizationToken(&input)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get an ECR authorization token")
+	}
+
+	var authorizations []*RegistryAuthorization
+
+	for _, data := range res.AuthorizationData {
+		token, err := base64.StdEncoding.DecodeString(aws.StringValue(data.AuthorizationToken))
+
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to decode the ECR authorization token")
+		}
+
+		credentials := strings.SplitN(string(token), ":", 2)
+
+		if len(credentials) != 2 {
+			return nil, errors.New("unexpected ECR authorization token format")
+		}
+
+		authorizations = append(authorizations, &RegistryAuthorization{
+			Endpoint: aws.StringValue(data.ProxyEndpoint),
+			Username: credentials[0],
+			Password: credentials[1],
+		})
+	}
+
+	return authorizations, nil
+}
@@ -0,0 +1,70 @@
+package aws
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/stretchr/testify/assert"
+)
+
+//Returns the same token for each registry asked, the caller's account one when none is.
+type fakeECR struct {
+	token string
+}
+
+func (f *fakeECR) GetAuthorizationToken(input *ecr.GetAuthorizationTokenInput) (*ecr.GetAuthorizationTokenOutput, error) {
+	registryIds := aws.StringValueSlice(input.RegistryIds)
+
+	if len(registryIds) == 0 {
+		registryIds = []string{"123456789012"}
+	}
+
+	var data []*ecr.AuthorizationData
+
+	for _, id := range registryIds {
+		data = append(data, &ecr.AuthorizationData{
+			AuthorizationToken: aws.String(f.token),
+			ProxyEndpoint:      aws.String("https://" + id + ".dkr.ecr.us-east-1.amazonaws.com"),
+		})
+	}
+
+	return &ecr.GetAuthorizationTokenOutput{AuthorizationData: data}, nil
+}
+
+func TestRegistryAuthorizations(t *testing.T) {
+	// Tear up.
+	ecrFake := &fakeECR{token: base64.StdEncoding.EncodeToString([]byte("AWS:pass:word"))}
+	a := awsImpl{factory: &fakeFactory{ecr: ecrFake}}
+
+	//Assertions
+	authorizations, err := a.RegistryAuthorizations([]string{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []*RegistryAuthorization{{
+		Endpoint: "https://123456789012.dkr.ecr.us-east-1.amazonaws.com",
+		Username: "AWS",
+		Password: "pass:word",
+	}}, authorizations)
+
+	authorizations, err = a.RegistryAuthorizations([]string{"123456789012", "210987654321"})
+
+	assert.Nil(t, err)
+	assert.Len(t, authorizations, 2)
+	assert.Equal(t, "https://210987654321.dkr.ecr.us-east-1.amazonaws.com", authorizations[1].Endpoint)
+}
+
+func TestRegistryAuthorizationsRejectsMalformedTokens(t *testing.T) {
+	// Tear up.
+	a := awsImpl{factory: &fakeFactory{ecr: &fakeECR{token: "not base64 !"}}}
+
+	//Assertions
+	_, err := a.RegistryAuthorizations(nil)
+	assert.Contains(t, err.Error(), "failed to decode the ECR authorization token")
+
+	a = awsImpl{factory: &fakeFactory{ecr: &fakeECR{token: base64.StdEncoding.EncodeToString([]byte("AWS"))}}}
+
+	_, err = a.RegistryAuthorizations(nil)
+	assert.EqualError(t, err, "unexpected ECR authorization token format")
+}
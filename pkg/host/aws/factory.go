@@ -1,20 +1,51 @@
 package aws
 
 import (
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/knlambert/docker-remote.git/pkg/state"
 	"github.com/pkg/errors"
 )
 
+//The state key of the region the host was created in.
+const regionStateKey = "region"
+
 type Factory interface {
 	EC2() (EC2, error)
+	ECR() (ECR, error)
+	IAM() (IAM, error)
+	//The region the clients are configured for.
+	Region() (string, error)
+	//Records the region of the session, used by the next commands when none is given.
+	RegionRecord() error
+	SSM() (SSM, error)
+	STS() (STS, error)
 }
 
-func CreateFactory() Factory {
-	return &factoryImpl{}
+func CreateFactory(config *SessionConfig) Factory {
+	return &factoryImpl{
+		config: config,
+		state:  state.CreateStore(),
+	}
 }
 
-type factoryImpl struct {}
+type factoryImpl struct {
+	config *SessionConfig
+	state  state.Store
+
+	//The session, built once on first use.
+	once    sync.Once
+	cached  *session.Session
+	failure error
+}
 
 func (f *factoryImpl) EC2() (EC2, error) {
 	s, err := f.session()
@@ -26,13 +57,131 @@ func (f *factoryImpl) EC2() (EC2, error) {
 	return ec2.New(s), nil
 }
 
-func (f *factoryImpl) session() (*session.Session, error) {
-	s, err := session.NewSession()
+func (f *factoryImpl) ECR() (ECR, error) {
+	s, err := f.session()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ecr.New(s), nil
+}
+
+func (f *factoryImpl) IAM() (IAM, error) {
+	s, err := f.session()
+
+	if err != nil {
+		return nil, err
+	}
 
+	return iam.New(s), nil
+}
+
+func (f *factoryImpl) SSM() (SSM, error) {
+	s, err := f.session()
 
 	if err != nil {
 		return nil, err
 	}
 
-	return s, errors.Wrapf(err, "failed to create session")
+	return ssm.New(s), nil
+}
+
+func (f *factoryImpl) STS() (STS, error) {
+	s, err := f.session()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return sts.New(s), nil
+}
+
+func (f *factoryImpl) Region() (string, error) {
+	s, err := f.session()
+
+	if err != nil {
+		return "", err
+	}
+
+	return aws.StringValue(s.Config.Region), nil
+}
+
+//Records the region of the session, used by the next commands when none is given.
+func (f *factoryImpl) RegionRecord() error {
+	region, err := f.Region()
+
+	if err != nil {
+		return err
+	}
+
+	return f.state.Set("ec2", regionStateKey, region)
+}
+
+func (f *factoryImpl) session() (*session.Session, error) {
+	f.once.Do(func() {
+		f.cached, f.failure = f.newSession()
+	})
+
+	return f.cached, f.failure
+}
+
+//Builds a session from the configuration: the region falls back on the recorded one,
+//then on the SDK defaults.
+func (f *factoryImpl) newSession() (*session.Session, error) {
+	config := aws.Config{}
+	region := f.config.Region
+
+	if region == "" {
+		recorded, err := f.state.Get("ec2", regionStateKey)
+
+		if err != nil {
+			return nil, err
+		}
+
+		region = recorded
+	}
+
+	if region != "" {
+		config.Region = aws.String(region)
+	}
+
+	if f.config.EndpointURL != "" {
+		config.Endpoint = aws.String(f.config.EndpointURL)
+	}
+
+	s, err := session.NewSessionWithOptions(session.Options{
+		Config:                  config,
+		Profile:                 f.config.Profile,
+		SharedConfigState:       session.SharedConfigEnable,
+		AssumeRoleTokenProvider: stscreds.StdinTokenProvider,
+	})
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create session")
+	}
+
+	if aws.StringValue(s.Config.Region) == "" {
+		return nil, errors.New("no AWS region configured, use --region")
+	}
+
+	if f.config.RoleARN != "" {
+		s = s.Copy(&aws.Config{
+			Credentials: stscreds.NewCredentials(s, f.config.RoleARN, func(p *stscreds.AssumeRoleProvider) {
+				//The default name changes on every call, this one keeps the CloudTrail logs readable.
+				p.RoleSessionName = roleSessionName
+
+				if f.config.ExternalID != "" {
+					p.ExternalID = aws.String(f.config.ExternalID)
+				}
+
+				if f.config.MFASerial != "" {
+					p.SerialNumber = aws.String(f.config.MFASerial)
+					p.TokenProvider = stscreds.StdinTokenProvider
+				}
+			}),
+		})
+	}
+
+	return s, nil
 }
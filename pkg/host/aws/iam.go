@@ -0,0 +1,149 @@
+package aws
+
+import (
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/pkg/errors"
+)
+
+const (
+	//Lets EC2 instances assume a role.
+	ec2TrustPolicy = `{
+  "Version": "2012-10-17",
+  "Statement": [{
+    "Effect": "Allow",
+    "Principal": {"Service": "ec2.amazonaws.com"},
+    "Action": "sts:AssumeRole"
+  }]
+}`
+	//How long EC2 takes to see an instance profile IAM just created.
+	instanceProfilePropagationDelay = 10 * time.Second
+)
+
+//Creates an instance profile and its role (both named after the profile) with managed
+//policies, or completes them when they exist with a missing part. Returns whether anything changed.
+func (a *awsImpl) InstanceProfileEnsure(name string, policyARNs []string) (bool, error) {
+	c, err := a.factory.IAM()
+
+	if err != nil {
+		return false, err
+	}
+
+	profileExists, roleInProfile := true, false
+
+	var res *iam.GetInstanceProfileOutput
+
+	if err := a.retry(func() (err error) {
+		res, err = c.GetInstanceProfile(&iam.GetInstanceProfileInput{
+			InstanceProfileName: aws.String(name),
+		})
+		return err
+	}); err == nil {
+		for _, role := range res.InstanceProfile.Roles {
+			roleInProfile = roleInProfile || aws.StringValue(role.RoleName) == name
+		}
+	} else if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == iam.ErrCodeNoSuchEntityException {
+		profileExists = false
+	} else {
+		return false, errors.Wrapf(err, "failed to get instance profile %s", name)
+	}
+
+	changed := false
+
+	if !roleInProfile {
+		if err := a.retry(func() error {
+			_, err := c.CreateRole(&iam.CreateRoleInput{
+				RoleName:                 aws.String(name),
+				AssumeRolePolicyDocument: aws.String(ec2TrustPolicy),
+				Description:              aws.String("Docker hosts created by docker-remote"),
+			})
+			return err
+		}); err == nil {
+			log.Printf("Created the role %s", name)
+			changed = true
+		} else if awsErr, ok := err.(awserr.Error); !ok || awsErr.Code() != iam.ErrCodeEntityAlreadyExistsException {
+			return false, errors.Wrapf(err, "failed to create role %s", name)
+		}
+	}
+
+	attached := map[string]bool{}
+
+	if err := a.retry(func() error {
+		return c.ListAttachedRolePoliciesPages(&iam.ListAttachedRolePoliciesInput{
+			RoleName: aws.String(name),
+		}, func(page *iam.ListAttachedRolePoliciesOutput, lastPage bool) bool {
+			for _, policy := range page.AttachedPolicies {
+				attached[aws.StringValue(policy.PolicyArn)] = true
+			}
+			return true
+		})
+	}); err != nil {
+		return false, errors.Wrapf(err, "failed to list the policies of role %s", name)
+	}
+
+	for _, policyARN := range policyARNs {
+		if attached[policyARN] {
+			continue
+		}
+
+		if err := a.retry(func() error {
+			_, err := c.AttachRolePolicy(&iam.AttachRolePolicyInput{
+				RoleName:  aws.String(name),
+				PolicyArn: aws.String(policyARN),
+			})
+			return err
+		}); err != nil {
+			return false, errors.Wrapf(err, "failed to attach %s to role %s", policyARN, name)
+		}
+
+		log.Printf("Attached %s to the role %s", policyARN, name)
+		changed = true
+	}
+
+	if !profileExists {
+		if err := a.retry(func() error {
+			_, err := c.CreateInstanceProfile(&iam.CreateInstanceProfileInput{
+				InstanceProfileName: aws.String(name),
+			})
+			return err
+		}); err != nil {
+			return false, errors.Wrapf(err, "failed to create instance profile %s", name)
+		}
+
+		log.Printf("Created the instance profile %s", name)
+		changed = true
+	}
+
+	if !roleInProfile {
+		//An instance profile holds a single role, this fails if it has another one.
+		if err := a.retry(func() error {
+			_, err := c.AddRoleToInstanceProfile(&iam.AddRoleToInstanceProfileInput{
+				InstanceProfileName: aws.String(name),
+				RoleName:            aws.String(name),
+			})
+			return err
+		}); err != nil {
+			return false, errors.Wrapf(err, "failed to add role %s to its instance profile", name)
+		}
+
+		changed = true
+	}
+
+	if !changed {
+		return false, nil
+	}
+
+	if err := c.WaitUntilInstanceProfileExists(&iam.GetInstanceProfileInput{
+		InstanceProfileName: aws.String(name),
+	}); err != nil {
+		return false, errors.Wrapf(err, "failed to wait for instance profile %s", name)
+	}
+
+	a.sleep(instanceProfilePropagationDelay)
+
+	return true, nil
+}
@@ -0,0 +1,146 @@
+package aws
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/stretchr/testify/assert"
+)
+
+//Records the calls made to IAM, on an account holding a profile, a role and its policies.
+type fakeIAM struct {
+	profileRoles []string
+	roleExists   bool
+	attached     []string
+	calls        []string
+}
+
+func (f *fakeIAM) AddRoleToInstanceProfile(input *iam.AddRoleToInstanceProfileInput) (*iam.AddRoleToInstanceProfileOutput, error) {
+	f.calls = append(f.calls, "AddRoleToInstanceProfile")
+	return &iam.AddRoleToInstanceProfileOutput{}, nil
+}
+
+func (f *fakeIAM) AttachRolePolicy(input *iam.AttachRolePolicyInput) (*iam.AttachRolePolicyOutput, error) {
+	f.calls = append(f.calls, "AttachRolePolicy "+aws.StringValue(input.PolicyArn))
+	return &iam.AttachRolePolicyOutput{}, nil
+}
+
+func (f *fakeIAM) CreateInstanceProfile(input *iam.CreateInstanceProfileInput) (*iam.CreateInstanceProfileOutput, error) {
+	f.calls = append(f.calls, "CreateInstanceProfile")
+	return &iam.CreateInstanceProfileOutput{}, nil
+}
+
+func (f *fakeIAM) CreateRole(input *iam.CreateRoleInput) (*iam.CreateRoleOutput, error) {
+	if f.roleExists {
+		return nil, awserr.New(iam.ErrCodeEntityAlreadyExistsException, "role exists", nil)
+	}
+	f.calls = append(f.calls, "CreateRole")
+	return &iam.CreateRoleOutput{}, nil
+}
+
+func (f *fakeIAM) GetInstanceProfile(input *iam.GetInstanceProfileInput) (*iam.GetInstanceProfileOutput, error) {
+	if f.profileRoles == nil {
+		return nil, awserr.New(iam.ErrCodeNoSuchEntityException, "no profile", nil)
+	}
+
+	profile := iam.InstanceProfile{InstanceProfileName: input.InstanceProfileName}
+
+	for _, role := range f.profileRoles {
+		profile.Roles = append(profile.Roles, &iam.Role{RoleName: aws.String(role)})
+	}
+
+	return &iam.GetInstanceProfileOutput{InstanceProfile: &profile}, nil
+}
+
+func (f *fakeIAM) ListAttachedRolePoliciesPages(
+	input *iam.ListAttachedRolePoliciesInput,
+	fn func(*iam.ListAttachedRolePoliciesOutput, bool) bool,
+) error {
+	page := iam.ListAttachedRolePoliciesOutput{}
+
+	for _, policy := range f.attached {
+		page.AttachedPolicies = append(page.AttachedPolicies, &iam.AttachedPolicy{PolicyArn: aws.String(policy)})
+	}
+
+	fn(&page, true)
+
+	return nil
+}
+
+func (f *fakeIAM) WaitUntilInstanceProfileExists(input *iam.GetInstanceProfileInput) error {
+	return nil
+}
+
+var managedPolicies = []string{
+	"arn:aws:iam::aws:policy/AmazonEC2ContainerRegistryReadOnly",
+	"arn:aws:iam::aws:policy/AmazonSSMManagedInstanceCore",
+}
+
+func stubbedIAM(iamFake *fakeIAM) (*awsImpl, *[]time.Duration) {
+	var sleeps []time.Duration
+
+	return &awsImpl{
+		factory: &fakeFactory{iam: iamFake},
+		sleep: func(d time.Duration) {
+			sleeps = append(sleeps, d)
+		},
+	}, &sleeps
+}
+
+func TestInstanceProfileEnsureCreates(t *testing.T) {
+	// Tear up.
+	iamFake := &fakeIAM{}
+	a, sleeps := stubbedIAM(iamFake)
+
+	//Assertions
+	changed, err := a.InstanceProfileEnsure("docker-remote-host", managedPolicies)
+
+	assert.Nil(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, []string{
+		"CreateRole",
+		"AttachRolePolicy arn:aws:iam::aws:policy/AmazonEC2ContainerRegistryReadOnly",
+		"AttachRolePolicy arn:aws:iam::aws:policy/AmazonSSMManagedInstanceCore",
+		"CreateInstanceProfile",
+		"AddRoleToInstanceProfile",
+	}, iamFake.calls)
+	assert.Equal(t, []time.Duration{instanceProfilePropagationDelay}, *sleeps)
+}
+
+func TestInstanceProfileEnsureKeepsCompleteProfile(t *testing.T) {
+	// Tear up.
+	iamFake := &fakeIAM{profileRoles: []string{"docker-remote-host"}, roleExists: true, attached: managedPolicies}
+	a, sleeps := stubbedIAM(iamFake)
+
+	//Assertions
+	changed, err := a.InstanceProfileEnsure("docker-remote-host", managedPolicies)
+
+	assert.Nil(t, err)
+	assert.False(t, changed)
+	assert.Empty(t, iamFake.calls)
+	assert.Empty(t, *sleeps)
+}
+
+func TestInstanceProfileEnsureCompletesProfile(t *testing.T) {
+	// Tear up.
+	iamFake := &fakeIAM{
+		profileRoles: []string{},
+		roleExists:   true,
+		attached:     []string{"arn:aws:iam::aws:policy/AmazonSSMManagedInstanceCore"},
+	}
+	a, sleeps := stubbedIAM(iamFake)
+
+	//Assertions
+	changed, err := a.InstanceProfileEnsure("docker-remote-host", managedPolicies)
+
+	assert.Nil(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, []string{
+		"AttachRolePolicy arn:aws:iam::aws:policy/AmazonEC2ContainerRegistryReadOnly",
+		"AddRoleToInstanceProfile",
+	}, iamFake.calls, "only the missing policy and role should be added")
+	assert.Len(t, *sleeps, 1)
+}
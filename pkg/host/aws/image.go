@@ -0,0 +1,57 @@
+package aws
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/pkg/errors"
+)
+
+//The name of the Amazon Linux 2 AMIs, the provisioning script is made for them.
+const amazonLinuxImageName = "amzn2-ami-hvm-*-gp2"
+
+//Returns the ID of the latest Amazon Linux 2 AMI of an architecture (x86_64 or arm64).
+func (a *awsImpl) ImageLatest(architecture string) (string, error) {
+	c, err := a.factory.EC2()
+
+	if err != nil {
+		return "", err
+	}
+
+	var res *ec2.DescribeImagesOutput
+
+	err = a.retry(func() (err error) {
+		res, err = c.DescribeImages(&ec2.DescribeImagesInput{
+			Owners: []*string{aws.String("amazon")},
+			Filters: []*ec2.Filter{{
+				Name:   aws.String("name"),
+				Values: []*string{aws.String(amazonLinuxImageName)},
+			}, {
+				Name:   aws.String("architecture"),
+				Values: []*string{aws.String(architecture)},
+			}, {
+				Name:   aws.String("state"),
+				Values: []*string{aws.String("available")},
+			}},
+		})
+		return err
+	})
+
+	if err != nil {
+		return "", errors.Wrap(err, "failed to describe the Amazon Linux images")
+	}
+
+	var latest *ec2.Image
+
+	//The creation dates are ISO 8601, in UTC: they sort as strings.
+	for _, image := range res.Images {
+		if latest == nil || aws.StringValue(image.CreationDate) > aws.StringValue(latest.CreationDate) {
+			latest = image
+		}
+	}
+
+	if latest == nil {
+		return "", errors.Errorf("no %s Amazon Linux 2 image found in the region", architecture)
+	}
+
+	return aws.StringValue(latest.ImageId), nil
+}
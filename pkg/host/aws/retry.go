@@ -0,0 +1,42 @@
+package aws
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/knlambert/docker-remote.git/pkg/backoff"
+)
+
+//How many times a call is made before its error is returned.
+const maxAttempts = 8
+
+//Returned by calls made on an instance EC2 does not know yet, right after RunInstances.
+const instanceNotFoundCode = "InvalidInstanceID.NotFound"
+
+//Makes a call until it succeeds, retrying throttling errors and the given error codes, those
+//of resources not visible yet for instance, with a jittered exponential backoff.
+func (a *awsImpl) retry(call func() error, retryableCodes ...string) error {
+	delays := backoff.CreateBackoff(500*time.Millisecond, 10*time.Second)
+	delays.Jitter = 0.5
+
+	for attempt := 1; ; attempt++ {
+		err := call()
+
+		if err == nil || attempt == maxAttempts || !isRetryable(err, retryableCodes) {
+			return err
+		}
+
+		a.sleep(delays.Next())
+	}
+}
+
+func isRetryable(err error, retryableCodes []string) bool {
+	if request.IsErrorThrottle(err) {
+		return true
+	}
+
+	awsErr, ok := err.(awserr.Error)
+
+	return ok && sliceContainsString(retryableCodes, awsErr.Code())
+}
@@ -0,0 +1,90 @@
+package aws
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/pkg/errors"
+)
+
+//The SSM document forwarding a session to the SSH port of an instance.
+const sshSessionDocument = "AWS-StartSSHSession"
+
+//An SSM session opened to an instance, with what the session-manager-plugin needs to attach to it.
+type SessionDescription struct {
+	Id       string
+	Region   string
+	Endpoint string
+	//The StartSession response and request, JSON encoded, as the plugin expects them.
+	Response []byte
+	Request  []byte
+}
+
+//Opens an SSM session streaming the SSH port of an instance.
+func (a *awsImpl) SessionStart(instanceId string, port int) (*SessionDescription, error) {
+	c, err := a.factory.SSM()
+
+	if err != nil {
+		return nil, err
+	}
+
+	region, err := a.factory.Region()
+
+	if err != nil {
+		return nil, err
+	}
+
+	input := ssm.StartSessionInput{
+		Target:       aws.String(instanceId),
+		DocumentName: aws.String(sshSessionDocument),
+		Parameters: map[string][]*string{
+			"portNumber": {aws.String(strconv.Itoa(port))},
+		},
+	}
+
+	res, err := c.StartSession(&input)
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to start an SSM session to %s", instanceId)
+	}
+
+	response, err := json.Marshal(res)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode the SSM session")
+	}
+
+	request, err := json.Marshal(input)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode the SSM session request")
+	}
+
+	return &SessionDescription{
+		Id:       aws.StringValue(res.SessionId),
+		Region:   region,
+		Endpoint: fmt.Sprintf("https://ssm.%s.amazonaws.com", region),
+		Response: response,
+		Request:  request,
+	}, nil
+}
+
+//Terminates an SSM session.
+func (a *awsImpl) SessionTerminate(sessionId string) error {
+	c, err := a.factory.SSM()
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := c.TerminateSession(&ssm.TerminateSessionInput{
+		SessionId: aws.String(sessionId),
+	}); err != nil {
+		return errors.Wrapf(err, "failed to terminate the SSM session %s", sessionId)
+	}
+
+	return nil
+}
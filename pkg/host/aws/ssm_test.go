@@ -0,0 +1,93 @@
+package aws
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSSM struct {
+	started    []*ssm.StartSessionInput
+	terminated []string
+}
+
+func (f *fakeSSM) StartSession(input *ssm.StartSessionInput) (*ssm.StartSessionOutput, error) {
+	f.started = append(f.started, input)
+	return &ssm.StartSessionOutput{
+		SessionId:  aws.String("barney-0123"),
+		StreamUrl:  aws.String("wss://ssmmessages.us-east-1.amazonaws.com/v1/data-channel/barney-0123"),
+		TokenValue: aws.String("token"),
+	}, nil
+}
+
+func (f *fakeSSM) TerminateSession(input *ssm.TerminateSessionInput) (*ssm.TerminateSessionOutput, error) {
+	f.terminated = append(f.terminated, aws.StringValue(input.SessionId))
+	return &ssm.TerminateSessionOutput{SessionId: input.SessionId}, nil
+}
+
+type fakeFactory struct {
+	ec2 EC2
+	ecr ECR
+	iam IAM
+	ssm *fakeSSM
+}
+
+func (f *fakeFactory) EC2() (EC2, error) {
+	return f.ec2, nil
+}
+
+func (f *fakeFactory) ECR() (ECR, error) {
+	return f.ecr, nil
+}
+
+func (f *fakeFactory) IAM() (IAM, error) {
+	return f.iam, nil
+}
+
+func (f *fakeFactory) Region() (string, error) {
+	return "us-east-1", nil
+}
+
+func (f *fakeFactory) RegionRecord() error {
+	return nil
+}
+
+func (f *fakeFactory) SSM() (SSM, error) {
+	return f.ssm, nil
+}
+
+func (f *fakeFactory) STS() (STS, error) {
+	return nil, nil
+}
+
+func TestSessionStart(t *testing.T) {
+	// Tear up.
+	ssmFake := &fakeSSM{}
+	a := awsImpl{factory: &fakeFactory{ssm: ssmFake}}
+
+	//Assertions
+	session, err := a.SessionStart("i-0123", 22)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "barney-0123", session.Id)
+	assert.Equal(t, "https://ssm.us-east-1.amazonaws.com", session.Endpoint)
+	assert.Equal(t, "AWS-StartSSHSession", aws.StringValue(ssmFake.started[0].DocumentName))
+
+	var response map[string]string
+	assert.Nil(t, json.Unmarshal(session.Response, &response))
+	assert.Equal(t, "token", response["TokenValue"])
+
+	var request struct {
+		Target     string
+		Parameters map[string][]string
+	}
+	assert.Nil(t, json.Unmarshal(session.Request, &request))
+	assert.Equal(t, "i-0123", request.Target)
+	assert.Equal(t, []string{"22"}, request.Parameters["portNumber"])
+
+	assert.Nil(t, a.SessionTerminate(session.Id))
+	assert.Equal(t, []string{"barney-0123"}, ssmFake.terminated)
+}
@@ -0,0 +1,51 @@
+package aws
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/pkg/errors"
+)
+
+//The session name of the roles assumed with --role-arn.
+const roleSessionName = "docker-remote"
+
+//Returns the ARN of the identity the AWS calls are made with. The docker-remote session name
+//of the roles assumed with --role-arn is dropped, the other session names tell apart the users
+//of a role (SSO ones for instance) and are kept.
+func (a *awsImpl) CallerIdentity() (string, error) {
+	c, err := a.factory.STS()
+
+	if err != nil {
+		return "", err
+	}
+
+	res, err := c.GetCallerIdentity(&sts.GetCallerIdentityInput{})
+
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get the AWS caller identity")
+	}
+
+	return stableArn(aws.StringValue(res.Arn)), nil
+}
+
+//Turns "arn:aws:sts::<account>:assumed-role/<role>/docker-remote" into
+//"arn:aws:sts::<account>:assumed-role/<role>", returns the other ARNs as is.
+func stableArn(arn string) string {
+	parts := strings.SplitN(arn, ":", 6)
+
+	if len(parts) != 6 || parts[2] != "sts" || !strings.HasPrefix(parts[5], "assumed-role/") {
+		return arn
+	}
+
+	resource := strings.Split(parts[5], "/")
+
+	if len(resource) != 3 || resource[2] != roleSessionName {
+		return arn
+	}
+
+	parts[5] = strings.Join(resource[:2], "/")
+
+	return strings.Join(parts, ":")
+}
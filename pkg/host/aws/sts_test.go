@@ -0,0 +1,22 @@
+package aws
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStableArn(t *testing.T) {
+	//Assertions
+	assert.Equal(
+		t, "arn:aws:sts::123456789012:assumed-role/AWSReservedSSO_Dev/alice@corp",
+		stableArn("arn:aws:sts::123456789012:assumed-role/AWSReservedSSO_Dev/alice@corp"),
+		"the users of a role should keep distinct owners",
+	)
+	assert.Equal(
+		t, "arn:aws:sts::123456789012:assumed-role/ops",
+		stableArn("arn:aws:sts::123456789012:assumed-role/ops/docker-remote"),
+	)
+	assert.Equal(t, "arn:aws:iam::123456789012:user/barney", stableArn("arn:aws:iam::123456789012:user/barney"))
+	assert.Equal(t, "not-an-arn", stableArn("not-an-arn"))
+}
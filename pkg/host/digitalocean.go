@@ -0,0 +1,371 @@
+package host
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/knlambert/docker-remote.git/pkg/host/digitalocean"
+	"github.com/knlambert/docker-remote.git/pkg/output"
+	"github.com/knlambert/docker-remote.git/pkg/provision"
+	"github.com/knlambert/docker-remote.git/pkg/sshutil"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	//The docker context (and SSH alias) of the host.
+	dropletDockerContextName = "docker-remote-digitalocean"
+	//The user of the DigitalOcean images.
+	dropletUser = "root"
+)
+
+//The statuses of a droplet that exists ("archive" ones are being destroyed).
+var dropletStatuses = []string{"new", "active", "off"}
+
+func CreateDigitalOceanHost() DockerHostSystem {
+	return &dropletHostImpl{
+		digitalOcean: digitalocean.Create(),
+		helpers:      CreatePluginHelpers(),
+	}
+}
+
+type dropletHostImpl struct {
+	digitalOcean digitalocean.DigitalOcean
+	helpers      PluginHelpers
+}
+
+type DropletUpParams struct {
+	Region      string
+	Size        string
+	Image       string
+	KeyPairPath string
+	//The public key uploaded to the account, KeyPairPath with a ".pub" suffix or ~/.ssh/id_rsa.pub when empty.
+	PublicKeyPath string
+	Timeout       time.Duration
+	Rollback      bool
+}
+
+func (d *dropletHostImpl) CobraCommand(
+	command Command,
+) *cobra.Command {
+	switch command {
+	case Up:
+		upParams := DropletUpParams{}
+		upCmd := cobra.Command{
+			Use:   string(command),
+			Short: "Create a droplet running docker and use it as the docker host",
+			Run: func(cmd *cobra.Command, args []string) {
+				ctx, cancel := interruptibleContext()
+				defer cancel()
+
+				runAndPrint(cmd, func() (output.Result, error) {
+					return d.Up(ctx, &upParams)
+				})
+			},
+		}
+
+		upCmd.Flags().StringVarP(&upParams.Region, "do-region", "", "nyc3", "The DigitalOcean region")
+		upCmd.Flags().StringVarP(&upParams.Size, "size", "", "s-1vcpu-2gb", "The droplet size")
+		upCmd.Flags().StringVarP(
+			&upParams.Image, "image", "", "docker-20-04",
+			"The image, the Docker marketplace one or a Debian based one like ubuntu-20-04-x64",
+		)
+
+		addPublicKeyFlag(upCmd.Flags(), &upParams.PublicKeyPath, "uploaded to the account")
+
+		addUpFlags(upCmd.Flags(), &upParams.KeyPairPath, &upParams.Timeout, &upParams.Rollback)
+
+		return &upCmd
+	}
+
+	return commonCommand(command, d, "Destroy the docker host")
+}
+
+func (d *dropletHostImpl) Cost() (*CostResult, error) {
+	return nil, errors.New("cost is not supported by the digitalocean driver")
+}
+
+func (d *dropletHostImpl) Extend(params interface{}) (*ExtendResult, error) {
+	return nil, errors.New("extend is not supported by the digitalocean driver")
+}
+
+func (d *dropletHostImpl) Reap(params interface{}) (*ReapResult, error) {
+	return nil, errors.New("reap is not supported by the digitalocean driver")
+}
+
+func (d *dropletHostImpl) Down(params interface{}) (*DownResult, error) {
+	metadata, err := d.helpers.DefaultMetadata()
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to calculate metadata")
+	}
+
+	droplet, err := d.digitalOcean.DropletDescribe(metadata, dropletStatuses)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := DownResult{}
+
+	if droplet != nil {
+		if err := d.digitalOcean.DropletDelete(droplet.ID); err != nil {
+			return nil, errors.Wrapf(err, "failed to shutdown the docker host")
+		}
+
+		result.ID = strconv.Itoa(droplet.ID)
+		result.Terminated = true
+	}
+
+	if err := d.helpers.SSHUtils().SSHAgentRemoveKey(agentKeyComment(dropletDockerContextName)); err != nil {
+		return nil, err
+	}
+
+	if err := d.helpers.SSHUtils().SSHConfigRemove(dropletDockerContextName); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+//Lists every docker host managed by docker-remote, whoever owns it.
+func (d *dropletHostImpl) List() (*ListResult, error) {
+	droplets, err := d.digitalOcean.DropletList(map[string]string{"managed_by": "docker-remote"}, dropletStatuses)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list digitalocean hosts")
+	}
+
+	result := ListResult{Hosts: []HostSummary{}}
+
+	for _, droplet := range droplets {
+		result.Hosts = append(result.Hosts, *summarizeDroplet(droplet))
+	}
+
+	return &result, nil
+}
+
+func (d *dropletHostImpl) PortForward(params interface{}) error {
+	return forwardTo(d.helpers, params, d.activeTarget)
+}
+
+func (d *dropletHostImpl) Shell(params interface{}) error {
+	return shellTo(d.helpers, d.activeTarget)
+}
+
+//Describes the docker host of the current user.
+func (d *dropletHostImpl) Status() (*StatusResult, error) {
+	metadata, err := d.helpers.DefaultMetadata()
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to calculate metadata")
+	}
+
+	droplet, err := d.digitalOcean.DropletDescribe(metadata, dropletStatuses)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to describe digitalocean host")
+	}
+
+	if droplet == nil {
+		return &StatusResult{}, nil
+	}
+
+	return &StatusResult{Host: summarizeDroplet(droplet)}, nil
+}
+
+func summarizeDroplet(droplet *digitalocean.DropletDescription) *HostSummary {
+	return &HostSummary{
+		ID:           strconv.Itoa(droplet.ID),
+		Owner:        droplet.Tags["owner"],
+		State:        droplet.Status,
+		InstanceType: droplet.Size,
+		LaunchTime:   droplet.CreatedAt,
+		Address:      droplet.PublicIp,
+	}
+}
+
+func (d *dropletHostImpl) Up(ctx context.Context, params interface{}) (*UpResult, error) {
+	upParams := params.(*DropletUpParams)
+
+	metadata, err := d.helpers.DefaultMetadata()
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to calculate metadata")
+	}
+
+	var droplet *digitalocean.DropletDescription
+	var createdDropletId int
+
+	completed, err := RunSteps(ctx, []Step{{
+		Name: "droplet creation",
+		Run: func(ctx context.Context) error {
+			droplet, err = d.digitalOcean.DropletDescribe(metadata, dropletStatuses)
+
+			if err != nil {
+				return errors.Wrap(err, "failed to describe digitalocean host")
+			}
+
+			if droplet != nil {
+				if droplet.Status == "off" {
+					return errors.Errorf("droplet %d is off, power it on or destroy it with down", droplet.ID)
+				}
+				return nil
+			}
+
+			publicKey, err := d.helpers.PublicKey(upParams.PublicKeyPath, upParams.KeyPairPath)
+
+			if err != nil {
+				return err
+			}
+
+			name := fmt.Sprintf("docker-remote-%s", digitalocean.TagValue(metadata["owner"]))
+			fingerprint, err := d.digitalOcean.SSHKeyEnsure(name, publicKey)
+
+			if err != nil {
+				return err
+			}
+
+			//The Docker marketplace image already has docker, the script is a no-op there.
+			install, err := provision.DockerInstallScript(
+				&provision.Distribution{ID: "debian", Name: "Debian"}, dropletUser,
+			)
+
+			if err != nil {
+				return err
+			}
+
+			createdDropletId, err = d.digitalOcean.DropletCreate(&digitalocean.DropletCreateParams{
+				Name:     name,
+				Region:   upParams.Region,
+				Size:     upParams.Size,
+				Image:    upParams.Image,
+				UserData: "#!/bin/bash\n" + install,
+				SSHKeys:  []string{fingerprint},
+				Tags:     metadata,
+			})
+
+			if err != nil {
+				return err
+			}
+
+			log.Printf("Droplet %d created", createdDropletId)
+			droplet = &digitalocean.DropletDescription{ID: createdDropletId}
+
+			return nil
+		},
+		Rollback: func() error {
+			if createdDropletId == 0 {
+				return nil
+			}
+			return d.digitalOcean.DropletDelete(createdDropletId)
+		},
+		Resource: func() string {
+			if createdDropletId == 0 {
+				return ""
+			}
+			return fmt.Sprintf("droplet %d", createdDropletId)
+		},
+	}, agentKeyStep(d.helpers, dropletDockerContextName, upParams.KeyPairPath), {
+		Name: "readiness",
+		Run: func(ctx context.Context) error {
+			if err := createReadinessWaiter(upParams.Timeout).Wait(
+				ctx, d.readinessStages(metadata, &droplet),
+			); err != nil {
+				return err
+			}
+
+			log.Println("Droplet is ready !")
+
+			return nil
+		},
+	}, dockerContextStep(d.helpers, dropletDockerContextName, func() (*sshutil.Target, error) {
+		return dropletTarget(droplet), nil
+	}, nil)})
+
+	if err != nil {
+		HandleStepsFailure(completed, upParams.Rollback)
+		return nil, err
+	}
+
+	return &UpResult{
+		ID:            strconv.Itoa(droplet.ID),
+		Address:       droplet.PublicIp,
+		DockerContext: dropletDockerContextName,
+		Created:       createdDropletId != 0,
+	}, nil
+}
+
+//Stages a freshly created droplet goes through before docker can be used on it.
+func (d *dropletHostImpl) readinessStages(
+	metadata map[string]string,
+	droplet **digitalocean.DropletDescription,
+) []ReadinessStage {
+	sshUtils := d.helpers.SSHUtils()
+
+	return []ReadinessStage{{
+		Name: "droplet active",
+		Probe: func(ctx context.Context) error {
+			ready, err := d.digitalOcean.DropletIsReady((*droplet).ID)
+
+			if err != nil {
+				return err
+			} else if !ready {
+				return errors.New("droplet is not active yet")
+			}
+
+			*droplet, err = d.digitalOcean.DropletDescribe(metadata, []string{"active"})
+
+			if err == nil && (*droplet == nil || (*droplet).PublicIp == "") {
+				return errors.New("droplet has no public IP yet")
+			}
+
+			return err
+		},
+	}, {
+		Name: "SSH",
+		Probe: func(ctx context.Context) error {
+			_, err := sshUtils.SSHRun(dropletTarget(*droplet), "true")
+			return err
+		},
+	}, {
+		Name: "cloud-init",
+		Probe: func(ctx context.Context) error {
+			_, err := sshUtils.SSHRun(dropletTarget(*droplet), "test -f /var/lib/cloud/instance/boot-finished")
+			return err
+		},
+	}, {
+		Name: "docker daemon",
+		Probe: func(ctx context.Context) error {
+			return sshUtils.DockerPing(dropletTarget(*droplet))
+		},
+	}}
+}
+
+func dropletTarget(droplet *digitalocean.DropletDescription) *sshutil.Target {
+	return &sshutil.Target{Endpoint: sshutil.Endpoint{User: dropletUser, Host: droplet.PublicIp}}
+}
+
+//Returns the droplet of the current user, fails if it is not active.
+func (d *dropletHostImpl) activeTarget() (*sshutil.Target, error) {
+	metadata, err := d.helpers.DefaultMetadata()
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to calculate metadata")
+	}
+
+	droplet, err := d.digitalOcean.DropletDescribe(metadata, []string{"active"})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if droplet == nil {
+		return nil, errors.Errorf("Please create the host first")
+	}
+
+	return dropletTarget(droplet), nil
+}
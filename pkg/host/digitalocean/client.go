@@ -0,0 +1,235 @@
+package digitalocean
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+//The DigitalOcean REST API, the subset docker-remote uses.
+type Client interface {
+	DropletsCreate(request *DropletCreateRequest) (*Droplet, error)
+	DropletsDelete(id int) error
+	//Returns a droplet, nil if it does not exist.
+	DropletsGet(id int) (*Droplet, error)
+	DropletsListByTag(tag string) ([]*Droplet, error)
+	KeysCreate(name string, publicKey string) (*Key, error)
+	//Returns an SSH key of the account by its fingerprint, nil if it does not exist.
+	KeysGet(fingerprint string) (*Key, error)
+}
+
+type DropletCreateRequest struct {
+	Name     string   `json:"name"`
+	Region   string   `json:"region"`
+	Size     string   `json:"size"`
+	Image    string   `json:"image"`
+	SSHKeys  []string `json:"ssh_keys,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	UserData string   `json:"user_data,omitempty"`
+}
+
+type Droplet struct {
+	ID        int      `json:"id"`
+	Name      string   `json:"name"`
+	Status    string   `json:"status"`
+	SizeSlug  string   `json:"size_slug"`
+	CreatedAt string   `json:"created_at"`
+	Tags      []string `json:"tags"`
+	Region    struct {
+		Slug string `json:"slug"`
+	} `json:"region"`
+	Networks struct {
+		V4 []struct {
+			IPAddress string `json:"ip_address"`
+			Type      string `json:"type"`
+		} `json:"v4"`
+	} `json:"networks"`
+}
+
+type Key struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+//The API endpoint.
+const apiURL = "https://api.digitalocean.com/v2"
+
+func createRESTClient(baseURL func() string, token func() (string, error)) Client {
+	return &restClient{
+		baseURL: baseURL,
+		token:   token,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+type restClient struct {
+	baseURL func() string
+	//Returns the API token of the requests.
+	token  func() (string, error)
+	client *http.Client
+}
+
+func (c *restClient) DropletsCreate(request *DropletCreateRequest) (*Droplet, error) {
+	var res struct {
+		Droplet *Droplet `json:"droplet"`
+	}
+	return res.Droplet, c.do(http.MethodPost, "/droplets", request, &res)
+}
+
+func (c *restClient) DropletsDelete(id int) error {
+	return c.do(http.MethodDelete, fmt.Sprintf("/droplets/%d", id), nil, nil)
+}
+
+func (c *restClient) DropletsGet(id int) (*Droplet, error) {
+	var res struct {
+		Droplet *Droplet `json:"droplet"`
+	}
+
+	if err := c.do(http.MethodGet, fmt.Sprintf("/droplets/%d", id), nil, &res); err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return res.Droplet, nil
+}
+
+func (c *restClient) DropletsListByTag(tag string) ([]*Droplet, error) {
+	var droplets []*Droplet
+
+	for page := 1; ; page++ {
+		query := url.Values{}
+		query.Set("tag_name", tag)
+		query.Set("page", fmt.Sprint(page))
+		query.Set("per_page", "200")
+
+		var res struct {
+			Droplets []*Droplet `json:"droplets"`
+			Links    struct {
+				Pages struct {
+					Next string `json:"next"`
+				} `json:"pages"`
+			} `json:"links"`
+		}
+
+		if err := c.do(http.MethodGet, "/droplets?"+query.Encode(), nil, &res); err != nil {
+			return nil, err
+		}
+
+		droplets = append(droplets, res.Droplets...)
+
+		if res.Links.Pages.Next == "" {
+			return droplets, nil
+		}
+	}
+}
+
+func (c *restClient) KeysCreate(name string, publicKey string) (*Key, error) {
+	var res struct {
+		SSHKey *Key `json:"ssh_key"`
+	}
+
+	request := map[string]string{
+		"name":       name,
+		"public_key": strings.TrimSpace(publicKey),
+	}
+
+	return res.SSHKey, c.do(http.MethodPost, "/account/keys", request, &res)
+}
+
+func (c *restClient) KeysGet(fingerprint string) (*Key, error) {
+	var res struct {
+		SSHKey *Key `json:"ssh_key"`
+	}
+
+	if err := c.do(http.MethodGet, "/account/keys/"+fingerprint, nil, &res); err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return res.SSHKey, nil
+}
+
+//An error answered by the API.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("DigitalOcean API answered %d: %s", e.StatusCode, e.Message)
+}
+
+func isNotFound(err error) bool {
+	apiErr, ok := errors.Cause(err).(*APIError)
+	return ok && apiErr.StatusCode == http.StatusNotFound
+}
+
+//Sends an authenticated JSON request, decodes the response into out.
+func (c *restClient) do(method string, path string, in interface{}, out interface{}) error {
+	var body bytes.Buffer
+
+	if in != nil {
+		if err := json.NewEncoder(&body).Encode(in); err != nil {
+			return errors.Wrap(err, "failed to encode the request")
+		}
+	}
+
+	req, err := http.NewRequest(method, c.baseURL()+path, &body)
+
+	if err != nil {
+		return errors.Wrap(err, "failed to create the request")
+	}
+
+	token, err := c.token()
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := c.client.Do(req)
+
+	if err != nil {
+		return errors.Wrapf(err, "failed to call %s %s", method, path)
+	}
+
+	defer res.Body.Close()
+
+	content, err := ioutil.ReadAll(res.Body)
+
+	if err != nil {
+		return errors.Wrap(err, "failed to read the response")
+	}
+
+	if res.StatusCode >= 300 {
+		var failure struct {
+			Message string `json:"message"`
+		}
+
+		_ = json.Unmarshal(content, &failure)
+
+		return &APIError{StatusCode: res.StatusCode, Message: failure.Message}
+	}
+
+	if out != nil && len(content) > 0 {
+		if err := json.Unmarshal(content, out); err != nil {
+			return errors.Wrap(err, "failed to decode the response")
+		}
+	}
+
+	return nil
+}
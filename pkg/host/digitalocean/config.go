@@ -0,0 +1,19 @@
+package digitalocean
+
+import (
+	"github.com/spf13/pflag"
+)
+
+//Options of the DigitalOcean client, the API token comes from the environment.
+type SessionConfig struct {
+	//Overrides the DigitalOcean API endpoint, for proxies and emulators.
+	EndpointURL string
+}
+
+//The session options given on the command line, see AddFlags.
+var CommandLineConfig = SessionConfig{}
+
+//Adds the flags filling CommandLineConfig.
+func AddFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(&CommandLineConfig.EndpointURL, "do-endpoint-url", "", "", "Overrides the DigitalOcean API endpoint URL")
+}
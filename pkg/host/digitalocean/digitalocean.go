@@ -0,0 +1,253 @@
+package digitalocean
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+)
+
+//The environment variable holding the API token, as doctl uses it.
+const accessTokenEnv = "DIGITALOCEAN_ACCESS_TOKEN"
+
+type DigitalOcean interface {
+	//Creates a droplet, returns its ID.
+	DropletCreate(params *DropletCreateParams) (int, error)
+	DropletDelete(id int) error
+	//Returns the first droplet having all the tags, in one of the statuses, nil if there is none.
+	DropletDescribe(tags map[string]string, statuses []string) (*DropletDescription, error)
+	DropletIsReady(id int) (bool, error)
+	DropletList(tags map[string]string, statuses []string) ([]*DropletDescription, error)
+	//Uploads a public key to the account if it's not there yet, returns its fingerprint.
+	SSHKeyEnsure(name string, publicKey string) (string, error)
+}
+
+func Create() DigitalOcean {
+	return &digitalOceanImpl{
+		client: createRESTClient(func() string {
+			//Read on use, the flags are parsed after the commands are built.
+			if CommandLineConfig.EndpointURL != "" {
+				return strings.TrimSuffix(CommandLineConfig.EndpointURL, "/")
+			}
+			return apiURL
+		}, accessToken),
+	}
+}
+
+type digitalOceanImpl struct {
+	client Client
+}
+
+func accessToken() (string, error) {
+	if token := os.Getenv(accessTokenEnv); token != "" {
+		return token, nil
+	}
+	return "", errors.Errorf("no DigitalOcean token, please set %s", accessTokenEnv)
+}
+
+type DropletCreateParams struct {
+	Name   string
+	Region string
+	Size   string
+	//An image slug, "docker-20-04" (the Docker marketplace image) or "ubuntu-20-04-x64" for instance.
+	Image    string
+	UserData string
+	//The fingerprints of the SSH keys allowed to log in.
+	SSHKeys []string
+	Tags    map[string]string
+}
+
+func (d *digitalOceanImpl) DropletCreate(params *DropletCreateParams) (int, error) {
+	droplet, err := d.client.DropletsCreate(&DropletCreateRequest{
+		Name:     params.Name,
+		Region:   params.Region,
+		Size:     params.Size,
+		Image:    params.Image,
+		SSHKeys:  params.SSHKeys,
+		Tags:     mapToTags(params.Tags),
+		UserData: params.UserData,
+	})
+
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to kick a droplet in DigitalOcean")
+	}
+
+	return droplet.ID, nil
+}
+
+func (d *digitalOceanImpl) DropletDelete(id int) error {
+	return errors.Wrapf(d.client.DropletsDelete(id), "failed to delete droplet %d", id)
+}
+
+type DropletDescription struct {
+	ID        int
+	Name      string
+	PublicIp  string
+	PrivateIp string
+	Status    string
+	Size      string
+	Region    string
+	CreatedAt *time.Time
+	Tags      map[string]string
+}
+
+func (d *digitalOceanImpl) DropletDescribe(
+	tags map[string]string, statuses []string,
+) (*DropletDescription, error) {
+	droplets, err := d.DropletList(tags, statuses)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(droplets) > 0 {
+		return droplets[0], nil
+	}
+
+	return nil, nil
+}
+
+//Returns the droplets having all the tags, in one of the statuses.
+func (d *digitalOceanImpl) DropletList(
+	tags map[string]string, statuses []string,
+) ([]*DropletDescription, error) {
+	wanted := mapToTags(tags)
+
+	if len(wanted) == 0 {
+		return nil, errors.New("droplets can only be listed by tags")
+	}
+
+	//The API filters on one tag, the others are checked here.
+	res, err := d.client.DropletsListByTag(wanted[0])
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list the droplets")
+	}
+
+	var droplets []*DropletDescription
+
+	for _, droplet := range res {
+		description := describeDroplet(droplet)
+
+		if containsAll(description.Tags, tags) && contains(statuses, description.Status) {
+			droplets = append(droplets, description)
+		}
+	}
+
+	return droplets, nil
+}
+
+func describeDroplet(droplet *Droplet) *DropletDescription {
+	description := DropletDescription{
+		ID:     droplet.ID,
+		Name:   droplet.Name,
+		Status: droplet.Status,
+		Size:   droplet.SizeSlug,
+		Region: droplet.Region.Slug,
+		Tags:   tagsToMap(droplet.Tags),
+	}
+
+	if createdAt, err := time.Parse(time.RFC3339, droplet.CreatedAt); err == nil {
+		description.CreatedAt = &createdAt
+	}
+
+	for _, network := range droplet.Networks.V4 {
+		switch network.Type {
+		case "public":
+			description.PublicIp = network.IPAddress
+		case "private":
+			description.PrivateIp = network.IPAddress
+		}
+	}
+
+	return &description
+}
+
+func (d *digitalOceanImpl) DropletIsReady(id int) (bool, error) {
+	droplet, err := d.client.DropletsGet(id)
+
+	if err != nil {
+		return false, errors.Wrapf(err, "can't describe droplet %d", id)
+	}
+
+	return droplet != nil && droplet.Status == "active", nil
+}
+
+func (d *digitalOceanImpl) SSHKeyEnsure(name string, publicKey string) (string, error) {
+	parsed, _, _, _, err := ssh.ParseAuthorizedKey([]byte(publicKey))
+
+	if err != nil {
+		return "", errors.Wrap(err, "invalid public key")
+	}
+
+	fingerprint := ssh.FingerprintLegacyMD5(parsed)
+	key, err := d.client.KeysGet(fingerprint)
+
+	if err != nil {
+		return "", errors.Wrap(err, "failed to look the SSH key up")
+	}
+
+	if key == nil {
+		if key, err = d.client.KeysCreate(name, publicKey); err != nil {
+			return "", errors.Wrap(err, "failed to upload the SSH key")
+		}
+	}
+
+	return key.Fingerprint, nil
+}
+
+//Droplet tags are plain strings, "key:value" ones hold the metadata.
+func mapToTags(tags map[string]string) []string {
+	var result []string
+
+	for key, value := range tags {
+		result = append(result, fmt.Sprintf("%s:%s", key, TagValue(value)))
+	}
+
+	//A stable order, the first tag is the one filtered by the API.
+	sort.Strings(result)
+
+	return result
+}
+
+func tagsToMap(tags []string) map[string]string {
+	result := map[string]string{}
+
+	for _, tag := range tags {
+		if parts := strings.SplitN(tag, ":", 2); len(parts) == 2 {
+			result[parts[0]] = parts[1]
+		}
+	}
+
+	return result
+}
+
+func containsAll(tags map[string]string, wanted map[string]string) bool {
+	for key, value := range wanted {
+		if tags[key] != TagValue(value) {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+var tagInvalidCharacters = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+//Turns a value into a valid tag value: letters, digits, "_" and "-".
+func TagValue(value string) string {
+	return tagInvalidCharacters.ReplaceAllString(value, "_")
+}
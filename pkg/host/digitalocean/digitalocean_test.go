@@ -0,0 +1,137 @@
+package digitalocean
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const publicKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIHb8uBTq3DAk0LyajIlb8eujsDiMWgQVMxYuxtK9c0l6 barney@laptop"
+
+func stubbedDigitalOcean(handler http.HandlerFunc) (*digitalOceanImpl, *httptest.Server) {
+	server := httptest.NewServer(handler)
+
+	return &digitalOceanImpl{
+		client: createRESTClient(func() string { return server.URL }, func() (string, error) { return "token", nil }),
+	}, server
+}
+
+func TestDropletCreate(t *testing.T) {
+	// Tear up.
+	var received DropletCreateRequest
+
+	d, server := stubbedDigitalOcean(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/droplets", r.URL.Path)
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&received))
+
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte(`{"droplet": {"id": 3164444, "status": "new"}}`))
+	})
+	defer server.Close()
+
+	//Assertions
+	id, err := d.DropletCreate(&DropletCreateParams{
+		Name:     "docker-remote-barney",
+		Region:   "nyc3",
+		Size:     "s-1vcpu-2gb",
+		Image:    "docker-20-04",
+		UserData: "#!/bin/bash\n",
+		SSHKeys:  []string{"3b:16:bf:e4:8b:00:8b:b8:59:8c:a9:d3:f0:19:45:fa"},
+		Tags:     map[string]string{"owner": "barney.rubble", "managed_by": "docker-remote"},
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3164444, id)
+	assert.Equal(t, []string{"managed_by:docker-remote", "owner:barney_rubble"}, received.Tags)
+	assert.Equal(t, "docker-20-04", received.Image)
+}
+
+func TestDropletDescribe(t *testing.T) {
+	// Tear up.
+	d, server := stubbedDigitalOcean(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "managed_by:docker-remote", r.URL.Query().Get("tag_name"))
+
+		if r.URL.Query().Get("page") == "1" {
+			_, _ = w.Write([]byte(`{
+				"droplets": [{"id": 1, "status": "active", "tags": ["managed_by:docker-remote", "owner:fred"]}],
+				"links": {"pages": {"next": "https://api.digitalocean.com/v2/droplets?page=2"}}
+			}`))
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"droplets": [{
+			"id": 2,
+			"name": "docker-remote-barney",
+			"status": "active",
+			"size_slug": "s-1vcpu-2gb",
+			"created_at": "2020-11-02T16:12:41Z",
+			"region": {"slug": "nyc3"},
+			"tags": ["managed_by:docker-remote", "owner:barney"],
+			"networks": {"v4": [
+				{"ip_address": "10.128.0.2", "type": "private"},
+				{"ip_address": "104.236.32.182", "type": "public"}
+			]}
+		}], "links": {}}`))
+	})
+	defer server.Close()
+
+	//Assertions
+	droplet, err := d.DropletDescribe(
+		map[string]string{"owner": "barney", "managed_by": "docker-remote"},
+		[]string{"new", "active"},
+	)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, droplet.ID)
+	assert.Equal(t, "104.236.32.182", droplet.PublicIp)
+	assert.Equal(t, "10.128.0.2", droplet.PrivateIp)
+	assert.Equal(t, "barney", droplet.Tags["owner"])
+	assert.NotNil(t, droplet.CreatedAt)
+}
+
+func TestSSHKeyEnsureUploadsMissingKey(t *testing.T) {
+	// Tear up.
+	var uploaded map[string]string
+
+	d, server := stubbedDigitalOcean(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"id": "not_found", "message": "The resource you were accessing could not be found."}`))
+		case http.MethodPost:
+			assert.Nil(t, json.NewDecoder(r.Body).Decode(&uploaded))
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"ssh_key": {"id": 512190, "fingerprint": "created"}}`))
+		}
+	})
+	defer server.Close()
+
+	//Assertions
+	fingerprint, err := d.SSHKeyEnsure("docker-remote-barney", publicKey+"\n")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "created", fingerprint)
+	assert.Equal(t, map[string]string{"name": "docker-remote-barney", "public_key": publicKey}, uploaded)
+}
+
+func TestSSHKeyEnsureReusesKey(t *testing.T) {
+	// Tear up.
+	d, server := stubbedDigitalOcean(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Contains(t, r.URL.Path, "/account/keys/")
+
+		_, _ = w.Write([]byte(`{"ssh_key": {"id": 512190, "fingerprint": "existing"}}`))
+	})
+	defer server.Close()
+
+	//Assertions
+	fingerprint, err := d.SSHKeyEnsure("docker-remote-barney", publicKey)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "existing", fingerprint)
+}
@@ -1,12 +1,17 @@
 package host
 
 import (
+	"context"
 	"fmt"
 	"github.com/knlambert/docker-remote.git/pkg/host/aws"
+	"github.com/knlambert/docker-remote.git/pkg/output"
+	"github.com/knlambert/docker-remote.git/pkg/pricing"
+	"github.com/knlambert/docker-remote.git/pkg/provision"
+	"github.com/knlambert/docker-remote.git/pkg/sshutil"
+	"github.com/knlambert/docker-remote.git/pkg/std/runtime"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"log"
-	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -16,12 +21,104 @@ func CreateEC2Host() DockerHostSystem {
 	return &ec2HostImpl{
 		aws:     aws.Create(),
 		helpers: CreatePluginHelpers(),
+		pricing: pricing.CreatePricing(),
+		runtime: runtime.CreateRuntime(),
 	}
 }
 
+//The docker context (and SSH alias) of the host.
+const dockerContextName = "docker-remote-ec2"
+
 type ec2HostImpl struct {
 	aws     aws.AWS
 	helpers PluginHelpers
+	pricing pricing.Pricing
+	runtime runtime.Runtime
+}
+
+//Returns the tags identifying the hosts of the current user, owned by their AWS identity.
+func (e *ec2HostImpl) metadata() (map[string]string, error) {
+	metadata, err := e.helpers.DefaultMetadata()
+
+	if err != nil {
+		return nil, err
+	}
+
+	owner, err := e.aws.CallerIdentity()
+
+	if err != nil {
+		return nil, err
+	}
+
+	metadata["owner"] = owner
+
+	return metadata, nil
+}
+
+//Returns the host of the current user. Hosts created before they were owned by an AWS identity
+//carry the display name of the local user as owner: they are looked up with it too, and retagged
+//on first sight.
+func (e *ec2HostImpl) describeOwned(
+	metadata map[string]string,
+	states []string,
+) (*aws.InstanceDescription, error) {
+	instance, err := e.aws.InstanceDescribe(metadata, states)
+
+	if err != nil || instance != nil {
+		return instance, err
+	}
+
+	legacy, err := e.helpers.LegacyMetadata()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if legacy == nil || legacy["owner"] == metadata["owner"] {
+		return nil, nil
+	}
+
+	instance, err = e.aws.InstanceDescribe(legacy, states)
+
+	if err != nil || instance == nil {
+		return instance, err
+	}
+
+	log.Printf("Host %s is owned by %s, retagging it with the owner %s", *instance.Id, legacy["owner"], metadata["owner"])
+
+	if err := e.aws.InstanceTag(*instance.Id, map[string]string{"owner": metadata["owner"]}); err != nil {
+		return nil, errors.Wrap(err, "failed to retag the host with its AWS owner")
+	}
+
+	instance.Tags["owner"] = metadata["owner"]
+
+	return instance, nil
+}
+
+//Checks the shutdown behavior is one EC2 knows, empty leaving EC2's default.
+func validateShutdownBehavior(behavior string) error {
+	switch behavior {
+	case "stop", "terminate", "":
+		return nil
+	}
+	return errors.Errorf("unknown shutdown behavior '%s', use stop or terminate", behavior)
+}
+
+//Checks user supplied tags do not override the ones docker-remote relies on.
+func validateUserTags(tags map[string]string, metadata map[string]string) error {
+	for key := range tags {
+		if _, ok := metadata[key]; ok {
+			return errors.Errorf("tag '%s' is reserved by docker-remote", key)
+		}
+
+		for _, reserved := range []string{expiresAtTag, idleTimeoutTag, sshJumpTag, sshUserTag, transportTag} {
+			if key == reserved {
+				return errors.Errorf("tag '%s' is reserved by docker-remote", key)
+			}
+		}
+	}
+
+	return nil
 }
 
 type ForwardParams struct {
@@ -29,119 +126,450 @@ type ForwardParams struct {
 	KeyPairPath string
 	RemotePort  uint
 	RemoteAddr  string
+	//Called each time the forwarding state changes, the events are dropped when nil.
+	OnEvent func(event *PortForwardEvent)
+}
+
+//Reports a forwarding event to OnEvent, if any.
+func (p *ForwardParams) notify(event sshutil.ForwardEvent) {
+	if p.OnEvent != nil {
+		p.OnEvent(portForwardEvent(p, event))
+	}
 }
 
 func (e *ec2HostImpl) PortForward(params interface{}) error {
 	fwdParams := params.(*ForwardParams)
 
-	metadata, err := e.helpers.DefaultMetadata()
+	metadata, err := e.metadata()
 
 	if err != nil {
 		return errors.Wrap(err, "failed to calculate metadata")
 	}
 
-	instance, err := e.aws.InstanceDescribe(metadata, []string{"running", "pending"})
+	instance, err := e.describeOwned(metadata, []string{"running", "pending"})
+
+	if err != nil {
+		return err
+	}
+
+	if instance == nil {
+		return errors.Errorf("Please create the host first")
+	}
+
+	target, err := instanceTarget(instance)
 
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Port-forwarding %d -> %d\n", fwdParams.LocalPort, fwdParams.RemotePort)
 	return e.helpers.SSHUtils().LocalPortForward(
 		fwdParams.LocalPort,
 		fwdParams.RemoteAddr,
 		fwdParams.RemotePort,
-		*instance.PublicIp,
-		"ec2-user",
+		target,
+		fwdParams.notify,
 	)
 }
 
-func (e *ec2HostImpl) Down() error {
-	metadata, err := e.helpers.DefaultMetadata()
+func (e *ec2HostImpl) Down(params interface{}) (*DownResult, error) {
+	downParams := params.(*DownParams)
+
+	metadata, err := e.metadata()
 
 	if err != nil {
-		return errors.Wrap(err, "failed to calculate metadata")
+		return nil, errors.Wrap(err, "failed to calculate metadata")
 	}
 
-	instance, err := e.aws.InstanceDescribe(metadata, []string{"running", "pending"})
+	instance, err := e.describeOwned(metadata, []string{"running", "pending", "stopping", "stopped"})
 
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	result := DownResult{}
+
+	if !downParams.KeepIP {
+		releasedIP, err := e.releaseOwnedAddress(metadata)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if releasedIP != nil {
+			result.ReleasedIP = *releasedIP
+		}
 	}
 
 	if instance != nil {
 		if err := e.aws.InstanceTerminate(*instance.Id); err != nil {
-			return errors.Wrapf(err, "failed to shutdown the docker host")
+			return nil, errors.Wrapf(err, "failed to shutdown the docker host")
 		}
 
-		log.Println("Shutdown signal sent")
-	} else {
-		log.Println("Docker host is already down")
+		result.ID = *instance.Id
+		result.Terminated = true
+	}
+
+	if err := e.helpers.SSHUtils().SSHAgentRemoveKey(agentKeyComment(dockerContextName)); err != nil {
+		return nil, err
+	}
+
+	if err := e.helpers.SSHUtils().SSHConfigRemove(dockerContextName); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+//Lists every docker host managed by docker-remote, whoever owns it.
+func (e *ec2HostImpl) List() (*ListResult, error) {
+	instances, err := e.aws.InstanceList(
+		map[string]string{"managed_by": "docker-remote"},
+		[]string{"pending", "running", "stopping", "stopped"},
+	)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list ec2 hosts")
 	}
 
-	return e.helpers.SSHUtils().SSHAgentRemoveKey()
+	result := ListResult{Hosts: []HostSummary{}}
+
+	for _, instance := range instances {
+		result.Hosts = append(result.Hosts, *e.summarizeInstance(instance))
+	}
+
+	return &result, nil
+}
+
+//Describes the docker host of the current user.
+func (e *ec2HostImpl) Status() (*StatusResult, error) {
+	metadata, err := e.metadata()
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to calculate metadata")
+	}
+
+	instance, err := e.describeOwned(metadata, []string{"pending", "running", "stopping", "stopped"})
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to describe ec2 host")
+	}
+
+	if instance == nil {
+		return &StatusResult{}, nil
+	}
+
+	summary := e.summarizeInstance(instance)
+	summary.Idle = e.idleStatus(instance)
+
+	return &StatusResult{Host: summary}, nil
+}
+
+//Describes an instance, its cost is left unknown when it cannot be priced.
+func (e *ec2HostImpl) summarizeInstance(instance *aws.InstanceDescription) *HostSummary {
+	cost, err := e.instanceCost(instance)
+
+	if err != nil {
+		log.Printf("Failed to price %s: %s", *instance.Id, err)
+	}
+
+	summary := HostSummary{
+		ID:           *instance.Id,
+		Owner:        instance.Tags["owner"],
+		State:        instance.State,
+		InstanceType: instance.InstanceType,
+		LaunchTime:   instance.LaunchTime,
+		Address:      instanceAddress(instance),
+		ExpiresAt:    instanceExpiry(instance),
+		Cost:         cost,
+	}
+
+	return &summary
+}
+
+func portForwardEvent(params *ForwardParams, event sshutil.ForwardEvent) *PortForwardEvent {
+	result := PortForwardEvent{
+		Event:      PortForwardConnection,
+		LocalPort:  params.LocalPort,
+		RemoteAddr: params.RemoteAddr,
+		RemotePort: params.RemotePort,
+		Client:     event.Client,
+	}
+
+	if event.Err != nil {
+		result.Event = PortForwardError
+		result.Error = event.Err.Error()
+	} else if event.Client == "" {
+		result.Event = PortForwardListening
+	}
+
+	return &result
 }
 
 type UpParams struct {
-	AMI           string
-	InstanceType  string
+	//The AMI of the instance, the latest Amazon Linux 2 of the architecture when empty.
+	AMI string
+	//The architecture of the instance: amd64 or arm64.
+	Arch string
+	//Registers QEMU on the instance to build images for the other architectures.
+	Binfmt bool
+	//The instance types to try, in order of preference.
+	InstanceTypes []string
 	KeyPairPath   string
 	KeyName       string
 	SecurityGroup string
+	UserData      provision.Params
+	PrintUserData bool
+	Timeout       time.Duration
+	Rollback      bool
+	TTL           time.Duration
+	IdleTimeout   int
+	//What the idle watchdog shutdown does to the instance: stop or terminate.
+	ShutdownBehavior string
+	//The subnets to try for each instance type, in order.
+	SubnetIds []string
+	//Creates the instance without a public IP.
+	Private bool
+	//Hosts to hop through to reach the instance, "user@host[:port]".
+	Jumps []string
+	//How SSH reaches the instance: ssh or ssm.
+	Transport string
+	//The instance profile given to the instance, required by the ssm transport.
+	IamInstanceProfile string
+	//Creates (if needed) and gives the instance a role allowed to pull from ECR and use SSM.
+	ManagedRole bool
+	//Gives the instance a stable Elastic IP.
+	ElasticIP bool
+	//Additional tags given to the instance, its volumes and network interfaces.
+	Tags map[string]string
 }
 
 func (e *ec2HostImpl) CobraCommand(
 	command Command,
 ) *cobra.Command {
 	switch command {
+	case Adopt:
+		adoptParams := AdoptParams{}
+		adoptCmd := cobra.Command{
+			Use:   string(command),
+			Short: "Tag an existing instance as the docker host of the current user",
+			Run: func(cmd *cobra.Command, args []string) {
+				runAndPrint(cmd, func() (output.Result, error) {
+					return e.Adopt(&adoptParams)
+				})
+			},
+		}
+
+		adoptCmd.Flags().StringVarP(
+			&adoptParams.InstanceId, "instance-id", "", "", "The ID of the instance to adopt",
+		)
+
+		adoptCmd.Flags().StringVarP(
+			&adoptParams.KeyPairPath, "key-pair-path", "", "",
+			"The path to the PEM key file to use for connection",
+		)
+
+		adoptCmd.Flags().StringVarP(
+			&adoptParams.SSHUser, "ssh-user", "", ec2User, "The SSH user of the instance",
+		)
+
+		adoptCmd.Flags().StringSliceVarP(
+			&adoptParams.Jumps, "jump", "", []string{},
+			"A jump host to reach the instance through, 'user@host[:port]' (can be repeated or comma separated)",
+		)
+
+		_ = adoptCmd.MarkFlagRequired("instance-id")
+
+		return &adoptCmd
+	case Attach:
+		attachParams := AttachParams{}
+		attachCmd := cobra.Command{
+			Use:   string(command),
+			Short: "Register a docker context for an existing instance",
+			Run: func(cmd *cobra.Command, args []string) {
+				runAndPrint(cmd, func() (output.Result, error) {
+					return e.Attach(&attachParams)
+				})
+			},
+		}
+
+		attachCmd.Flags().StringVarP(
+			&attachParams.InstanceId, "instance-id", "", "", "The ID of the instance to attach to",
+		)
+
+		attachCmd.Flags().StringVarP(
+			&attachParams.Name, "name", "", "", "The Name tag of the instance to attach to",
+		)
+
+		attachCmd.Flags().StringVarP(
+			&attachParams.Owner, "owner", "", "", "The owner of the managed docker host to attach to",
+		)
+
+		attachCmd.Flags().StringVarP(
+			&attachParams.KeyPairPath, "key-pair-path", "", "",
+			"The path to the PEM key file to use for connection",
+		)
+
+		attachCmd.Flags().StringVarP(
+			&attachParams.Context, "context", "", "",
+			fmt.Sprintf("The docker context to register (default %s-<name or instance id>)", dockerContextName),
+		)
+
+		attachCmd.Flags().StringVarP(
+			&attachParams.SSHUser, "ssh-user", "", "", "The SSH user of the instance (default ec2-user)",
+		)
+
+		attachCmd.Flags().StringSliceVarP(
+			&attachParams.Jumps, "jump", "", []string{},
+			"A jump host to reach the instance through, 'user@host[:port]' (can be repeated or comma separated)",
+		)
+
+		return &attachCmd
 	case Down:
-		return &cobra.Command{
+		downParams := DownParams{}
+		downCmd := cobra.Command{
 			Use:   string(command),
 			Short: "Cleanup a docker host",
 			Run: func(cmd *cobra.Command, args []string) {
-				if err := e.Down(); err != nil {
-					log.Fatal(err)
-				}
+				runAndPrint(cmd, func() (output.Result, error) {
+					return e.Down(&downParams)
+				})
 			},
 		}
-	case PortForward:
-		fwdParams := ForwardParams{}
-		fwdCmd := cobra.Command{
+
+		downCmd.Flags().BoolVarP(
+			&downParams.KeepIP, "keep-ip", "", false, "Keep the Elastic IP of the host for the next up",
+		)
+
+		return &downCmd
+	case Cost:
+		return &cobra.Command{
 			Use:   string(command),
-			Args:  cobra.MinimumNArgs(1),
-			Short: "Forward the connection from the remote host",
-			PreRunE: func(cmd *cobra.Command, args []string) error {
-				r := regexp.MustCompile(`(\d{2,5}):(\d{2,5})`)
+			Short: "Summarize the hourly rate and session cost of the managed docker hosts per owner",
+			Run: func(cmd *cobra.Command, args []string) {
+				runAndPrint(cmd, func() (output.Result, error) {
+					return e.Cost()
+				})
+			},
+		}
+	case EcrLogin:
+		loginParams := EcrLoginParams{}
+		loginCmd := cobra.Command{
+			Use:   string(command),
+			Short: "Give the docker daemon of the host access to ECR registries",
+			Run: func(cmd *cobra.Command, args []string) {
+				runAndPrint(cmd, func() (output.Result, error) {
+					return e.EcrLogin(&loginParams)
+				})
+			},
+		}
 
-				if len(r.FindStringSubmatch(args[0])) == 0 {
-					return errors.Errorf("parameter must be 'localPort:remotePort, example: '8080:80'")
-				}
+		loginCmd.Flags().StringSliceVarP(
+			&loginParams.Registries, "registry", "", []string{},
+			"The ID (AWS account) of a registry to log in, the current account one by default (can be repeated)",
+		)
 
-				return nil
+		loginCmd.Flags().BoolVarP(
+			&loginParams.CredentialHelper, "credential-helper", "", false,
+			"Configure the ECR credential helper on the host (needs an instance role allowed to pull, see up --managed-role), "+
+				"instead of logging in with a 12 hours token fetched locally",
+		)
+
+		return &loginCmd
+	case Extend:
+		extendParams := ExtendParams{}
+		extendCmd := cobra.Command{
+			Use:   string(command),
+			Short: "Push the expiry of the docker host",
+			Run: func(cmd *cobra.Command, args []string) {
+				runAndPrint(cmd, func() (output.Result, error) {
+					return e.Extend(&extendParams)
+				})
 			},
+		}
+
+		extendCmd.Flags().DurationVarP(
+			&extendParams.TTL, "ttl", "", 0, "The new time-to-live of the host, from now (example: '4h')",
+		)
+
+		_ = extendCmd.MarkFlagRequired("ttl")
+
+		return &extendCmd
+	case List:
+		return &cobra.Command{
+			Use:   string(command),
+			Short: "List the docker hosts managed by docker-remote",
 			Run: func(cmd *cobra.Command, args []string) {
-				ports := strings.Split(args[0], ":")
+				runAndPrint(cmd, func() (output.Result, error) {
+					return e.List()
+				})
+			},
+		}
+	case Status:
+		return &cobra.Command{
+			Use:   string(command),
+			Short: "Describe the docker host",
+			Run: func(cmd *cobra.Command, args []string) {
+				runAndPrint(cmd, func() (output.Result, error) {
+					return e.Status()
+				})
+			},
+		}
+	case PortForward:
+		return portForwardCommand(command, e.PortForward)
 
-				var converted, _ = strconv.ParseUint(ports[0], 10, 0)
-				fwdParams.LocalPort = uint(converted)
+	case Reap:
+		reapParams := ReapParams{}
+		reapCmd := cobra.Command{
+			Use:   string(command),
+			Short: "Terminate every managed docker host past its expiry",
+			Run: func(cmd *cobra.Command, args []string) {
+				runAndPrint(cmd, func() (output.Result, error) {
+					return e.Reap(&reapParams)
+				})
+			},
+		}
+
+		reapCmd.Flags().BoolVarP(
+			&reapParams.DryRun, "dry-run", "", false, "Only list the expired hosts",
+		)
+
+		return &reapCmd
+	case SSMProxy:
+		return &cobra.Command{
+			Use:    fmt.Sprintf("%s <instance-id> <port>", command),
+			Short:  "Stream the SSH port of an instance through SSM, used as an SSH ProxyCommand",
+			Args:   cobra.ExactArgs(2),
+			Hidden: true,
+			Run: func(cmd *cobra.Command, args []string) {
+				port, err := strconv.Atoi(args[1])
 
-				converted, _ = strconv.ParseUint(ports[1], 10, 0)
-				fwdParams.RemotePort = uint(converted)
+				if err != nil {
+					log.Fatalf("invalid port '%s'", args[1])
+				}
 
-				if err := e.PortForward(&fwdParams); err != nil {
+				if err := e.SSMProxy(&SSMProxyParams{InstanceId: args[0], Port: port}); err != nil {
 					log.Fatal(err)
 				}
 			},
 		}
+	case RegistrySync:
+		syncParams := RegistrySyncParams{}
+		syncCmd := cobra.Command{
+			Use:   string(command),
+			Short: "Copy the local registry credentials to the docker host",
+			Run: func(cmd *cobra.Command, args []string) {
+				runAndPrint(cmd, func() (output.Result, error) {
+					return e.RegistrySync(&syncParams)
+				})
+			},
+		}
 
-		fwdCmd.Flags().StringVarP(
-			&fwdParams.RemoteAddr,
-			"remote-addr", "", "127.0.0.1",
-			"The address to forward from on the remote machine",
+		syncCmd.Flags().StringSliceVarP(
+			&syncParams.Registries, "registry", "", []string{},
+			"A registry to copy the credentials of, all the known ones by default (can be repeated)",
 		)
 
-		return &fwdCmd
-
+		return &syncCmd
 	case Shell:
 		shellParams := ShellParams{}
 		shellCmd := cobra.Command{
@@ -157,14 +585,31 @@ func (e *ec2HostImpl) CobraCommand(
 		return &shellCmd
 	case Up:
 		upParams := UpParams{}
+		var userDataFormat string
 		upCmd := cobra.Command{
 			Use:   string(command),
 			Short: "Creates the docker host",
 			Long:  "Creates the docker host",
 			Run: func(cmd *cobra.Command, args []string) {
-				if err := e.Up(&upParams); err != nil {
-					log.Fatal(err)
+				upParams.UserData.Format = provision.Format(userDataFormat)
+
+				if upParams.PrintUserData {
+					userData, err := e.userData(&upParams)
+
+					if err != nil {
+						log.Fatal(err)
+					}
+
+					fmt.Print(userData)
+					return
 				}
+
+				ctx, cancel := interruptibleContext()
+				defer cancel()
+
+				runAndPrint(cmd, func() (output.Result, error) {
+					return e.Up(ctx, &upParams)
+				})
 			},
 		}
 
@@ -175,11 +620,24 @@ func (e *ec2HostImpl) CobraCommand(
 		)
 
 		upCmd.Flags().StringVarP(
-			&upParams.AMI, "ami", "", "ami-0c2f25c1f66a1ff4d", "The AMI to use",
+			&upParams.AMI, "ami", "", "",
+			"The AMI to use (the latest Amazon Linux 2 of the architecture by default)",
 		)
 
 		upCmd.Flags().StringVarP(
-			&upParams.InstanceType, "instance-type", "", "t2.micro", "The instance type to use",
+			&upParams.Arch, "arch", "", "amd64",
+			"The architecture of the VM: amd64 or arm64 (Graviton)",
+		)
+
+		upCmd.Flags().BoolVarP(
+			&upParams.Binfmt, "binfmt", "", false,
+			"Register QEMU on the VM to build images for the other architectures (docker buildx --platform)",
+		)
+
+		upCmd.Flags().StringSliceVarP(
+			&upParams.InstanceTypes, "instance-type", "", []string{},
+			"The instance types to use, the next ones are tried when one has no capacity (comma separated, "+
+				"t2.micro or t4g.micro by default depending on the architecture)",
 		)
 
 		upCmd.Flags().StringVarP(
@@ -190,6 +648,76 @@ func (e *ec2HostImpl) CobraCommand(
 			&upParams.SecurityGroup, "sg-id", "", "", "A security group ID for the VM",
 		)
 
+		addUserDataFlags(upCmd.Flags(), &upParams.UserData, &userDataFormat, &upParams.PrintUserData)
+
+		upCmd.Flags().DurationVarP(
+			&upParams.Timeout, "timeout", "", 10*time.Minute,
+			"How long to wait for the host to be ready",
+		)
+
+		upCmd.Flags().BoolVarP(
+			&upParams.Rollback, "rollback", "", false,
+			"Remove the resources created so far if the command fails or is interrupted, without asking",
+		)
+
+		upCmd.Flags().DurationVarP(
+			&upParams.TTL, "ttl", "", 0,
+			"Tag the host to expire after this duration, see the reap command (example: '8h')",
+		)
+
+		upCmd.Flags().IntVarP(
+			&upParams.IdleTimeout, "idle-timeout", "", 0,
+			"Minutes without containers, docker or SSH sessions after which the host shuts down (0 to disable)",
+		)
+
+		upCmd.Flags().StringSliceVarP(
+			&upParams.SubnetIds, "subnet-id", "", []string{},
+			"The subnets to create the VM in, tried in order for each instance type (comma separated)",
+		)
+
+		upCmd.Flags().BoolVarP(
+			&upParams.Private, "private", "", false,
+			"Do not give a public IP to the VM, use --jump to reach it",
+		)
+
+		upCmd.Flags().StringSliceVarP(
+			&upParams.Jumps, "jump", "", []string{},
+			"A jump host to reach the VM through, 'user@host[:port]' (can be repeated or comma separated)",
+		)
+
+		upCmd.Flags().StringToStringVarP(
+			&upParams.Tags, "tag", "", map[string]string{},
+			"A tag given to the VM, its volumes and network interfaces, example: 'cost-center=42' (can be repeated)",
+		)
+
+		upCmd.Flags().BoolVarP(
+			&upParams.ManagedRole, "managed-role", "", false,
+			fmt.Sprintf(
+				"Give the VM the %s instance profile, created if needed, allowed to pull from ECR and use SSM",
+				managedInstanceProfile,
+			),
+		)
+
+		upCmd.Flags().BoolVarP(
+			&upParams.ElasticIP, "elastic-ip", "", false,
+			"Give the VM an Elastic IP, kept across stop and start (released by down unless --keep-ip)",
+		)
+
+		upCmd.Flags().StringVarP(
+			&upParams.Transport, "transport", "", SSHTransport,
+			fmt.Sprintf("How SSH reaches the VM: ssh (port 22) or ssm (Session Manager, needs %s)", sessionManagerPlugin),
+		)
+
+		upCmd.Flags().StringVarP(
+			&upParams.IamInstanceProfile, "iam-instance-profile", "", "",
+			fmt.Sprintf("The instance profile of the VM (%s by default with the ssm transport)", defaultSSMInstanceProfile),
+		)
+
+		upCmd.Flags().StringVarP(
+			&upParams.ShutdownBehavior, "shutdown-behavior", "", "stop",
+			"What an idle shutdown does to the instance (stop, terminate)",
+		)
+
 		_ = upCmd.MarkFlagRequired("key-name")
 		_ = upCmd.MarkFlagRequired("sg-id")
 
@@ -204,13 +732,13 @@ type ShellParams struct {
 }
 
 func (e *ec2HostImpl) Shell(params interface{}) error {
-	metadata, err := e.helpers.DefaultMetadata()
+	metadata, err := e.metadata()
 
 	if err != nil {
 		return errors.Wrap(err, "failed to calculate metadata")
 	}
 
-	instance, err := e.aws.InstanceDescribe(metadata, []string{"running", "pending"})
+	instance, err := e.describeOwned(metadata, []string{"running", "pending"})
 
 	if err != nil {
 		return err
@@ -220,87 +748,395 @@ func (e *ec2HostImpl) Shell(params interface{}) error {
 		return errors.Errorf("Please create the host first")
 	}
 
-	if err := e.helpers.SSHUtils().SSHConnection(
-		*instance.PublicIp,
-		"ec2-user",
-	); err != nil {
+	warnIfExpiring(instance)
+	e.warnIfIdle(instance)
+
+	target, err := instanceTarget(instance)
+
+	if err != nil {
+		return err
+	}
+
+	if err := e.helpers.SSHUtils().SSHConnection(target); err != nil {
 		return err
 	}
 
 	return nil
 }
-func (e *ec2HostImpl) Up(params interface{}) error {
-	upParams := params.(*UpParams)
 
-	metadata, err := e.helpers.DefaultMetadata()
+//Renders the provisioning script of the instance.
+func (e *ec2HostImpl) userData(upParams *UpParams) (string, error) {
+	upParams.UserData.User = ec2User
+	upParams.UserData.IdleTimeout = upParams.IdleTimeout
+	upParams.UserData.Binfmt = upParams.Binfmt
+
+	userData, err := e.helpers.Provision().Render(&upParams.UserData)
 
 	if err != nil {
-		return errors.Wrap(err, "failed to calculate metadata")
+		return "", errors.Wrap(err, "failed to render the provisioning script")
+	}
+
+	return userData, nil
+}
+
+func (e *ec2HostImpl) Up(ctx context.Context, params interface{}) (*UpResult, error) {
+	upParams := params.(*UpParams)
+
+	if err := validateShutdownBehavior(upParams.ShutdownBehavior); err != nil {
+		return nil, err
 	}
 
-	instance, err := e.aws.InstanceDescribe(metadata, []string{"running", "pending"})
+	userData, err := e.userData(upParams)
 
 	if err != nil {
-		return errors.Wrap(err, "failed to describe ec2 host")
+		return nil, err
 	}
 
-	var instanceId *string
+	jumps, err := sshutil.ParseJumps(upParams.Jumps)
 
-	if instance == nil {
-		instanceId, err = e.aws.InstanceCreate(
-			upParams.AMI,
-			upParams.InstanceType,
-			upParams.KeyName,
-			upParams.SecurityGroup,
-			metadata,
-		)
+	if err != nil {
+		return nil, err
+	}
 
-		if err != nil {
-			return err
+	if upParams.ManagedRole {
+		if upParams.IamInstanceProfile != "" {
+			return nil, errors.New("--managed-role can't be used with --iam-instance-profile")
 		}
 
-	} else {
-		instanceId = instance.Id
+		upParams.IamInstanceProfile = managedInstanceProfile
 	}
 
-	var ready = false
+	if upParams.ElasticIP && upParams.Private {
+		return nil, errors.New("--elastic-ip can't be used with --private")
+	}
 
-	for {
-		ready, err = e.aws.InstanceIsReady(*instanceId)
+	if upParams.Arch == "" {
+		upParams.Arch = "amd64"
+	}
 
-		if err != nil {
-			return err
+	if err := resolveArch(upParams); err != nil {
+		return nil, err
+	}
+
+	switch upParams.Transport {
+	case SSHTransport, "":
+	case SSMTransport:
+		if len(jumps) > 0 {
+			return nil, errors.New("--jump can't be used with the ssm transport")
 		}
 
-		if ready {
-			break
+		if upParams.IamInstanceProfile == "" {
+			upParams.IamInstanceProfile = defaultSSMInstanceProfile
 		}
+	default:
+		return nil, errors.Errorf("unknown transport '%s'", upParams.Transport)
+	}
 
-		log.Println("Waiting for instance to be ready ...")
+	metadata, err := e.metadata()
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to calculate metadata")
+	}
 
-		time.Sleep(5 * time.Second)
+	if err := validateUserTags(upParams.Tags, metadata); err != nil {
+		return nil, err
 	}
 
-	log.Println("Instance is ready !")
+	var instance *aws.InstanceDescription
+	var createdInstanceId *string
+	var created *aws.InstanceCreated
+
+	completed, err := RunSteps(ctx, []Step{{
+		Name: "instance creation",
+		Run: func(ctx context.Context) error {
+			instance, err = e.describeOwned(metadata, []string{"running", "pending", "stopping", "stopped"})
+
+			if err != nil {
+				return errors.Wrap(err, "failed to describe ec2 host")
+			}
+
+			//A stopping instance can't be started, nor ignored without creating a second host.
+			if instance != nil && instance.State == "stopping" {
+				if err := e.waitStopped(ctx, instance, upParams.Timeout); err != nil {
+					return err
+				}
+			}
+
+			tags := map[string]string{}
+
+			if upParams.TTL > 0 {
+				tags = expiryTags(time.Now().Add(upParams.TTL))
+			}
+
+			if len(jumps) > 0 {
+				tags[sshJumpTag] = (&sshutil.Target{Jumps: jumps}).ProxyJump()
+			}
+
+			for key, value := range upParams.Tags {
+				tags[key] = value
+			}
+
+			if instance != nil {
+				if instance.State == "stopped" {
+					log.Printf("Starting stopped instance %s", *instance.Id)
+
+					if err := e.aws.InstanceStart(*instance.Id); err != nil {
+						return err
+					}
+				}
+
+				if len(tags) > 0 {
+					if err := e.aws.InstanceTag(*instance.Id, tags); err != nil {
+						return err
+					}
+
+					for key, value := range tags {
+						instance.Tags[key] = value
+					}
+				}
+
+				if upParams.TTL == 0 {
+					warnIfExpiring(instance)
+				}
+				return nil
+			}
+
+			resourceTags := map[string]string{}
+
+			for key, value := range upParams.Tags {
+				resourceTags[key] = value
+			}
+
+			for key, value := range metadata {
+				tags[key] = value
+				resourceTags[key] = value
+			}
+
+			if upParams.Transport == SSMTransport {
+				tags[transportTag] = SSMTransport
+			}
+
+			if upParams.IdleTimeout > 0 {
+				tags[idleTimeoutTag] = strconv.Itoa(upParams.IdleTimeout)
+			}
+
+			if upParams.ManagedRole {
+				if _, err := e.aws.InstanceProfileEnsure(managedInstanceProfile, managedRolePolicies); err != nil {
+					return err
+				}
+			}
+
+			if upParams.AMI == "" {
+				if upParams.AMI, err = e.aws.ImageLatest(ec2Architectures[upParams.Arch]); err != nil {
+					return err
+				}
+
+				log.Printf("Using AMI %s", upParams.AMI)
+			}
+
+			created, err = e.aws.InstanceCreate(&aws.InstanceCreateParams{
+				AMI:              upParams.AMI,
+				InstanceTypes:    upParams.InstanceTypes,
+				KeyName:          upParams.KeyName,
+				SecurityGroup:    upParams.SecurityGroup,
+				UserData:         userData,
+				Tags:             tags,
+				ShutdownBehavior: upParams.ShutdownBehavior,
+				SubnetIds:        upParams.SubnetIds,
+				Private:          upParams.Private,
+
+				IamInstanceProfile: upParams.IamInstanceProfile,
+				ResourceTags:       resourceTags,
+			})
+
+			if err != nil {
+				return err
+			}
+
+			createdInstanceId = &created.Id
+			log.Printf("Instance %s created (%s)", *createdInstanceId, created)
+			instance = &aws.InstanceDescription{Id: createdInstanceId, Tags: tags}
+
+			return nil
+		},
+		Rollback: func() error {
+			if createdInstanceId == nil {
+				return nil
+			}
+			return e.aws.InstanceTerminate(*createdInstanceId)
+		},
+		Resource: func() string {
+			if createdInstanceId == nil {
+				return ""
+			}
+			return fmt.Sprintf("EC2 instance %s", *createdInstanceId)
+		},
+	}, agentKeyStep(e.helpers, dockerContextName, upParams.KeyPairPath), {
+		Name: "readiness",
+		Run: func(ctx context.Context) error {
+			if err := createReadinessWaiter(upParams.Timeout).Wait(
+				ctx, e.readinessStages(*instance.Id, metadata, &instance),
+			); err != nil {
+				return err
+			}
+
+			log.Println("Instance is ready !")
+
+			return nil
+		},
+	}, e.elasticIPStep(upParams, metadata, &instance), {
+		Name: "binfmt",
+		Run: func(ctx context.Context) error {
+			//A created instance installs the unit from its provisioning script.
+			if !upParams.Binfmt || createdInstanceId != nil {
+				return nil
+			}
+
+			target, err := instanceTarget(instance)
+
+			if err != nil {
+				return err
+			}
+
+			if out, err := e.helpers.SSHUtils().SSHRunInput(
+				target, binfmtInstallCommand(), []byte(provision.BinfmtUnit),
+			); err != nil {
+				return errors.Wrapf(err, "failed to register QEMU: %s", strings.TrimSpace(string(out)))
+			}
 
-	instance, err = e.aws.InstanceDescribe(metadata, []string{"running"})
+			return nil
+		},
+	}, dockerContextStep(e.helpers, dockerContextName, func() (*sshutil.Target, error) {
+		return instanceTarget(instance)
+	}, func() map[string]string {
+		return map[string]string{architectureMetadata: hostArch(upParams, instance)}
+	})})
 
 	if err != nil {
-		return err
+		HandleStepsFailure(completed, upParams.Rollback)
+		return nil, err
 	}
 
-	log.Printf("Instance IP: %s", *instance.PublicIp)
+	if err := e.aws.RegionRecord(); err != nil {
+		log.Printf("Failed to record the region of the host: %s", err)
+	}
 
-	dockerContextName := "docker-remote-ec2"
+	result := UpResult{
+		ID:            *instance.Id,
+		Address:       instanceAddress(instance),
+		DockerContext: dockerContextName,
+		Created:       createdInstanceId != nil,
+		Architecture:  hostArch(upParams, instance),
+	}
 
-	err = e.helpers.RegisterToDocker(
-		dockerContextName,
-		fmt.Sprintf("ssh://ec2-user@%s", *instance.PublicIp),
-	)
+	e.warnIfForeignArch(result.Architecture)
 
-	if err == nil {
-		log.Printf("Docker context %s set !", dockerContextName)
+	if created != nil {
+		result.InstanceType = created.InstanceType
+		result.SubnetId = created.SubnetId
 	}
 
-	return e.helpers.SSHUtils().SSHAgentAddKey(upParams.KeyPairPath)
+	return &result, nil
+}
+
+//Waits for a stopping instance to be stopped, so that it can be started again.
+func (e *ec2HostImpl) waitStopped(ctx context.Context, instance *aws.InstanceDescription, timeout time.Duration) error {
+	log.Printf("Instance %s is stopping, waiting for it to stop before starting it", *instance.Id)
+
+	return createReadinessWaiter(timeout).Wait(ctx, []ReadinessStage{{
+		Name: "instance stopped",
+		Probe: func(ctx context.Context) error {
+			current, err := e.aws.InstanceGet(*instance.Id)
+
+			if err != nil {
+				return err
+			} else if current == nil {
+				return errors.Errorf("instance %s disappeared while stopping", *instance.Id)
+			} else if current.State != "stopped" {
+				return errors.Errorf("instance is %s", current.State)
+			}
+
+			instance.State = current.State
+
+			return nil
+		},
+	}})
+}
+
+//Stages a freshly started instance goes through before docker can be used on it.
+func (e *ec2HostImpl) readinessStages(
+	instanceId string,
+	metadata map[string]string,
+	instance **aws.InstanceDescription,
+) []ReadinessStage {
+	sshUtils := e.helpers.SSHUtils()
+
+	return []ReadinessStage{{
+		Name: "instance running",
+		Probe: func(ctx context.Context) error {
+			ready, err := e.aws.InstanceIsReady(instanceId)
+
+			if err != nil {
+				return err
+			} else if !ready {
+				return errors.New("instance is not running yet")
+			}
+
+			*instance, err = e.aws.InstanceDescribe(metadata, []string{"running"})
+
+			if err == nil && *instance == nil {
+				return errors.New("instance is not listed as running yet")
+			}
+
+			return err
+		},
+	}, {
+		Name: "EC2 status checks",
+		Probe: func(ctx context.Context) error {
+			passed, err := e.aws.InstanceStatusChecksPassed(instanceId)
+
+			if err != nil {
+				return err
+			} else if !passed {
+				return errors.New("status checks are not ok yet")
+			}
+
+			return nil
+		},
+	}, {
+		Name: "SSH",
+		Probe: func(ctx context.Context) error {
+			target, err := instanceTarget(*instance)
+
+			if err != nil {
+				return err
+			}
+
+			_, err = sshUtils.SSHRun(target, "true")
+			return err
+		},
+	}, {
+		Name: "cloud-init",
+		Probe: func(ctx context.Context) error {
+			target, err := instanceTarget(*instance)
+
+			if err != nil {
+				return err
+			}
+
+			_, err = sshUtils.SSHRun(target, "test -f /var/lib/cloud/instance/boot-finished")
+			return err
+		},
+	}, {
+		Name: "docker daemon",
+		Probe: func(ctx context.Context) error {
+			target, err := instanceTarget(*instance)
+
+			if err != nil {
+				return err
+			}
+
+			return sshUtils.DockerPing(target)
+		},
+	}}
 }
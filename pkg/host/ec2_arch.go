@@ -0,0 +1,101 @@
+package host
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/knlambert/docker-remote.git/pkg/host/aws"
+	"github.com/knlambert/docker-remote.git/pkg/provision"
+	"github.com/pkg/errors"
+)
+
+//The docker context metadata field holding the architecture of the host.
+const architectureMetadata = "Architecture"
+
+//The EC2 name of the architectures, by docker name.
+var ec2Architectures = map[string]string{
+	"amd64": "x86_64",
+	"arm64": "arm64",
+}
+
+//The instance type used when none is given, by architecture.
+var defaultInstanceTypes = map[string]string{
+	"amd64": "t2.micro",
+	"arm64": "t4g.micro",
+}
+
+//The Graviton families have a "g" after their generation (t4g, m6gd, c7gn), but a1.
+var gravitonFamily = regexp.MustCompile(`^(a1|[a-z]+[0-9]+[a-z]*g[a-z]*)$`)
+
+//Returns the architecture of an instance type, by its family.
+func instanceTypeArch(instanceType string) string {
+	if gravitonFamily.MatchString(strings.SplitN(instanceType, ".", 2)[0]) {
+		return "arm64"
+	}
+	return "amd64"
+}
+
+//Returns the architecture of an instance in the docker naming, empty if unknown.
+func instanceArch(instance *aws.InstanceDescription) string {
+	for arch, name := range ec2Architectures {
+		if name == instance.Architecture {
+			return arch
+		}
+	}
+	return ""
+}
+
+//Checks the architecture requested and that the instance types match it, uses the default
+//instance type of the architecture when none is given.
+func resolveArch(upParams *UpParams) error {
+	if _, ok := ec2Architectures[upParams.Arch]; !ok {
+		return errors.Errorf("unknown architecture '%s', use amd64 or arm64", upParams.Arch)
+	}
+
+	if len(upParams.InstanceTypes) == 0 {
+		upParams.InstanceTypes = []string{defaultInstanceTypes[upParams.Arch]}
+	}
+
+	for _, instanceType := range upParams.InstanceTypes {
+		if arch := instanceTypeArch(instanceType); arch != upParams.Arch {
+			return errors.Errorf(
+				"instance type %s is %s, use an %s one with --arch %s (%s for instance)",
+				instanceType, arch, upParams.Arch, upParams.Arch, defaultInstanceTypes[upParams.Arch],
+			)
+		}
+	}
+
+	return nil
+}
+
+//Warns when the images built on the host do not run on this machine without emulation.
+func (e *ec2HostImpl) warnIfForeignArch(arch string) {
+	if local := e.runtime.CurrentArch(); arch != "" && arch != local {
+		log.Printf(
+			"Warning: the host is %s while this machine is %s, the images it builds need --platform "+
+				"linux/%s to run here",
+			arch, local, local,
+		)
+	}
+}
+
+//Installs the QEMU registration unit, given on the standard input, on a host created without it.
+func binfmtInstallCommand() string {
+	commands := []string{fmt.Sprintf("sudo tee %s > /dev/null", provision.BinfmtUnitPath)}
+
+	for _, command := range provision.BinfmtCommands {
+		commands = append(commands, "sudo "+command)
+	}
+
+	return strings.Join(commands, " && ")
+}
+
+//Returns the architecture of the host, as reported by EC2 or else as requested.
+func hostArch(upParams *UpParams, instance *aws.InstanceDescription) string {
+	if arch := instanceArch(instance); arch != "" {
+		return arch
+	}
+	return upParams.Arch
+}
@@ -0,0 +1,65 @@
+package host
+
+import (
+	"testing"
+
+	"github.com/knlambert/docker-remote.git/pkg/pricing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstanceTypeArch(t *testing.T) {
+	//Assertions
+	for instanceType, arch := range map[string]string{
+		"t2.micro":    "amd64",
+		"t4g.micro":   "arm64",
+		"m6gd.large":  "arm64",
+		"c6gn.xlarge": "arm64",
+		"x2gd.medium": "arm64",
+		"a1.large":    "arm64",
+		"g5g.xlarge":  "arm64",
+		"g4dn.xlarge": "amd64",
+		"m5a.large":   "amd64",
+	} {
+		assert.Equal(t, arch, instanceTypeArch(instanceType), instanceType)
+	}
+}
+
+func TestResolveArch(t *testing.T) {
+	// Tear up.
+	upParams := &UpParams{Arch: "arm64"}
+
+	//Assertions
+	assert.Nil(t, resolveArch(upParams))
+	assert.Equal(t, []string{"t4g.micro"}, upParams.InstanceTypes)
+
+	assert.EqualError(
+		t, resolveArch(&UpParams{Arch: "arm64", InstanceTypes: []string{"t4g.small", "t3.small"}}),
+		"instance type t3.small is amd64, use an arm64 one with --arch arm64 (t4g.micro for instance)",
+	)
+
+	assert.EqualError(t, resolveArch(&UpParams{Arch: "386"}), "unknown architecture '386', use amd64 or arm64")
+}
+
+func TestBinfmtInstallCommand(t *testing.T) {
+	//Assertions
+	assert.Equal(
+		t,
+		"sudo tee /etc/systemd/system/docker-remote-binfmt.service > /dev/null && sudo systemctl daemon-reload && "+
+			"sudo systemctl enable --now docker-remote-binfmt.service",
+		binfmtInstallCommand(),
+	)
+}
+
+func TestDefaultInstanceTypesArePriced(t *testing.T) {
+	// Tear up.
+	table, err := pricing.EmbeddedTable()
+
+	//Assertions
+	assert.Nil(t, err)
+
+	for region, prices := range table.Regions {
+		for arch, instanceType := range defaultInstanceTypes {
+			assert.Contains(t, prices.Instances, instanceType, "the %s default should be priced in %s", arch, region)
+		}
+	}
+}
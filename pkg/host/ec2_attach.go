@@ -0,0 +1,252 @@
+package host
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/knlambert/docker-remote.git/pkg/host/aws"
+	"github.com/knlambert/docker-remote.git/pkg/sshutil"
+	"github.com/pkg/errors"
+)
+
+//The tag holding the SSH user of an instance, ec2-user when missing.
+const sshUserTag = "ssh_user"
+
+type AttachParams struct {
+	InstanceId string
+	//The Name tag of the instance.
+	Name string
+	//The owner tag of a managed instance.
+	Owner       string
+	KeyPairPath string
+	//The docker context (and SSH alias) to register, derived from the instance when empty.
+	Context string
+	SSHUser string
+	Jumps   []string
+}
+
+type AdoptParams struct {
+	InstanceId  string
+	KeyPairPath string
+	SSHUser     string
+	Jumps       []string
+}
+
+//Registers a docker context for an existing instance, without creating anything.
+func (e *ec2HostImpl) Attach(params interface{}) (*AttachResult, error) {
+	attachParams := params.(*AttachParams)
+
+	instance, err := e.findAttachable(attachParams)
+
+	if err != nil {
+		return nil, err
+	}
+
+	contextName := attachParams.Context
+
+	if contextName == "" {
+		suffix := *instance.Id
+
+		if name := instance.Tags["Name"]; name != "" {
+			suffix = name
+		}
+
+		contextName = fmt.Sprintf("%s-%s", dockerContextName, suffix)
+	}
+
+	return e.attach(instance, contextName, attachParams.KeyPairPath, attachParams.SSHUser, attachParams.Jumps)
+}
+
+//Puts the docker-remote tags on an unmanaged instance, making it the host of the current user.
+func (e *ec2HostImpl) Adopt(params interface{}) (*AttachResult, error) {
+	adoptParams := params.(*AdoptParams)
+
+	metadata, err := e.metadata()
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to calculate metadata")
+	}
+
+	instance, err := e.aws.InstanceGet(adoptParams.InstanceId)
+
+	if err != nil {
+		return nil, err
+	} else if instance == nil {
+		return nil, errors.Errorf("instance %s not found", adoptParams.InstanceId)
+	}
+
+	if instance.Tags["managed_by"] == metadata["managed_by"] && instance.Tags["owner"] != metadata["owner"] {
+		return nil, errors.Errorf("instance %s is already managed for %s", *instance.Id, instance.Tags["owner"])
+	}
+
+	current, err := e.describeOwned(metadata, []string{"pending", "running", "stopping", "stopped"})
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to describe ec2 host")
+	}
+
+	if current != nil && *current.Id != *instance.Id {
+		return nil, errors.Errorf("you already have a host (%s), run 'down' first", *current.Id)
+	}
+
+	tags := map[string]string{}
+
+	for key, value := range metadata {
+		tags[key] = value
+	}
+
+	if len(adoptParams.Jumps) > 0 {
+		jumps, err := sshutil.ParseJumps(adoptParams.Jumps)
+
+		if err != nil {
+			return nil, err
+		}
+
+		tags[sshJumpTag] = (&sshutil.Target{Jumps: jumps}).ProxyJump()
+	}
+
+	if adoptParams.SSHUser != "" && adoptParams.SSHUser != ec2User {
+		tags[sshUserTag] = adoptParams.SSHUser
+	}
+
+	if err := e.aws.InstanceTag(*instance.Id, tags); err != nil {
+		return nil, err
+	}
+
+	for key, value := range tags {
+		instance.Tags[key] = value
+	}
+
+	result, err := e.attach(instance, dockerContextName, adoptParams.KeyPairPath, "", nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result.Adopted = true
+
+	if err := e.aws.RegionRecord(); err != nil {
+		log.Printf("Failed to record the region of the host: %s", err)
+	}
+
+	return result, nil
+}
+
+//Finds the running instance designated by its ID, or by its name and owner tags.
+func (e *ec2HostImpl) findAttachable(params *AttachParams) (*aws.InstanceDescription, error) {
+	var instance *aws.InstanceDescription
+
+	if params.InstanceId != "" {
+		var err error
+
+		if instance, err = e.aws.InstanceGet(params.InstanceId); err != nil {
+			return nil, err
+		} else if instance == nil {
+			return nil, errors.Errorf("instance %s not found", params.InstanceId)
+		}
+	} else {
+		tags := map[string]string{}
+
+		if params.Name != "" {
+			tags["Name"] = params.Name
+		}
+
+		if params.Owner != "" {
+			tags["owner"] = params.Owner
+			tags["managed_by"] = "docker-remote"
+		}
+
+		if len(tags) == 0 {
+			return nil, errors.New("either --instance-id, --name or --owner is required")
+		}
+
+		instances, err := e.aws.InstanceList(tags, []string{"pending", "running", "stopping", "stopped"})
+
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list ec2 hosts")
+		}
+
+		switch len(instances) {
+		case 0:
+			return nil, errors.New("no instance matches the given name and owner")
+		case 1:
+			instance = instances[0]
+		default:
+			var ids []string
+
+			for _, match := range instances {
+				ids = append(ids, *match.Id)
+			}
+
+			return nil, errors.Errorf(
+				"several instances match the given name and owner (%s), use --instance-id", strings.Join(ids, ", "),
+			)
+		}
+	}
+
+	if instance.State != "running" {
+		return nil, errors.Errorf("instance %s is %s, it must be running", *instance.Id, instance.State)
+	}
+
+	return instance, nil
+}
+
+//Loads the key, trusts the host key and registers the docker context of an instance.
+func (e *ec2HostImpl) attach(
+	instance *aws.InstanceDescription,
+	contextName string,
+	keyPairPath string,
+	sshUser string,
+	jumps []string,
+) (*AttachResult, error) {
+	target, err := instanceTarget(instance)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if sshUser != "" {
+		target.User = sshUser
+	}
+
+	if len(jumps) > 0 {
+		if target.Jumps, err = sshutil.ParseJumps(jumps); err != nil {
+			return nil, err
+		}
+	}
+
+	sshUtils := e.helpers.SSHUtils()
+
+	if keyPairPath != "" {
+		if err := sshUtils.SSHAgentAddKey(keyPairPath, agentKeyComment(contextName)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := sshUtils.SSHKnownHostsAdd(target); err != nil {
+		return nil, errors.Wrap(err, "failed to record the host key")
+	}
+
+	dockerHost, err := e.helpers.DockerHost(contextName, target)
+
+	if err != nil {
+		return nil, err
+	}
+
+	contextMetadata := map[string]string{}
+
+	if arch := instanceArch(instance); arch != "" {
+		contextMetadata[architectureMetadata] = arch
+	}
+
+	if err := e.helpers.RegisterToDocker(contextName, dockerHost, contextMetadata); err != nil {
+		return nil, err
+	}
+
+	return &AttachResult{
+		ID:            *instance.Id,
+		Address:       instanceAddress(instance),
+		DockerContext: contextName,
+	}, nil
+}
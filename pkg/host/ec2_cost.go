@@ -0,0 +1,128 @@
+package host
+
+import (
+	"sort"
+	"time"
+
+	"github.com/knlambert/docker-remote.git/pkg/host/aws"
+	"github.com/knlambert/docker-remote.git/pkg/pricing"
+	"github.com/pkg/errors"
+)
+
+//Summarizes the hourly rate and the current session cost of every managed host, per owner.
+func (e *ec2HostImpl) Cost() (*CostResult, error) {
+	hosts, err := e.List()
+
+	if err != nil {
+		return nil, err
+	}
+
+	owners := map[string]*OwnerCost{}
+	result := CostResult{Owners: []OwnerCost{}, UnpricedHosts: []string{}}
+
+	for _, host := range hosts.Hosts {
+		if host.Cost == nil || host.Cost.totalHourlyRate() == nil {
+			result.UnpricedHosts = append(result.UnpricedHosts, host.ID)
+			continue
+		}
+
+		owner, ok := owners[host.Owner]
+
+		if !ok {
+			owner = &OwnerCost{Owner: host.Owner}
+			owners[host.Owner] = owner
+		}
+
+		owner.Hosts++
+		owner.HourlyRate += *host.Cost.totalHourlyRate()
+
+		if host.Cost.SessionCost != nil {
+			owner.SessionCost += *host.Cost.SessionCost
+		}
+	}
+
+	for _, owner := range owners {
+		result.Owners = append(result.Owners, *owner)
+	}
+
+	sort.Slice(result.Owners, func(i, j int) bool {
+		return result.Owners[i].HourlyRate > result.Owners[j].HourlyRate
+	})
+
+	return &result, nil
+}
+
+//Prices an instance and its volumes from the price table, or the spot market for spot instances.
+func (e *ec2HostImpl) instanceCost(instance *aws.InstanceDescription) (*HostCost, error) {
+	region := regionFromAvailabilityZone(instance.AvailabilityZone)
+	cost := HostCost{Lifecycle: "on-demand"}
+
+	if instance.State == "running" || instance.State == "pending" {
+		var err error
+
+		if instance.Lifecycle == "spot" {
+			cost.Lifecycle = "spot"
+			cost.HourlyRate, err = e.aws.SpotPrice(instance.InstanceType, instance.AvailabilityZone)
+		} else {
+			cost.HourlyRate, err = e.pricing.InstanceHourlyPrice(region, instance.InstanceType)
+		}
+
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to price instance %s", *instance.Id)
+		}
+	} else {
+		stopped := 0.0
+		cost.HourlyRate = &stopped
+	}
+
+	volumes, err := e.aws.InstanceVolumes(*instance.Id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	storageHourlyRate := 0.0
+	storagePriced := true
+
+	for _, volume := range volumes {
+		monthlyPrice, err := e.pricing.VolumeMonthlyPrice(region, volume.Type)
+
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to price volume %s", volume.Id)
+		}
+
+		cost.StorageSize += volume.Size
+
+		if monthlyPrice == nil {
+			storagePriced = false
+			continue
+		}
+
+		storageHourlyRate += *monthlyPrice * float64(volume.Size) / pricing.HoursPerMonth
+	}
+
+	//A single unknown volume type leaves the storage, and so the total, unknown.
+	if !storagePriced {
+		return &cost, nil
+	}
+
+	cost.StorageHourlyRate = &storageHourlyRate
+
+	//EC2 resets the launch time at each start, so only the current session can be priced.
+	running := instance.State == "running" || instance.State == "pending"
+
+	if running && cost.HourlyRate != nil && instance.LaunchTime != nil {
+		session := time.Since(*instance.LaunchTime).Hours() * (*cost.HourlyRate + storageHourlyRate)
+		cost.SessionCost = &session
+	}
+
+	return &cost, nil
+}
+
+//Availability zones are named after their region followed by a letter.
+func regionFromAvailabilityZone(availabilityZone string) string {
+	if availabilityZone == "" {
+		return ""
+	}
+	return availabilityZone[:len(availabilityZone)-1]
+}
@@ -0,0 +1,162 @@
+package host
+
+import (
+	"testing"
+	"time"
+
+	"github.com/knlambert/docker-remote.git/pkg/host/aws"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+//Serves the instances and volumes the cost needs, the other calls panic.
+type fakeCostAWS struct {
+	aws.AWS
+	instances []*aws.InstanceDescription
+	volumes   map[string][]*aws.VolumeDescription
+	spotPrice *float64
+	spotErr   error
+}
+
+func (f *fakeCostAWS) InstanceList(tags map[string]string, states []string) ([]*aws.InstanceDescription, error) {
+	return f.instances, nil
+}
+
+func (f *fakeCostAWS) InstanceVolumes(instanceId string) ([]*aws.VolumeDescription, error) {
+	return f.volumes[instanceId], nil
+}
+
+func (f *fakeCostAWS) SpotPrice(instanceType string, availabilityZone string) (*float64, error) {
+	return f.spotPrice, f.spotErr
+}
+
+//Prices of us-east-1 only.
+type fakePricing struct{}
+
+func (f *fakePricing) InstanceHourlyPrice(region string, instanceType string) (*float64, error) {
+	prices := map[string]float64{"t3.micro": 0.0104, "m5.large": 0.096}
+
+	if price, ok := prices[instanceType]; ok && region == "us-east-1" {
+		return &price, nil
+	}
+	return nil, nil
+}
+
+func (f *fakePricing) VolumeMonthlyPrice(region string, volumeType string) (*float64, error) {
+	prices := map[string]float64{"gp2": 0.1, "gp3": 0.08}
+
+	if price, ok := prices[volumeType]; ok && region == "us-east-1" {
+		return &price, nil
+	}
+	return nil, nil
+}
+
+func costInstance(id string, owner string, state string, launchedAgo time.Duration) *aws.InstanceDescription {
+	launchTime := time.Now().Add(-launchedAgo)
+
+	return &aws.InstanceDescription{
+		Id:               &id,
+		State:            state,
+		InstanceType:     "m5.large",
+		LaunchTime:       &launchTime,
+		Tags:             map[string]string{"owner": owner},
+		AvailabilityZone: "us-east-1a",
+	}
+}
+
+func TestInstanceCost(t *testing.T) {
+	// Tear up.
+	awsFake := &fakeCostAWS{volumes: map[string][]*aws.VolumeDescription{
+		"i-1": {{Id: "vol-1", Type: "gp2", Size: 73}, {Id: "vol-2", Type: "gp3", Size: 73}},
+	}}
+	e := &ec2HostImpl{aws: awsFake, pricing: &fakePricing{}}
+
+	cost, err := e.instanceCost(costInstance("i-1", "barney", "running", 10*time.Hour))
+
+	//Assertions
+	assert.Nil(t, err)
+	assert.Equal(t, "on-demand", cost.Lifecycle)
+	assert.Equal(t, 0.096, *cost.HourlyRate)
+	assert.Equal(t, int64(146), cost.StorageSize)
+	assert.InDelta(t, 0.018, *cost.StorageHourlyRate, 1e-9)
+	assert.InDelta(t, 1.14, *cost.SessionCost, 1e-3)
+}
+
+func TestInstanceCostStopped(t *testing.T) {
+	// Tear up.
+	awsFake := &fakeCostAWS{volumes: map[string][]*aws.VolumeDescription{
+		"i-1": {{Id: "vol-1", Type: "gp2", Size: 73}},
+	}}
+	e := &ec2HostImpl{aws: awsFake, pricing: &fakePricing{}}
+
+	cost, err := e.instanceCost(costInstance("i-1", "barney", "stopped", 10*time.Hour))
+
+	//Assertions
+	assert.Nil(t, err)
+	assert.Equal(t, 0.0, *cost.HourlyRate)
+	assert.InDelta(t, 0.01, *cost.StorageHourlyRate, 1e-9)
+	assert.Nil(t, cost.SessionCost, "a stopped host has no running session to price")
+}
+
+func TestInstanceCostSpot(t *testing.T) {
+	// Tear up.
+	spotPrice := 0.035
+	awsFake := &fakeCostAWS{spotPrice: &spotPrice}
+	e := &ec2HostImpl{aws: awsFake, pricing: &fakePricing{}}
+	instance := costInstance("i-1", "barney", "running", time.Hour)
+	instance.Lifecycle = "spot"
+
+	cost, err := e.instanceCost(instance)
+
+	//Assertions
+	assert.Nil(t, err)
+	assert.Equal(t, "spot", cost.Lifecycle)
+	assert.Equal(t, 0.035, *cost.HourlyRate)
+	assert.Equal(t, 0.0, *cost.StorageHourlyRate)
+}
+
+func TestInstanceCostUnknownVolumeType(t *testing.T) {
+	// Tear up.
+	awsFake := &fakeCostAWS{volumes: map[string][]*aws.VolumeDescription{
+		"i-1": {{Id: "vol-1", Type: "sc1", Size: 500}, {Id: "vol-2", Type: "gp2", Size: 8}},
+	}}
+	e := &ec2HostImpl{aws: awsFake, pricing: &fakePricing{}}
+
+	cost, err := e.instanceCost(costInstance("i-1", "barney", "running", time.Hour))
+
+	//Assertions
+	assert.Nil(t, err)
+	assert.Equal(t, int64(508), cost.StorageSize, "the volumes after the unknown one should be counted")
+	assert.Nil(t, cost.StorageHourlyRate)
+	assert.Nil(t, cost.SessionCost)
+}
+
+func TestCostSumsOwnersAndKeepsUnpricedHosts(t *testing.T) {
+	// Tear up.
+	unknownType := costInstance("i-3", "fred", "running", time.Hour)
+	unknownType.InstanceType = "x1e.32xlarge"
+	spot := costInstance("i-4", "fred", "running", time.Hour)
+	spot.Lifecycle = "spot"
+
+	awsFake := &fakeCostAWS{
+		instances: []*aws.InstanceDescription{
+			costInstance("i-1", "barney", "running", 2*time.Hour),
+			costInstance("i-2", "barney", "stopped", 2*time.Hour),
+			unknownType,
+			spot,
+		},
+		spotErr: errors.New("throttled"),
+	}
+	e := &ec2HostImpl{aws: awsFake, pricing: &fakePricing{}}
+
+	result, err := e.Cost()
+
+	//Assertions
+	assert.Nil(t, err, "a host that can't be priced should not fail the cost")
+	assert.Len(t, result.Owners, 1)
+	assert.Equal(t, "barney", result.Owners[0].Owner)
+	assert.Equal(t, 2, result.Owners[0].Hosts)
+	assert.InDelta(t, 0.096, result.Owners[0].HourlyRate, 1e-9)
+	assert.InDelta(t, 0.192, result.Owners[0].SessionCost, 1e-3, "only the running host has a session")
+	assert.Equal(t, []string{"i-3", "i-4"}, result.UnpricedHosts)
+}
@@ -0,0 +1,204 @@
+package host
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/knlambert/docker-remote.git/pkg/docker"
+	"github.com/knlambert/docker-remote.git/pkg/host/aws"
+	"github.com/knlambert/docker-remote.git/pkg/host/aws/ec2test"
+	mock_runtime "github.com/knlambert/docker-remote.git/pkg/mock/std/runtime"
+	mock_user "github.com/knlambert/docker-remote.git/pkg/mock/std/user"
+	"github.com/knlambert/docker-remote.git/pkg/provision"
+	"github.com/knlambert/docker-remote.git/pkg/sshutil"
+	"github.com/knlambert/docker-remote.git/pkg/sshutil/sshtest"
+	stdioutil "github.com/knlambert/docker-remote.git/pkg/std/ioutil"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+//Does not record the region in the state of the current user.
+type e2eAWS struct {
+	aws.AWS
+}
+
+func (a *e2eAWS) RegionRecord() error {
+	return nil
+}
+
+//Keeps the SSH config of the current user untouched.
+type e2eSSHUtils struct {
+	sshutil.SSHUtils
+	aliases map[string]*sshutil.Target
+}
+
+func (s *e2eSSHUtils) SSHConfigSet(alias string, target *sshutil.Target) error {
+	s.aliases[alias] = target
+	return nil
+}
+
+func (s *e2eSSHUtils) SSHConfigRemove(alias string) error {
+	delete(s.aliases, alias)
+	return nil
+}
+
+//Records the docker contexts instead of calling the docker command line.
+type e2eDocker struct {
+	contexts map[string]string
+	metadata map[string]map[string]string
+}
+
+func (d *e2eDocker) ContextSet(name string, dockerHost string, metadata map[string]string) error {
+	d.contexts[name] = dockerHost
+	d.metadata[name] = metadata
+	return nil
+}
+
+func (d *e2eDocker) ContextRemove(name string) error {
+	delete(d.contexts, name)
+	return nil
+}
+
+func (d *e2eDocker) RegistryAuths(registries []string) (map[string]docker.RegistryAuth, error) {
+	return nil, nil
+}
+
+//Serves an in-memory SSH agent, returns its socket.
+func serveAgent(t *testing.T, dir string) string {
+	socket := filepath.Join(dir, "agent.sock")
+	listener, err := net.Listen("unix", socket)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyring := agent.NewKeyring()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+
+			if err != nil {
+				return
+			}
+
+			go agent.ServeAgent(keyring, conn)
+		}
+	}()
+
+	return socket
+}
+
+//Runs up, shell and down against the EC2 emulator. The instances are reached through the
+//test SSH server as a jump host, which forwards every address to itself.
+func TestEC2EndToEnd(t *testing.T) {
+	// Tear up.
+	ctrl := gomock.NewController(t)
+	dir, _ := ioutil.TempDir("", "e2e")
+	defer os.RemoveAll(dir)
+
+	for key, value := range map[string]string{
+		"AWS_ACCESS_KEY_ID":     "AKIDEXAMPLE",
+		"AWS_SECRET_ACCESS_KEY": "secret",
+		"SSH_AUTH_SOCK":         serveAgent(t, dir),
+	} {
+		defer os.Setenv(key, os.Getenv(key))
+		os.Setenv(key, value)
+	}
+
+	privateKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	keyPairPath := filepath.Join(dir, "id_rsa")
+	_ = ioutil.WriteFile(keyPairPath, pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+	}), 0600)
+
+	publicKey, _ := ssh.NewPublicKey(&privateKey.PublicKey)
+	sshServer, err := sshtest.CreateServer(publicKey)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer sshServer.Close()
+
+	sshServer.Dial = func(addr string) (net.Conn, error) {
+		return net.Dial("tcp", sshServer.Addr)
+	}
+
+	ec2Server := ec2test.CreateServer()
+	defer ec2Server.Close()
+
+	userMock := mock_user.NewMockUser(ctrl)
+	userMock.EXPECT().Current().Return(&user.User{Username: "barney", HomeDir: dir}, nil).AnyTimes()
+
+	sshUtils := &e2eSSHUtils{SSHUtils: sshutil.CreateSSHUtils(), aliases: map[string]*sshutil.Target{}}
+	dockerFake := &e2eDocker{contexts: map[string]string{}, metadata: map[string]map[string]string{}}
+
+	runtimeMock := mock_runtime.NewMockRuntime(ctrl)
+	runtimeMock.EXPECT().CurrentArch().Return("amd64")
+
+	e := &ec2HostImpl{
+		aws: &e2eAWS{aws.CreateFromConfig(&aws.SessionConfig{Region: "us-east-1", EndpointURL: ec2Server.URL})},
+		helpers: &pluginHelperImpl{
+			user:      userMock,
+			io:        stdioutil.CreateIOUtil(),
+			docker:    dockerFake,
+			provision: provision.CreateProvision(),
+			sshUtils:  sshUtils,
+		},
+		runtime: runtimeMock,
+	}
+
+	//Assertions
+	up, err := e.Up(context.Background(), &UpParams{
+		AMI:           "ami-0123",
+		InstanceTypes: []string{"t3.medium"},
+		KeyPairPath:   keyPairPath,
+		KeyName:       "barney",
+		SecurityGroup: "sg-0123",
+		Timeout:       time.Minute,
+		Jumps:         []string{"ops@" + sshServer.Addr},
+	})
+
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	assert.True(t, up.Created)
+	assert.Equal(t, "203.0.113.5", up.Address)
+	assert.Equal(t, "amd64", up.Architecture)
+	assert.Equal(t, map[string]string{dockerContextName: "ssh://" + dockerContextName}, dockerFake.contexts)
+	assert.Equal(t, map[string]string{"Architecture": "amd64"}, dockerFake.metadata[dockerContextName])
+	assert.Equal(t, "ops@"+sshServer.Addr, sshUtils.aliases[dockerContextName].ProxyJump())
+	assert.Equal(t, map[string]string{up.ID: "running"}, ec2Server.States())
+
+	assert.Nil(t, e.Shell(nil))
+
+	down, err := e.Down(&DownParams{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, &DownResult{ID: up.ID, Terminated: true}, down)
+	assert.NotEqual(t, "running", ec2Server.States()[up.ID])
+	assert.Empty(t, sshUtils.aliases)
+
+	assert.Equal(t, []string{
+		"true",
+		"test -f /var/lib/cloud/instance/boot-finished",
+		"shell",
+	}, sshServer.Commands())
+
+	ctrl.Finish()
+}
@@ -0,0 +1,169 @@
+package host
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/knlambert/docker-remote.git/pkg/host/aws"
+	"github.com/knlambert/docker-remote.git/pkg/sshutil"
+	"github.com/pkg/errors"
+)
+
+const (
+	//The instance profile (and role) created by up --managed-role.
+	managedInstanceProfile = "docker-remote-host"
+	//Installs the Amazon ECR credential helper.
+	ecrCredentialHelperScript = `command -v docker-credential-ecr-login >/dev/null || {
+  sudo amazon-linux-extras enable docker >/dev/null 2>&1
+  sudo yum install -y amazon-ecr-credential-helper
+}`
+)
+
+//The policies of the managed role: pulling from ECR, and the SSM agent for the ssm transport.
+var managedRolePolicies = []string{
+	"arn:aws:iam::aws:policy/AmazonEC2ContainerRegistryReadOnly",
+	"arn:aws:iam::aws:policy/AmazonSSMManagedInstanceCore",
+}
+
+type EcrLoginParams struct {
+	//The IDs (AWS accounts) of the registries to log in, the caller's account one when empty.
+	Registries []string
+	//Configures the ECR credential helper instead of logging in with a token fetched locally.
+	CredentialHelper bool
+}
+
+//Gives the docker daemon of the host access to ECR registries.
+func (e *ec2HostImpl) EcrLogin(params interface{}) (*EcrLoginResult, error) {
+	loginParams := params.(*EcrLoginParams)
+
+	metadata, err := e.metadata()
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to calculate metadata")
+	}
+
+	instance, err := e.describeOwned(metadata, []string{"running"})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if instance == nil {
+		return nil, errors.Errorf("Please create the host first")
+	}
+
+	target, err := instanceTarget(instance)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := EcrLoginResult{ID: *instance.Id, CredentialHelper: loginParams.CredentialHelper, Registries: []string{}}
+
+	authorizations, err := e.aws.RegistryAuthorizations(loginParams.Registries)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if loginParams.CredentialHelper {
+		return e.ecrCredentialHelperConfigure(target, authorizations, &result)
+	}
+
+	for _, authorization := range authorizations {
+		out, err := e.helpers.SSHUtils().SSHRunInput(
+			target,
+			fmt.Sprintf("docker login --username %s --password-stdin %s", authorization.Username, authorization.Endpoint),
+			[]byte(authorization.Password),
+		)
+
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to log in %s: %s", authorization.Endpoint, strings.TrimSpace(string(out)))
+		}
+
+		result.Registries = append(result.Registries, authorization.Endpoint)
+	}
+
+	return &result, nil
+}
+
+//Installs the ECR credential helper on the host and hands it the registries, keeping the
+//other settings and registries of the docker configuration.
+func (e *ec2HostImpl) ecrCredentialHelperConfigure(
+	target *sshutil.Target,
+	authorizations []*aws.RegistryAuthorization,
+	result *EcrLoginResult,
+) (*EcrLoginResult, error) {
+	sshUtils := e.helpers.SSHUtils()
+
+	if out, err := sshUtils.SSHRun(target, ecrCredentialHelperScript); err != nil {
+		return nil, errors.Wrapf(err, "failed to install the ECR credential helper: %s", strings.TrimSpace(string(out)))
+	}
+
+	config, err := readDockerConfig(sshUtils, target)
+
+	if err != nil {
+		return nil, err
+	}
+
+	helpers, _ := config["credHelpers"].(map[string]interface{})
+
+	if helpers == nil {
+		helpers = map[string]interface{}{}
+	}
+
+	for _, authorization := range authorizations {
+		//Credential helpers are keyed by the registry host, without scheme.
+		registry := strings.TrimPrefix(strings.TrimPrefix(authorization.Endpoint, "https://"), "http://")
+		helpers[registry] = "ecr-login"
+		result.Registries = append(result.Registries, registry)
+	}
+
+	config["credHelpers"] = helpers
+
+	if err := writeDockerConfig(sshUtils, target, config); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+type RegistrySyncParams struct {
+	//The registries to copy the credentials of, all the known ones when empty.
+	Registries []string
+}
+
+//Copies the local registry credentials to the docker configuration of the host user.
+func (e *ec2HostImpl) RegistrySync(params interface{}) (*RegistrySyncResult, error) {
+	syncParams := params.(*RegistrySyncParams)
+
+	metadata, err := e.metadata()
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to calculate metadata")
+	}
+
+	instance, err := e.describeOwned(metadata, []string{"running"})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if instance == nil {
+		return nil, errors.Errorf("Please create the host first")
+	}
+
+	target, err := instanceTarget(instance)
+
+	if err != nil {
+		return nil, err
+	}
+
+	registries, err := e.helpers.RegistrySync(target, syncParams.Registries)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &RegistrySyncResult{ID: *instance.Id, Registries: registries}, nil
+}
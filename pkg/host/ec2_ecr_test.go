@@ -0,0 +1,141 @@
+package host
+
+import (
+	"encoding/json"
+	"os/user"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/knlambert/docker-remote.git/pkg/host/aws"
+	mock_user "github.com/knlambert/docker-remote.git/pkg/mock/std/user"
+	"github.com/knlambert/docker-remote.git/pkg/sshutil"
+	"github.com/stretchr/testify/assert"
+)
+
+//Serves the running host of barney and the credentials of the registries asked.
+type fakeRegistryAWS struct {
+	aws.AWS
+}
+
+func (f *fakeRegistryAWS) CallerIdentity() (string, error) {
+	return "arn:aws:iam::123456789012:user/barney", nil
+}
+
+func (f *fakeRegistryAWS) InstanceDescribe(tags map[string]string, states []string) (*aws.InstanceDescription, error) {
+	id, ip := "i-0123", "10.0.0.1"
+	return &aws.InstanceDescription{Id: &id, PublicIp: &ip, State: "running", Tags: tags}, nil
+}
+
+func (f *fakeRegistryAWS) RegistryAuthorizations(registryIds []string) ([]*aws.RegistryAuthorization, error) {
+	if len(registryIds) == 0 {
+		registryIds = []string{"123456789012"}
+	}
+
+	var authorizations []*aws.RegistryAuthorization
+
+	for _, id := range registryIds {
+		authorizations = append(authorizations, &aws.RegistryAuthorization{
+			Endpoint: "https://" + id + ".dkr.ecr.us-east-1.amazonaws.com",
+			Username: "AWS",
+			Password: "token-" + id,
+		})
+	}
+
+	return authorizations, nil
+}
+
+//Runs the commands against an in-memory ~/.docker/config.json.
+type fakeHostSSH struct {
+	sshutil.SSHUtils
+	config   string
+	commands []string
+	inputs   []string
+}
+
+func (f *fakeHostSSH) SSHRun(target *sshutil.Target, command string) ([]byte, error) {
+	return f.SSHRunInput(target, command, nil)
+}
+
+func (f *fakeHostSSH) SSHRunInput(target *sshutil.Target, command string, input []byte) ([]byte, error) {
+	f.commands = append(f.commands, command)
+	f.inputs = append(f.inputs, string(input))
+
+	if strings.HasPrefix(command, "cat ~/.docker/config.json") {
+		return []byte(f.config), nil
+	} else if strings.Contains(command, "cat > ~/.docker/config.json") {
+		f.config = string(input)
+	}
+
+	return nil, nil
+}
+
+func stubbedRegistryHost(ctrl *gomock.Controller, config string) (*ec2HostImpl, *fakeHostSSH) {
+	userMock := mock_user.NewMockUser(ctrl)
+	userMock.EXPECT().Current().Return(&user.User{Username: "barney"}, nil).AnyTimes()
+
+	sshFake := &fakeHostSSH{config: config}
+
+	return &ec2HostImpl{
+		aws:     &fakeRegistryAWS{},
+		helpers: &pluginHelperImpl{user: userMock, sshUtils: sshFake},
+	}, sshFake
+}
+
+func TestEcrLoginWithTokens(t *testing.T) {
+	// Tear up.
+	ctrl := gomock.NewController(t)
+	e, sshFake := stubbedRegistryHost(ctrl, "")
+
+	//Assertions
+	result, err := e.EcrLogin(&EcrLoginParams{Registries: []string{"123456789012", "210987654321"}})
+
+	assert.Nil(t, err)
+	assert.Equal(t, &EcrLoginResult{ID: "i-0123", Registries: []string{
+		"https://123456789012.dkr.ecr.us-east-1.amazonaws.com",
+		"https://210987654321.dkr.ecr.us-east-1.amazonaws.com",
+	}}, result)
+	assert.Equal(t, []string{
+		"docker login --username AWS --password-stdin https://123456789012.dkr.ecr.us-east-1.amazonaws.com",
+		"docker login --username AWS --password-stdin https://210987654321.dkr.ecr.us-east-1.amazonaws.com",
+	}, sshFake.commands)
+	assert.Equal(t, []string{"token-123456789012", "token-210987654321"}, sshFake.inputs)
+
+	ctrl.Finish()
+}
+
+func TestEcrLoginWithCredentialHelperKeepsTheConfiguration(t *testing.T) {
+	// Tear up.
+	ctrl := gomock.NewController(t)
+	e, sshFake := stubbedRegistryHost(ctrl, `{
+  "auths": {"ghcr.io": {"auth": "YmFybmV5OnRva2Vu"}},
+  "credHelpers": {"gcr.io": "gcloud"},
+  "detachKeys": "ctrl-x"
+}`)
+
+	//Assertions
+	result, err := e.EcrLogin(&EcrLoginParams{CredentialHelper: true})
+
+	assert.Nil(t, err)
+	assert.Equal(t, &EcrLoginResult{
+		ID:               "i-0123",
+		CredentialHelper: true,
+		Registries:       []string{"123456789012.dkr.ecr.us-east-1.amazonaws.com"},
+	}, result)
+	assert.Equal(t, ecrCredentialHelperScript, sshFake.commands[0])
+
+	var config map[string]interface{}
+	assert.Nil(t, json.Unmarshal([]byte(sshFake.config), &config))
+
+	assert.Equal(t, map[string]interface{}{
+		"auths": map[string]interface{}{"ghcr.io": map[string]interface{}{"auth": "YmFybmV5OnRva2Vu"}},
+		"credHelpers": map[string]interface{}{
+			"gcr.io": "gcloud",
+			"123456789012.dkr.ecr.us-east-1.amazonaws.com": "ecr-login",
+		},
+		"detachKeys": "ctrl-x",
+	}, config)
+	assert.NotContains(t, config, "credsStore", "the other registries should keep their credentials")
+
+	ctrl.Finish()
+}
@@ -0,0 +1,91 @@
+package host
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/knlambert/docker-remote.git/pkg/host/aws"
+)
+
+type DownParams struct {
+	//Keeps the Elastic IP of the host for the next up.
+	KeepIP bool
+}
+
+//The step giving the instance the Elastic IP of its owner, allocated on first use.
+func (e *ec2HostImpl) elasticIPStep(
+	upParams *UpParams,
+	metadata map[string]string,
+	instance **aws.InstanceDescription,
+) Step {
+	var allocated *aws.AddressDescription
+
+	return Step{
+		Name: "elastic IP",
+		Run: func(ctx context.Context) error {
+			if !upParams.ElasticIP {
+				return nil
+			}
+
+			address, err := e.aws.AddressDescribe(metadata)
+
+			if err != nil {
+				return err
+			}
+
+			if address == nil {
+				if address, err = e.aws.AddressAllocate(metadata); err != nil {
+					return err
+				}
+
+				log.Printf("Elastic IP %s allocated", address.PublicIp)
+				allocated = address
+			}
+
+			if address.InstanceId != *(*instance).Id {
+				if err := e.aws.AddressAssociate(address.AllocationId, *(*instance).Id); err != nil {
+					return err
+				}
+			}
+
+			(*instance).PublicIp = &address.PublicIp
+
+			return nil
+		},
+		Rollback: func() error {
+			if allocated == nil {
+				return nil
+			}
+			_, err := e.releaseOwnedAddress(metadata)
+			return err
+		},
+		Resource: func() string {
+			if allocated == nil {
+				return ""
+			}
+			return fmt.Sprintf("elastic IP %s", allocated.PublicIp)
+		},
+	}
+}
+
+//Releases the Elastic IP matching the tags, returns its address, nil when there is none.
+func (e *ec2HostImpl) releaseOwnedAddress(tags map[string]string) (*string, error) {
+	address, err := e.aws.AddressDescribe(tags)
+
+	if err != nil || address == nil {
+		return nil, err
+	}
+
+	if address.AssociationId != "" {
+		if err := e.aws.AddressDisassociate(address.AssociationId); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := e.aws.AddressRelease(address.AllocationId); err != nil {
+		return nil, err
+	}
+
+	return &address.PublicIp, nil
+}
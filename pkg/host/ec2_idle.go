@@ -0,0 +1,78 @@
+package host
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/knlambert/docker-remote.git/pkg/host/aws"
+	"github.com/knlambert/docker-remote.git/pkg/provision"
+)
+
+const (
+	//The tag holding the idle timeout of the host watchdog, in minutes.
+	idleTimeoutTag = "idle_timeout"
+	//How long before an idle shutdown the shell warns about it.
+	idleWarningDelay = 5 * time.Minute
+)
+
+//Reads the idle countdown of the host watchdog, nil when the watchdog is disabled or unreachable.
+func (e *ec2HostImpl) idleStatus(instance *aws.InstanceDescription) *IdleStatus {
+	timeout, err := strconv.Atoi(instance.Tags[idleTimeoutTag])
+
+	if err != nil || timeout <= 0 || instance.State != "running" {
+		return nil
+	}
+
+	target, err := instanceTarget(instance)
+
+	if err != nil {
+		return nil
+	}
+
+	out, err := e.helpers.SSHUtils().SSHRun(
+		target,
+		fmt.Sprintf("echo $(( $(date +%%s) - $(cat %s) ))", provision.IdleStatePath),
+	)
+
+	if err != nil {
+		log.Printf("Failed to read the idle countdown: %s", err)
+		return nil
+	}
+
+	idleSeconds, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+
+	if err != nil {
+		log.Printf("Failed to read the idle countdown: %s", err)
+		return nil
+	}
+
+	status := IdleStatus{
+		Timeout: timeout,
+		IdleFor: idleSeconds,
+	}
+
+	if shutdownIn := int64(timeout)*60 - idleSeconds; shutdownIn > 0 {
+		status.ShutdownIn = shutdownIn
+	}
+
+	return &status
+}
+
+//Logs a warning when the host is about to shut itself down.
+func (e *ec2HostImpl) warnIfIdle(instance *aws.InstanceDescription) {
+	status := e.idleStatus(instance)
+
+	if status == nil {
+		return
+	}
+
+	if shutdownIn := time.Duration(status.ShutdownIn) * time.Second; shutdownIn < idleWarningDelay {
+		log.Printf(
+			"Warning: host %s has been idle for %s and shuts down in %s unless used",
+			*instance.Id, (time.Duration(status.IdleFor) * time.Second).Round(time.Minute), shutdownIn,
+		)
+	}
+}
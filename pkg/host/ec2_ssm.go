@@ -0,0 +1,91 @@
+package host
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/knlambert/docker-remote.git/pkg/host/aws"
+	"github.com/pkg/errors"
+)
+
+const (
+	//The tag holding how SSH reaches the instance: ssh (TCP to port 22) or ssm.
+	transportTag = "ssh_transport"
+	SSHTransport = "ssh"
+	SSMTransport = "ssm"
+	//The instance profile created by the Systems Manager quick setup, used when none is given.
+	defaultSSMInstanceProfile = "AmazonSSMRoleForInstancesQuickSetup"
+	//The AWS program streaming SSM sessions, as the AWS command line uses it.
+	sessionManagerPlugin = "session-manager-plugin"
+)
+
+type SSMProxyParams struct {
+	InstanceId string
+	Port       int
+}
+
+//Streams the SSH port of an instance on the standard input and output through an SSM session,
+//meant to be used as an SSH ProxyCommand.
+func (e *ec2HostImpl) SSMProxy(params interface{}) error {
+	proxyParams := params.(*SSMProxyParams)
+
+	pluginPath, err := exec.LookPath(sessionManagerPlugin)
+
+	if err != nil {
+		return errors.Errorf(
+			"%s is required for the ssm transport, see "+
+				"https://docs.aws.amazon.com/systems-manager/latest/userguide/session-manager-working-with-install-plugin.html",
+			sessionManagerPlugin,
+		)
+	}
+
+	session, err := e.aws.SessionStart(proxyParams.InstanceId, proxyParams.Port)
+
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = e.aws.SessionTerminate(session.Id)
+	}()
+
+	plugin := exec.Command(
+		pluginPath,
+		string(session.Response),
+		session.Region,
+		"StartSession",
+		"",
+		string(session.Request),
+		session.Endpoint,
+	)
+
+	plugin.Stdin = os.Stdin
+	plugin.Stdout = os.Stdout
+	plugin.Stderr = os.Stderr
+
+	if err := plugin.Run(); err != nil {
+		return errors.Wrapf(err, "the SSM session to %s failed", proxyParams.InstanceId)
+	}
+
+	return nil
+}
+
+//Returns the ProxyCommand running the ssm-proxy command of this executable.
+func ssmProxyCommand() (string, error) {
+	executable, err := os.Executable()
+
+	if err != nil {
+		return "", errors.Wrap(err, "failed to locate the docker-remote executable")
+	}
+
+	if strings.ContainsAny(executable, " \t") {
+		executable = fmt.Sprintf(`"%s"`, executable)
+	}
+
+	//The session options are given again, the command runs outside this one.
+	args := append([]string{executable, "ec2", string(SSMProxy)}, aws.CommandLineConfig.Args()...)
+
+	return fmt.Sprintf("%s %%h %%p", strings.Join(args, " ")), nil
+}
@@ -0,0 +1,67 @@
+package host
+
+import (
+	"strings"
+
+	"github.com/knlambert/docker-remote.git/pkg/host/aws"
+	"github.com/knlambert/docker-remote.git/pkg/sshutil"
+	"github.com/pkg/errors"
+)
+
+const (
+	//The tag holding the jump hosts of the instance, in the ProxyJump format.
+	sshJumpTag = "ssh_jump"
+	//The user of the Amazon Linux images.
+	ec2User = "ec2-user"
+)
+
+//Returns the address of an instance, the public IP when it has one, the private IP otherwise.
+func instanceAddress(instance *aws.InstanceDescription) string {
+	if instance.PublicIp != nil && *instance.PublicIp != "" {
+		return *instance.PublicIp
+	} else if instance.PrivateIp != nil {
+		return *instance.PrivateIp
+	}
+	return ""
+}
+
+//Returns the SSH user of an instance.
+func instanceUser(instance *aws.InstanceDescription) string {
+	if user := instance.Tags[sshUserTag]; user != "" {
+		return user
+	}
+	return ec2User
+}
+
+//Returns how to reach an instance over SSH, through the jump hosts or the SSM session it was created with.
+func instanceTarget(instance *aws.InstanceDescription) (*sshutil.Target, error) {
+	if instance.Tags[transportTag] == SSMTransport {
+		proxyCommand, err := ssmProxyCommand()
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &sshutil.Target{
+			Endpoint:     sshutil.Endpoint{User: instanceUser(instance), Host: *instance.Id},
+			ProxyCommand: proxyCommand,
+		}, nil
+	}
+
+	address := instanceAddress(instance)
+
+	if address == "" {
+		return nil, errors.Errorf("instance %s has no IP address yet", *instance.Id)
+	}
+
+	jumps, err := sshutil.ParseJumps(strings.Fields(instance.Tags[sshJumpTag]))
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid %s tag on %s", sshJumpTag, *instance.Id)
+	}
+
+	return &sshutil.Target{
+		Endpoint: sshutil.Endpoint{User: instanceUser(instance), Host: address},
+		Jumps:    jumps,
+	}, nil
+}
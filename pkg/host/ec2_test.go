@@ -0,0 +1,185 @@
+package host
+
+import (
+	"context"
+	"os"
+	"os/user"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/knlambert/docker-remote.git/pkg/host/aws"
+	"github.com/knlambert/docker-remote.git/pkg/host/aws/ec2test"
+	mock_user "github.com/knlambert/docker-remote.git/pkg/mock/std/user"
+	"github.com/knlambert/docker-remote.git/pkg/sshutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDescribeOwnedRetagsLegacyHosts(t *testing.T) {
+	// Tear up.
+	ctrl := gomock.NewController(t)
+
+	for key, value := range map[string]string{"AWS_ACCESS_KEY_ID": "AKIDEXAMPLE", "AWS_SECRET_ACCESS_KEY": "secret"} {
+		defer os.Setenv(key, os.Getenv(key))
+		os.Setenv(key, value)
+	}
+
+	ec2Server := ec2test.CreateServer()
+	defer ec2Server.Close()
+
+	userMock := mock_user.NewMockUser(ctrl)
+	userMock.EXPECT().Current().Return(&user.User{Username: "barney", Name: "Barney Rubble"}, nil).AnyTimes()
+
+	e := &ec2HostImpl{
+		aws:     aws.CreateFromConfig(&aws.SessionConfig{Region: "us-east-1", EndpointURL: ec2Server.URL}),
+		helpers: &pluginHelperImpl{user: userMock},
+	}
+
+	//Earlier versions tagged the hosts with the display name of the user.
+	created, _ := e.aws.InstanceCreate(&aws.InstanceCreateParams{
+		AMI:           "ami-0123",
+		InstanceTypes: []string{"t2.micro"},
+		Tags:          map[string]string{"owner": "Barney Rubble", "managed_by": "docker-remote"},
+	})
+
+	metadata, err := e.metadata()
+
+	//Assertions
+	assert.Nil(t, err)
+	assert.Equal(t, ec2test.CallerArn, metadata["owner"])
+
+	instance, err := e.describeOwned(metadata, []string{"running", "pending"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, created.Id, *instance.Id)
+	assert.Equal(t, ec2test.CallerArn, instance.Tags["owner"])
+
+	instance, err = e.aws.InstanceDescribe(metadata, []string{"running", "pending"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, created.Id, *instance.Id, "the host should be found by its AWS owner from now on")
+
+	ctrl.Finish()
+}
+
+func TestDescribeOwnedSkipsLegacyLookupWithoutDisplayName(t *testing.T) {
+	// Tear up.
+	ctrl := gomock.NewController(t)
+
+	for key, value := range map[string]string{"AWS_ACCESS_KEY_ID": "AKIDEXAMPLE", "AWS_SECRET_ACCESS_KEY": "secret"} {
+		defer os.Setenv(key, os.Getenv(key))
+		os.Setenv(key, value)
+	}
+
+	ec2Server := ec2test.CreateServer()
+	defer ec2Server.Close()
+
+	userMock := mock_user.NewMockUser(ctrl)
+	userMock.EXPECT().Current().Return(&user.User{Username: "barney"}, nil).AnyTimes()
+
+	e := &ec2HostImpl{
+		aws:     aws.CreateFromConfig(&aws.SessionConfig{Region: "us-east-1", EndpointURL: ec2Server.URL}),
+		helpers: &pluginHelperImpl{user: userMock},
+	}
+
+	//A host of a user without display name, which the empty owner should not match.
+	_, _ = e.aws.InstanceCreate(&aws.InstanceCreateParams{
+		AMI:           "ami-0123",
+		InstanceTypes: []string{"t2.micro"},
+		Tags:          map[string]string{"owner": "", "managed_by": "docker-remote"},
+	})
+
+	metadata, _ := e.metadata()
+
+	//Assertions
+	instance, err := e.describeOwned(metadata, []string{"running", "pending"})
+
+	assert.Nil(t, err)
+	assert.Nil(t, instance)
+
+	ctrl.Finish()
+}
+
+//Reports the instances as stopping until they were asked for a given number of times.
+type fakeStoppingAWS struct {
+	aws.AWS
+	stoppingFor int
+}
+
+func (f *fakeStoppingAWS) InstanceGet(instanceId string) (*aws.InstanceDescription, error) {
+	state := "stopped"
+
+	if f.stoppingFor > 0 {
+		f.stoppingFor--
+		state = "stopping"
+	}
+
+	return &aws.InstanceDescription{Id: &instanceId, State: state}, nil
+}
+
+func TestWaitStopped(t *testing.T) {
+	// Tear up.
+	id := "i-0123"
+	instance := &aws.InstanceDescription{Id: &id, State: "stopping"}
+	e := &ec2HostImpl{aws: &fakeStoppingAWS{}}
+
+	//Assertions
+	assert.Nil(t, e.waitStopped(context.Background(), instance, time.Minute))
+	assert.Equal(t, "stopped", instance.State, "the instance should be started as a stopped one")
+
+	e = &ec2HostImpl{aws: &fakeStoppingAWS{stoppingFor: 10}}
+	instance.State = "stopping"
+
+	err := e.waitStopped(context.Background(), instance, 0)
+
+	assert.EqualError(t, err, "timed out after 0s waiting for instance stopped: instance is stopping")
+	assert.Equal(t, "stopping", instance.State)
+}
+
+func TestValidateShutdownBehavior(t *testing.T) {
+	//Assertions
+	for _, behavior := range []string{"stop", "terminate", ""} {
+		assert.Nil(t, validateShutdownBehavior(behavior), behavior)
+	}
+
+	assert.EqualError(t, validateShutdownBehavior("hibernate"), "unknown shutdown behavior 'hibernate', use stop or terminate")
+}
+
+//Reports a listening then a connection event, as a forwarding would.
+type fakeForwardSSH struct {
+	sshutil.SSHUtils
+}
+
+func (f *fakeForwardSSH) LocalPortForward(
+	localPort uint,
+	remoteAddr string,
+	remotePort uint,
+	target *sshutil.Target,
+	onEvent func(event sshutil.ForwardEvent),
+) error {
+	onEvent(sshutil.ForwardEvent{})
+	onEvent(sshutil.ForwardEvent{Client: "127.0.0.1:50000"})
+	return nil
+}
+
+func TestForwardToWithoutOnEvent(t *testing.T) {
+	// Tear up.
+	helpers := &pluginHelperImpl{sshUtils: &fakeForwardSSH{}}
+	target := func() (*sshutil.Target, error) {
+		return &sshutil.Target{Endpoint: sshutil.Endpoint{User: "root", Host: "10.0.0.1"}}, nil
+	}
+	var events []*PortForwardEvent
+
+	//Assertions
+	assert.Nil(t, forwardTo(helpers, &ForwardParams{LocalPort: 8080, RemotePort: 80}, target))
+
+	assert.Nil(t, forwardTo(helpers, &ForwardParams{
+		LocalPort:  8080,
+		RemotePort: 80,
+		OnEvent: func(event *PortForwardEvent) {
+			events = append(events, event)
+		},
+	}, target))
+	assert.Len(t, events, 2)
+	assert.Equal(t, "127.0.0.1:50000", events[1].Client)
+}
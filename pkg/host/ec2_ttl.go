@@ -0,0 +1,149 @@
+package host
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/knlambert/docker-remote.git/pkg/host/aws"
+	"github.com/pkg/errors"
+)
+
+const (
+	//The tag holding the time after which a host can be reaped, RFC 3339 formatted.
+	expiresAtTag = "expires_at"
+	//How long before its expiry a host is reported as close to expiring.
+	expiryWarningDelay = time.Hour
+)
+
+type ReapParams struct {
+	DryRun bool
+}
+
+type ExtendParams struct {
+	TTL time.Duration
+}
+
+//Terminates every managed instance past its expiry, whoever owns it.
+func (e *ec2HostImpl) Reap(params interface{}) (*ReapResult, error) {
+	reapParams := params.(*ReapParams)
+
+	instances, err := e.aws.InstanceList(
+		map[string]string{"managed_by": "docker-remote"},
+		[]string{"pending", "running", "stopping", "stopped"},
+	)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list ec2 hosts")
+	}
+
+	result := ReapResult{DryRun: reapParams.DryRun, Hosts: []ReapedHost{}}
+	now := time.Now()
+	var failed []string
+
+	for _, instance := range instances {
+		expiresAt := instanceExpiry(instance)
+
+		if expiresAt == nil || expiresAt.After(now) {
+			continue
+		}
+
+		reaped := ReapedHost{
+			ID:        *instance.Id,
+			Owner:     instance.Tags["owner"],
+			ExpiresAt: *expiresAt,
+		}
+
+		if !reapParams.DryRun {
+			//The other hosts are still reaped, the failures are reported once they are.
+			if err := e.aws.InstanceTerminate(*instance.Id); err != nil {
+				log.Printf("Failed to terminate %s: %s", *instance.Id, err)
+				failed = append(failed, *instance.Id)
+			} else {
+				log.Printf("Terminated %s, owned by %s", *instance.Id, reaped.Owner)
+				reaped.Terminated = true
+			}
+		}
+
+		result.Hosts = append(result.Hosts, reaped)
+	}
+
+	if len(failed) > 0 {
+		return nil, errors.Errorf("failed to terminate the expired hosts %s", strings.Join(failed, ", "))
+	}
+
+	return &result, nil
+}
+
+//Pushes the expiry of the current user's host to now plus a new time-to-live.
+func (e *ec2HostImpl) Extend(params interface{}) (*ExtendResult, error) {
+	extendParams := params.(*ExtendParams)
+
+	if extendParams.TTL <= 0 {
+		return nil, errors.Errorf("the time-to-live must be positive, got %s", extendParams.TTL)
+	}
+
+	metadata, err := e.metadata()
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to calculate metadata")
+	}
+
+	instance, err := e.describeOwned(metadata, []string{"pending", "running", "stopping", "stopped"})
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to describe ec2 host")
+	}
+
+	if instance == nil {
+		return nil, errors.Errorf("Please create the host first")
+	}
+
+	expiresAt := time.Now().Add(extendParams.TTL).UTC().Truncate(time.Second)
+
+	if err := e.aws.InstanceTag(*instance.Id, expiryTags(expiresAt)); err != nil {
+		return nil, err
+	}
+
+	return &ExtendResult{ID: *instance.Id, ExpiresAt: expiresAt}, nil
+}
+
+//Logs a warning when the host expires soon.
+func warnIfExpiring(instance *aws.InstanceDescription) {
+	expiresAt := instanceExpiry(instance)
+
+	if expiresAt == nil {
+		return
+	}
+
+	if remaining := time.Until(*expiresAt); remaining < expiryWarningDelay {
+		log.Printf(
+			"Warning: host %s expires in %s (%s), run 'extend --ttl' to keep it",
+			*instance.Id, remaining.Round(time.Minute), expiresAt.Local().Format(time.Kitchen),
+		)
+	}
+}
+
+//Returns the expiry of an instance, nil if it has none.
+func instanceExpiry(instance *aws.InstanceDescription) *time.Time {
+	value, ok := instance.Tags[expiresAtTag]
+
+	if !ok {
+		return nil
+	}
+
+	expiresAt, err := time.Parse(time.RFC3339, value)
+
+	if err != nil {
+		log.Printf("Ignoring invalid %s tag '%s' on %s", expiresAtTag, value, *instance.Id)
+		return nil
+	}
+
+	return &expiresAt
+}
+
+func expiryTags(expiresAt time.Time) map[string]string {
+	return map[string]string{
+		expiresAtTag: expiresAt.UTC().Format(time.RFC3339),
+	}
+}
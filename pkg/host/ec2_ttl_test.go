@@ -0,0 +1,176 @@
+package host
+
+import (
+	"os/user"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/knlambert/docker-remote.git/pkg/host/aws"
+	mock_user "github.com/knlambert/docker-remote.git/pkg/mock/std/user"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+//Serves a fleet of instances, failing the termination of some of them.
+type fakeFleetAWS struct {
+	aws.AWS
+	instances     []*aws.InstanceDescription
+	failingIds    map[string]bool
+	terminatedIds []string
+	tags          map[string]map[string]string
+}
+
+func (f *fakeFleetAWS) CallerIdentity() (string, error) {
+	return "arn:aws:iam::123456789012:user/barney", nil
+}
+
+func (f *fakeFleetAWS) InstanceDescribe(tags map[string]string, states []string) (*aws.InstanceDescription, error) {
+	for _, instance := range f.instances {
+		if instance.Tags["owner"] == tags["owner"] {
+			return instance, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeFleetAWS) InstanceList(tags map[string]string, states []string) ([]*aws.InstanceDescription, error) {
+	return f.instances, nil
+}
+
+func (f *fakeFleetAWS) InstanceTag(instanceId string, tags map[string]string) error {
+	f.tags[instanceId] = tags
+	return nil
+}
+
+func (f *fakeFleetAWS) InstanceTerminate(instanceId string) error {
+	if f.failingIds[instanceId] {
+		return errors.New("UnauthorizedOperation")
+	}
+	f.terminatedIds = append(f.terminatedIds, instanceId)
+	return nil
+}
+
+func expiringInstance(id string, owner string, expiresIn time.Duration) *aws.InstanceDescription {
+	tags := map[string]string{"owner": owner, "managed_by": "docker-remote"}
+
+	if expiresIn != 0 {
+		tags[expiresAtTag] = time.Now().Add(expiresIn).UTC().Format(time.RFC3339)
+	}
+
+	return &aws.InstanceDescription{Id: &id, State: "running", Tags: tags}
+}
+
+func stubbedFleet(ctrl *gomock.Controller, instances ...*aws.InstanceDescription) (*ec2HostImpl, *fakeFleetAWS) {
+	userMock := mock_user.NewMockUser(ctrl)
+	userMock.EXPECT().Current().Return(&user.User{Username: "barney"}, nil).AnyTimes()
+
+	awsFake := &fakeFleetAWS{
+		instances:  instances,
+		failingIds: map[string]bool{},
+		tags:       map[string]map[string]string{},
+	}
+
+	return &ec2HostImpl{aws: awsFake, helpers: &pluginHelperImpl{user: userMock}}, awsFake
+}
+
+func TestReapTerminatesExpiredHosts(t *testing.T) {
+	// Tear up.
+	ctrl := gomock.NewController(t)
+	e, awsFake := stubbedFleet(
+		ctrl,
+		expiringInstance("i-1", "barney", -time.Hour),
+		expiringInstance("i-2", "fred", time.Hour),
+		expiringInstance("i-3", "wilma", 0),
+		expiringInstance("i-4", "fred", -time.Minute),
+	)
+
+	//Assertions
+	result, err := e.Reap(&ReapParams{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"i-1", "i-4"}, awsFake.terminatedIds)
+	assert.Len(t, result.Hosts, 2)
+	assert.Equal(t, "fred", result.Hosts[1].Owner)
+	assert.True(t, result.Hosts[1].Terminated)
+
+	ctrl.Finish()
+}
+
+func TestReapDryRun(t *testing.T) {
+	// Tear up.
+	ctrl := gomock.NewController(t)
+	e, awsFake := stubbedFleet(ctrl, expiringInstance("i-1", "barney", -time.Hour))
+
+	//Assertions
+	result, err := e.Reap(&ReapParams{DryRun: true})
+
+	assert.Nil(t, err)
+	assert.Empty(t, awsFake.terminatedIds)
+	assert.Len(t, result.Hosts, 1)
+	assert.False(t, result.Hosts[0].Terminated)
+
+	ctrl.Finish()
+}
+
+func TestReapFailsWhenTerminationFails(t *testing.T) {
+	// Tear up.
+	ctrl := gomock.NewController(t)
+	e, awsFake := stubbedFleet(
+		ctrl,
+		expiringInstance("i-1", "barney", -time.Hour),
+		expiringInstance("i-2", "fred", -time.Hour),
+	)
+	awsFake.failingIds["i-1"] = true
+
+	//Assertions
+	_, err := e.Reap(&ReapParams{})
+
+	assert.EqualError(t, err, "failed to terminate the expired hosts i-1")
+	assert.Equal(t, []string{"i-2"}, awsFake.terminatedIds, "the other expired hosts should still be reaped")
+
+	ctrl.Finish()
+}
+
+func TestExtend(t *testing.T) {
+	// Tear up.
+	ctrl := gomock.NewController(t)
+	e, awsFake := stubbedFleet(ctrl, expiringInstance("i-1", "arn:aws:iam::123456789012:user/barney", time.Minute))
+
+	//Assertions
+	result, err := e.Extend(&ExtendParams{TTL: 4 * time.Hour})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "i-1", result.ID)
+	assert.WithinDuration(t, time.Now().Add(4*time.Hour), result.ExpiresAt, 2*time.Second)
+	assert.Equal(t, expiryTags(result.ExpiresAt), awsFake.tags["i-1"])
+
+	ctrl.Finish()
+}
+
+func TestExtendRejectsNonPositiveTTL(t *testing.T) {
+	// Tear up.
+	ctrl := gomock.NewController(t)
+	e, awsFake := stubbedFleet(ctrl, expiringInstance("i-1", "arn:aws:iam::123456789012:user/barney", time.Minute))
+
+	//Assertions
+	for _, ttl := range []time.Duration{0, -time.Hour} {
+		_, err := e.Extend(&ExtendParams{TTL: ttl})
+		assert.EqualError(t, err, "the time-to-live must be positive, got "+ttl.String())
+	}
+
+	assert.Empty(t, awsFake.tags)
+
+	ctrl.Finish()
+}
+
+func TestInstanceExpiry(t *testing.T) {
+	// Tear up.
+	id := "i-1"
+	expiresAt := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	//Assertions
+	assert.Equal(t, &expiresAt, instanceExpiry(&aws.InstanceDescription{Id: &id, Tags: expiryTags(expiresAt)}))
+	assert.Nil(t, instanceExpiry(&aws.InstanceDescription{Id: &id, Tags: map[string]string{}}))
+	assert.Nil(t, instanceExpiry(&aws.InstanceDescription{Id: &id, Tags: map[string]string{expiresAtTag: "tomorrow"}}))
+}
@@ -2,20 +2,41 @@ package host
 
 import (
 	"log"
+
+	"github.com/knlambert/docker-remote.git/pkg/plugin"
 )
 
 type Driver string
 
 const (
-	EC2 Driver = "ec2"
+	DigitalOcean Driver = "digitalocean"
+	EC2          Driver = "ec2"
+	GCE          Driver = "gce"
+	OpenStack    Driver = "openstack"
+	Proxmox      Driver = "proxmox"
+	SSH          Driver = "ssh"
 )
 
 
 func BuildHostImplementation(requestedDriver string) DockerHostSystem {
 	driver := Driver(requestedDriver)
 	switch driver {
+	case DigitalOcean:
+		return CreateDigitalOceanHost()
 	case EC2:
 		return CreateEC2Host()
+	case GCE:
+		return CreateGCEHost()
+	case OpenStack:
+		return CreateOpenStackHost()
+	case Proxmox:
+		return CreateProxmoxHost()
+	case SSH:
+		return CreateSSHHost()
+	}
+	//Drivers not built in may be provided by a plugin.
+	if driverPlugin := plugin.Find(requestedDriver); driverPlugin != nil {
+		return CreatePluginHost(driverPlugin)
 	}
 	log.Fatalf("Can't find any implementation '%s'", requestedDriver)
 	return nil
@@ -0,0 +1,403 @@
+package host
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/knlambert/docker-remote.git/pkg/host/gcp"
+	"github.com/knlambert/docker-remote.git/pkg/output"
+	"github.com/knlambert/docker-remote.git/pkg/provision"
+	"github.com/knlambert/docker-remote.git/pkg/sshutil"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+//The docker context (and SSH alias) of the host.
+const gceDockerContextName = "docker-remote-gce"
+
+var (
+	//The statuses of an instance that is, or will be, running.
+	gceActiveStatuses = []string{"PROVISIONING", "STAGING", "RUNNING"}
+	//Every status but the deleted ones.
+	gceStatuses = []string{"PROVISIONING", "STAGING", "RUNNING", "STOPPING", "SUSPENDING", "SUSPENDED", "TERMINATED"}
+)
+
+func CreateGCEHost() DockerHostSystem {
+	return &gceHostImpl{
+		gcp:     gcp.Create(),
+		helpers: CreatePluginHelpers(),
+	}
+}
+
+type gceHostImpl struct {
+	gcp     gcp.GCP
+	helpers PluginHelpers
+}
+
+type GCEUpParams struct {
+	MachineType string
+	Image       string
+	DiskSize    int64
+	Network     string
+	KeyPairPath string
+	//The public key given to the instance, KeyPairPath with a ".pub" suffix or ~/.ssh/id_rsa.pub when empty.
+	PublicKeyPath string
+	Timeout       time.Duration
+	Rollback      bool
+}
+
+func (g *gceHostImpl) CobraCommand(
+	command Command,
+) *cobra.Command {
+	switch command {
+	case Up:
+		upParams := GCEUpParams{}
+		upCmd := cobra.Command{
+			Use:   string(command),
+			Short: "Create a GCE instance running docker and use it as the docker host",
+			Run: func(cmd *cobra.Command, args []string) {
+				ctx, cancel := interruptibleContext()
+				defer cancel()
+
+				runAndPrint(cmd, func() (output.Result, error) {
+					return g.Up(ctx, &upParams)
+				})
+			},
+		}
+
+		upCmd.Flags().StringVarP(&upParams.MachineType, "machine-type", "", "e2-small", "The machine type")
+		upCmd.Flags().StringVarP(
+			&upParams.Image, "image", "", "projects/ubuntu-os-cloud/global/images/family/ubuntu-2004-lts",
+			"The boot image, a Debian or Ubuntu one",
+		)
+		upCmd.Flags().Int64VarP(&upParams.DiskSize, "disk-size", "", 20, "The boot disk size, in GB")
+		upCmd.Flags().StringVarP(&upParams.Network, "network", "", "default", "The VPC network of the instance")
+
+		addPublicKeyFlag(upCmd.Flags(), &upParams.PublicKeyPath, "injected in the instance")
+
+		addUpFlags(upCmd.Flags(), &upParams.KeyPairPath, &upParams.Timeout, &upParams.Rollback)
+
+		return &upCmd
+	}
+
+	return commonCommand(command, g, "Delete the docker host")
+}
+
+func (g *gceHostImpl) Cost() (*CostResult, error) {
+	return nil, errors.New("cost is not supported by the gce driver")
+}
+
+func (g *gceHostImpl) Extend(params interface{}) (*ExtendResult, error) {
+	return nil, errors.New("extend is not supported by the gce driver")
+}
+
+func (g *gceHostImpl) Reap(params interface{}) (*ReapResult, error) {
+	return nil, errors.New("reap is not supported by the gce driver")
+}
+
+func (g *gceHostImpl) Down(params interface{}) (*DownResult, error) {
+	labels, err := g.labels()
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to calculate metadata")
+	}
+
+	instance, err := g.gcp.InstanceDescribe(labels, gceStatuses)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := DownResult{}
+
+	if instance != nil {
+		if err := g.gcp.InstanceDelete(instance.Name); err != nil {
+			return nil, errors.Wrapf(err, "failed to shutdown the docker host")
+		}
+
+		result.ID = instance.Name
+		result.Terminated = true
+	}
+
+	if err := g.helpers.SSHUtils().SSHAgentRemoveKey(agentKeyComment(gceDockerContextName)); err != nil {
+		return nil, err
+	}
+
+	if err := g.helpers.SSHUtils().SSHConfigRemove(gceDockerContextName); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+//Lists every docker host managed by docker-remote, whoever owns it.
+func (g *gceHostImpl) List() (*ListResult, error) {
+	instances, err := g.gcp.InstanceList(map[string]string{"managed_by": "docker-remote"}, gceStatuses)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list gce hosts")
+	}
+
+	result := ListResult{Hosts: []HostSummary{}}
+
+	for _, instance := range instances {
+		result.Hosts = append(result.Hosts, *summarizeGCEInstance(instance))
+	}
+
+	return &result, nil
+}
+
+func (g *gceHostImpl) PortForward(params interface{}) error {
+	return forwardTo(g.helpers, params, g.runningTarget)
+}
+
+func (g *gceHostImpl) Shell(params interface{}) error {
+	return shellTo(g.helpers, g.runningTarget)
+}
+
+//Describes the docker host of the current user.
+func (g *gceHostImpl) Status() (*StatusResult, error) {
+	labels, err := g.labels()
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to calculate metadata")
+	}
+
+	instance, err := g.gcp.InstanceDescribe(labels, gceStatuses)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to describe gce host")
+	}
+
+	if instance == nil {
+		return &StatusResult{}, nil
+	}
+
+	return &StatusResult{Host: summarizeGCEInstance(instance)}, nil
+}
+
+func summarizeGCEInstance(instance *gcp.InstanceDescription) *HostSummary {
+	return &HostSummary{
+		ID:           instance.Name,
+		Owner:        instance.Labels["owner"],
+		State:        strings.ToLower(instance.Status),
+		InstanceType: instance.MachineType,
+		LaunchTime:   instance.LaunchTime,
+		Address:      instance.PublicIp,
+	}
+}
+
+func (g *gceHostImpl) Up(ctx context.Context, params interface{}) (*UpResult, error) {
+	upParams := params.(*GCEUpParams)
+
+	labels, err := g.labels()
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to calculate metadata")
+	}
+
+	//The owner label is a valid user name too.
+	sshUser := labels["owner"]
+
+	var instance *gcp.InstanceDescription
+	var createdInstance string
+
+	completed, err := RunSteps(ctx, []Step{{
+		Name: "instance creation",
+		Run: func(ctx context.Context) error {
+			instance, err = g.gcp.InstanceDescribe(labels, gceStatuses)
+
+			if err != nil {
+				return errors.Wrap(err, "failed to describe gce host")
+			}
+
+			if instance != nil {
+				if !sliceContainsString(gceActiveStatuses, instance.Status) {
+					return errors.Errorf(
+						"instance %s is %s, start it or delete it with down", instance.Name, strings.ToLower(instance.Status),
+					)
+				}
+				return nil
+			}
+
+			publicKey, err := g.helpers.PublicKey(upParams.PublicKeyPath, upParams.KeyPairPath)
+
+			if err != nil {
+				return err
+			}
+
+			startupScript, err := gceStartupScript(sshUser)
+
+			if err != nil {
+				return err
+			}
+
+			name, err := g.gcp.InstanceCreate(&gcp.InstanceCreateParams{
+				Name:          fmt.Sprintf("docker-remote-%s", strings.Replace(sshUser, "_", "-", -1)),
+				MachineType:   upParams.MachineType,
+				Image:         upParams.Image,
+				DiskSizeGb:    upParams.DiskSize,
+				StartupScript: startupScript,
+				SSHKeys:       map[string]string{sshUser: publicKey},
+				Labels:        labels,
+				Network:       upParams.Network,
+			})
+
+			if err != nil {
+				return err
+			}
+
+			createdInstance = name
+			log.Printf("Instance %s created", name)
+			instance = &gcp.InstanceDescription{Name: name, Labels: labels}
+
+			return nil
+		},
+		Rollback: func() error {
+			if createdInstance == "" {
+				return nil
+			}
+			return g.gcp.InstanceDelete(createdInstance)
+		},
+		Resource: func() string {
+			if createdInstance == "" {
+				return ""
+			}
+			return fmt.Sprintf("GCE instance %s", createdInstance)
+		},
+	}, agentKeyStep(g.helpers, gceDockerContextName, upParams.KeyPairPath), {
+		Name: "readiness",
+		Run: func(ctx context.Context) error {
+			if err := createReadinessWaiter(upParams.Timeout).Wait(
+				ctx, g.readinessStages(labels, sshUser, &instance),
+			); err != nil {
+				return err
+			}
+
+			log.Println("Instance is ready !")
+
+			return nil
+		},
+	}, dockerContextStep(g.helpers, gceDockerContextName, func() (*sshutil.Target, error) {
+		return gceInstanceTarget(instance, sshUser), nil
+	}, nil)})
+
+	if err != nil {
+		HandleStepsFailure(completed, upParams.Rollback)
+		return nil, err
+	}
+
+	if err := g.gcp.LocationRecord(); err != nil {
+		log.Printf("Failed to record the project and zone of the host: %s", err)
+	}
+
+	return &UpResult{
+		ID:            instance.Name,
+		Address:       instance.PublicIp,
+		DockerContext: gceDockerContextName,
+		Created:       createdInstance != "",
+	}, nil
+}
+
+//Stages a freshly started instance goes through before docker can be used on it.
+func (g *gceHostImpl) readinessStages(
+	labels map[string]string,
+	sshUser string,
+	instance **gcp.InstanceDescription,
+) []ReadinessStage {
+	sshUtils := g.helpers.SSHUtils()
+
+	return []ReadinessStage{{
+		Name: "instance running",
+		Probe: func(ctx context.Context) error {
+			ready, err := g.gcp.InstanceIsReady((*instance).Name)
+
+			if err != nil {
+				return err
+			} else if !ready {
+				return errors.New("instance is not running yet")
+			}
+
+			*instance, err = g.gcp.InstanceDescribe(labels, []string{"RUNNING"})
+
+			if err == nil && (*instance == nil || (*instance).PublicIp == "") {
+				return errors.New("instance has no external IP yet")
+			}
+
+			return err
+		},
+	}, {
+		Name: "SSH",
+		Probe: func(ctx context.Context) error {
+			_, err := sshUtils.SSHRun(gceInstanceTarget(*instance, sshUser), "true")
+			return err
+		},
+	}, {
+		Name: "docker daemon",
+		Probe: func(ctx context.Context) error {
+			return sshUtils.DockerPing(gceInstanceTarget(*instance, sshUser))
+		},
+	}}
+}
+
+func gceInstanceTarget(instance *gcp.InstanceDescription, sshUser string) *sshutil.Target {
+	return &sshutil.Target{Endpoint: sshutil.Endpoint{User: sshUser, Host: instance.PublicIp}}
+}
+
+//Returns the instance of the current user, fails if it is not running.
+func (g *gceHostImpl) runningTarget() (*sshutil.Target, error) {
+	labels, err := g.labels()
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to calculate metadata")
+	}
+
+	instance, err := g.gcp.InstanceDescribe(labels, []string{"RUNNING"})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if instance == nil {
+		return nil, errors.Errorf("Please create the host first")
+	}
+
+	return gceInstanceTarget(instance, labels["owner"]), nil
+}
+
+//The default metadata, as GCE labels.
+func (g *gceHostImpl) labels() (map[string]string, error) {
+	metadata, err := g.helpers.DefaultMetadata()
+
+	if err != nil {
+		return nil, err
+	}
+
+	labels := map[string]string{}
+
+	for key, value := range metadata {
+		labels[key] = gcp.LabelValue(value)
+	}
+
+	return labels, nil
+}
+
+//Returns the startup-script of the instances: it creates the SSH user (the guest agent may
+//not have yet), then installs docker on the Debian based image.
+func gceStartupScript(sshUser string) (string, error) {
+	install, err := provision.DockerInstallScript(
+		&provision.Distribution{ID: "debian", Name: "Debian"}, sshUser,
+	)
+
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(
+		"#!/bin/bash\nid -u %s >/dev/null 2>&1 || useradd -m -s /bin/bash %s\n%s",
+		sshUser, sshUser, install,
+	), nil
+}
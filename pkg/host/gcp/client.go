@@ -0,0 +1,223 @@
+package gcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+//The Compute Engine REST API, the subset docker-remote uses.
+type Compute interface {
+	InstancesDelete(project string, zone string, name string) (*Operation, error)
+	InstancesGet(project string, zone string, name string) (*Instance, error)
+	InstancesInsert(project string, zone string, instance *Instance) (*Operation, error)
+	InstancesList(project string, zone string, filter string) ([]*Instance, error)
+}
+
+//A Compute Engine instance, as represented by the REST API.
+type Instance struct {
+	Name              string             `json:"name"`
+	MachineType       string             `json:"machineType,omitempty"`
+	Status            string             `json:"status,omitempty"`
+	Zone              string             `json:"zone,omitempty"`
+	CreationTimestamp string             `json:"creationTimestamp,omitempty"`
+	Labels            map[string]string  `json:"labels,omitempty"`
+	Metadata          *Metadata          `json:"metadata,omitempty"`
+	Disks             []AttachedDisk     `json:"disks,omitempty"`
+	NetworkInterfaces []NetworkInterface `json:"networkInterfaces,omitempty"`
+}
+
+type Metadata struct {
+	Items []MetadataItem `json:"items,omitempty"`
+}
+
+type MetadataItem struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type AttachedDisk struct {
+	Boot             bool                    `json:"boot,omitempty"`
+	AutoDelete       bool                    `json:"autoDelete,omitempty"`
+	InitializeParams *AttachedDiskInitParams `json:"initializeParams,omitempty"`
+}
+
+type AttachedDiskInitParams struct {
+	SourceImage string `json:"sourceImage,omitempty"`
+	DiskSizeGb  int64  `json:"diskSizeGb,string,omitempty"`
+}
+
+type NetworkInterface struct {
+	Network       string         `json:"network,omitempty"`
+	NetworkIP     string         `json:"networkIP,omitempty"`
+	AccessConfigs []AccessConfig `json:"accessConfigs,omitempty"`
+}
+
+type AccessConfig struct {
+	Type  string `json:"type,omitempty"`
+	Name  string `json:"name,omitempty"`
+	NatIP string `json:"natIP,omitempty"`
+}
+
+//A long running operation of the API.
+type Operation struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  *struct {
+		Errors []struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	} `json:"error,omitempty"`
+}
+
+//The API endpoint.
+const computeURL = "https://compute.googleapis.com/compute/v1"
+
+func createRESTCompute(baseURL string, token func() (string, error)) Compute {
+	return &restCompute{
+		baseURL: baseURL,
+		token:   token,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+type restCompute struct {
+	baseURL string
+	//Returns the OAuth access token of the requests.
+	token  func() (string, error)
+	client *http.Client
+}
+
+func (c *restCompute) InstancesDelete(project string, zone string, name string) (*Operation, error) {
+	var operation Operation
+	return &operation, c.do(http.MethodDelete, c.instancesPath(project, zone)+"/"+name, nil, &operation)
+}
+
+//Returns an instance, nil if it does not exist.
+func (c *restCompute) InstancesGet(project string, zone string, name string) (*Instance, error) {
+	var instance Instance
+
+	if err := c.do(http.MethodGet, c.instancesPath(project, zone)+"/"+name, nil, &instance); err != nil {
+		if apiErr, ok := errors.Cause(err).(*APIError); ok && apiErr.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &instance, nil
+}
+
+func (c *restCompute) InstancesInsert(project string, zone string, instance *Instance) (*Operation, error) {
+	var operation Operation
+	return &operation, c.do(http.MethodPost, c.instancesPath(project, zone), instance, &operation)
+}
+
+func (c *restCompute) InstancesList(project string, zone string, filter string) ([]*Instance, error) {
+	var instances []*Instance
+	pageToken := ""
+
+	for {
+		query := url.Values{}
+		query.Set("filter", filter)
+
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+
+		var page struct {
+			Items         []*Instance `json:"items"`
+			NextPageToken string      `json:"nextPageToken"`
+		}
+
+		if err := c.do(http.MethodGet, c.instancesPath(project, zone)+"?"+query.Encode(), nil, &page); err != nil {
+			return nil, err
+		}
+
+		instances = append(instances, page.Items...)
+
+		if pageToken = page.NextPageToken; pageToken == "" {
+			return instances, nil
+		}
+	}
+}
+
+func (c *restCompute) instancesPath(project string, zone string) string {
+	return fmt.Sprintf("/projects/%s/zones/%s/instances", url.PathEscape(project), url.PathEscape(zone))
+}
+
+//An error answered by the API.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("compute API answered %d: %s", e.StatusCode, e.Message)
+}
+
+//Sends an authenticated JSON request, decodes the response into out.
+func (c *restCompute) do(method string, path string, in interface{}, out interface{}) error {
+	var body bytes.Buffer
+
+	if in != nil {
+		if err := json.NewEncoder(&body).Encode(in); err != nil {
+			return errors.Wrap(err, "failed to encode the request")
+		}
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, &body)
+
+	if err != nil {
+		return errors.Wrap(err, "failed to create the request")
+	}
+
+	token, err := c.token()
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := c.client.Do(req)
+
+	if err != nil {
+		return errors.Wrapf(err, "failed to call %s %s", method, path)
+	}
+
+	defer res.Body.Close()
+
+	content, err := ioutil.ReadAll(res.Body)
+
+	if err != nil {
+		return errors.Wrap(err, "failed to read the response")
+	}
+
+	if res.StatusCode >= 300 {
+		var failure struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+
+		_ = json.Unmarshal(content, &failure)
+
+		return &APIError{StatusCode: res.StatusCode, Message: failure.Error.Message}
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(content, out); err != nil {
+			return errors.Wrap(err, "failed to decode the response")
+		}
+	}
+
+	return nil
+}
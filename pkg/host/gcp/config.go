@@ -0,0 +1,29 @@
+package gcp
+
+import (
+	"github.com/spf13/pflag"
+)
+
+//Options of the Compute Engine clients, the gcloud configuration applies to empty ones.
+type SessionConfig struct {
+	Project string
+	Zone    string
+	//Overrides the Compute Engine API endpoint, for proxies and emulators.
+	EndpointURL string
+}
+
+//The session options given on the command line, see AddFlags.
+var CommandLineConfig = SessionConfig{}
+
+//Adds the flags filling CommandLineConfig.
+func AddFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(
+		&CommandLineConfig.Project, "gce-project", "", "",
+		"The GCP project (default: the one recorded by up, then the gcloud configuration)",
+	)
+	flags.StringVarP(
+		&CommandLineConfig.Zone, "gce-zone", "", "",
+		"The GCE zone (default: the one recorded by up, then the gcloud configuration)",
+	)
+	flags.StringVarP(&CommandLineConfig.EndpointURL, "gce-endpoint-url", "", "", "Overrides the GCE API endpoint URL")
+}
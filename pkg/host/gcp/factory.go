@@ -0,0 +1,152 @@
+package gcp
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/knlambert/docker-remote.git/pkg/state"
+	"github.com/pkg/errors"
+)
+
+const (
+	//The state keys of the project and zone the host was created in.
+	projectStateKey = "project"
+	zoneStateKey    = "zone"
+	//The environment variable holding an OAuth access token, gcloud is used otherwise.
+	accessTokenEnv = "GOOGLE_OAUTH_ACCESS_TOKEN"
+)
+
+type Factory interface {
+	Compute() (Compute, error)
+	//The project the clients work in.
+	Project() (string, error)
+	//The zone the clients work in.
+	Zone() (string, error)
+	//Records the project and zone, used by the next commands when none is given.
+	LocationRecord() error
+}
+
+func CreateFactory(config *SessionConfig) Factory {
+	return &factoryImpl{
+		config: config,
+		state:  state.CreateStore(),
+		gcloud: gcloud,
+	}
+}
+
+type factoryImpl struct {
+	config *SessionConfig
+	state  state.Store
+	//Runs a gcloud command, returns its trimmed output.
+	gcloud func(args ...string) (string, error)
+
+	//The access token, fetched once on first use.
+	once    sync.Once
+	token   string
+	failure error
+}
+
+func (f *factoryImpl) Compute() (Compute, error) {
+	baseURL := f.config.EndpointURL
+
+	if baseURL == "" {
+		baseURL = computeURL
+	}
+
+	return createRESTCompute(strings.TrimSuffix(baseURL, "/"), f.accessToken), nil
+}
+
+//Returns the project from the configuration, the recorded one, the environment then gcloud.
+func (f *factoryImpl) Project() (string, error) {
+	return f.resolve(
+		"project", f.config.Project, projectStateKey,
+		[]string{"CLOUDSDK_CORE_PROJECT", "GOOGLE_CLOUD_PROJECT"}, "core/project",
+	)
+}
+
+//Returns the zone from the configuration, the recorded one, the environment then gcloud.
+func (f *factoryImpl) Zone() (string, error) {
+	return f.resolve(
+		"zone", f.config.Zone, zoneStateKey,
+		[]string{"CLOUDSDK_COMPUTE_ZONE"}, "compute/zone",
+	)
+}
+
+func (f *factoryImpl) LocationRecord() error {
+	project, err := f.Project()
+
+	if err != nil {
+		return err
+	}
+
+	zone, err := f.Zone()
+
+	if err != nil {
+		return err
+	}
+
+	if err := f.state.Set("gce", projectStateKey, project); err != nil {
+		return err
+	}
+
+	return f.state.Set("gce", zoneStateKey, zone)
+}
+
+func (f *factoryImpl) resolve(
+	name string,
+	configured string,
+	stateKey string,
+	envs []string,
+	property string,
+) (string, error) {
+	if configured != "" {
+		return configured, nil
+	}
+
+	recorded, err := f.state.Get("gce", stateKey)
+
+	if err != nil || recorded != "" {
+		return recorded, err
+	}
+
+	for _, env := range envs {
+		if value := os.Getenv(env); value != "" {
+			return value, nil
+		}
+	}
+
+	if value, err := f.gcloud("config", "get-value", property); err == nil && value != "" {
+		return value, nil
+	}
+
+	return "", errors.Errorf("no GCP %s configured, use --gce-%s", name, name)
+}
+
+//Returns the access token of the API calls.
+func (f *factoryImpl) accessToken() (string, error) {
+	f.once.Do(func() {
+		if f.token = os.Getenv(accessTokenEnv); f.token != "" {
+			return
+		}
+
+		if f.token, f.failure = f.gcloud("auth", "print-access-token"); f.failure != nil {
+			f.failure = errors.Wrapf(
+				f.failure, "failed to get a GCP access token, run 'gcloud auth login' or set %s", accessTokenEnv,
+			)
+		}
+	})
+
+	return f.token, f.failure
+}
+
+func gcloud(args ...string) (string, error) {
+	out, err := exec.Command("gcloud", args...).Output()
+
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to run gcloud %s", strings.Join(args, " "))
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,296 @@
+package gcp
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type GCP interface {
+	//Creates an instance, returns its name.
+	InstanceCreate(params *InstanceCreateParams) (string, error)
+	//Returns the first instance matching the labels, in one of the statuses, nil if there is none.
+	InstanceDescribe(labels map[string]string, statuses []string) (*InstanceDescription, error)
+	InstanceDelete(name string) error
+	InstanceIsReady(name string) (bool, error)
+	InstanceList(labels map[string]string, statuses []string) ([]*InstanceDescription, error)
+	//Records the project and zone, used by the next commands when none is given.
+	LocationRecord() error
+}
+
+func Create() GCP {
+	return &gcpImpl{
+		CreateFactory(&CommandLineConfig),
+	}
+}
+
+type gcpImpl struct {
+	factory Factory
+}
+
+type InstanceCreateParams struct {
+	Name        string
+	MachineType string
+	//The source image, a full or partial URL ("projects/ubuntu-os-cloud/global/images/family/ubuntu-2004-lts").
+	Image      string
+	DiskSizeGb int64
+	//The script run as root at each boot.
+	StartupScript string
+	//The SSH users and their public keys, added by the guest agent.
+	SSHKeys map[string]string
+	Labels  map[string]string
+	//The network of the instance, "default" when empty.
+	Network string
+}
+
+func (g *gcpImpl) InstanceCreate(params *InstanceCreateParams) (string, error) {
+	c, project, zone, err := g.location()
+
+	if err != nil {
+		return "", err
+	}
+
+	network := params.Network
+
+	if network == "" {
+		network = "default"
+	}
+
+	instance := Instance{
+		Name:        params.Name,
+		MachineType: fmt.Sprintf("zones/%s/machineTypes/%s", zone, params.MachineType),
+		Labels:      params.Labels,
+		Metadata: &Metadata{Items: []MetadataItem{
+			{Key: "startup-script", Value: params.StartupScript},
+			{Key: "ssh-keys", Value: sshKeysMetadata(params.SSHKeys)},
+		}},
+		Disks: []AttachedDisk{{
+			Boot:       true,
+			AutoDelete: true,
+			InitializeParams: &AttachedDiskInitParams{
+				SourceImage: params.Image,
+				DiskSizeGb:  params.DiskSizeGb,
+			},
+		}},
+		NetworkInterfaces: []NetworkInterface{{
+			Network:       fmt.Sprintf("global/networks/%s", network),
+			AccessConfigs: []AccessConfig{{Type: "ONE_TO_ONE_NAT", Name: "External NAT"}},
+		}},
+	}
+
+	operation, err := c.InstancesInsert(project, zone, &instance)
+
+	if err != nil {
+		return "", errors.Wrap(err, "failed to kick a VM in GCE")
+	}
+
+	if err := operationError(operation); err != nil {
+		return "", errors.Wrap(err, "failed to kick a VM in GCE")
+	}
+
+	return params.Name, nil
+}
+
+//Formats the ssh-keys metadata, one "user:public key" per line.
+func sshKeysMetadata(keys map[string]string) string {
+	var lines []string
+
+	for user, key := range keys {
+		lines = append(lines, fmt.Sprintf("%s:%s", user, strings.TrimSpace(key)))
+	}
+
+	sort.Strings(lines)
+
+	return strings.Join(lines, "\n")
+}
+
+type InstanceDescription struct {
+	Name        string
+	PublicIp    string
+	PrivateIp   string
+	Status      string
+	MachineType string
+	Zone        string
+	LaunchTime  *time.Time
+	Labels      map[string]string
+}
+
+func (g *gcpImpl) InstanceDescribe(
+	labels map[string]string, statuses []string,
+) (*InstanceDescription, error) {
+	instances, err := g.InstanceList(labels, statuses)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(instances) > 0 {
+		return instances[0], nil
+	}
+
+	return nil, nil
+}
+
+//Returns the instances matching the labels, in one of the statuses.
+func (g *gcpImpl) InstanceList(
+	labels map[string]string, statuses []string,
+) ([]*InstanceDescription, error) {
+	c, project, zone, err := g.location()
+
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.InstancesList(project, zone, labelsFilter(labels))
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list the GCE instances")
+	}
+
+	var instances []*InstanceDescription
+
+	for _, instance := range res {
+		for _, status := range statuses {
+			if instance.Status == status {
+				instances = append(instances, describeInstance(instance))
+				break
+			}
+		}
+	}
+
+	return instances, nil
+}
+
+//Formats a list filter matching all the labels.
+func labelsFilter(labels map[string]string) string {
+	var expressions []string
+
+	for key, value := range labels {
+		expressions = append(expressions, fmt.Sprintf(`labels.%s = "%s"`, key, value))
+	}
+
+	sort.Strings(expressions)
+
+	return strings.Join(expressions, " AND ")
+}
+
+func describeInstance(instance *Instance) *InstanceDescription {
+	description := InstanceDescription{
+		Name:        instance.Name,
+		Status:      instance.Status,
+		MachineType: lastSegment(instance.MachineType),
+		Zone:        lastSegment(instance.Zone),
+		Labels:      instance.Labels,
+	}
+
+	if launchTime, err := time.Parse(time.RFC3339, instance.CreationTimestamp); err == nil {
+		description.LaunchTime = &launchTime
+	}
+
+	for _, networkInterface := range instance.NetworkInterfaces {
+		description.PrivateIp = networkInterface.NetworkIP
+
+		for _, accessConfig := range networkInterface.AccessConfigs {
+			if accessConfig.NatIP != "" {
+				description.PublicIp = accessConfig.NatIP
+			}
+		}
+	}
+
+	return &description
+}
+
+func (g *gcpImpl) InstanceIsReady(name string) (bool, error) {
+	c, project, zone, err := g.location()
+
+	if err != nil {
+		return false, err
+	}
+
+	instance, err := c.InstancesGet(project, zone, name)
+
+	if err != nil {
+		return false, errors.Wrapf(err, "can't describe instance %s", name)
+	}
+
+	return instance != nil && instance.Status == "RUNNING", nil
+}
+
+func (g *gcpImpl) InstanceDelete(name string) error {
+	c, project, zone, err := g.location()
+
+	if err != nil {
+		return err
+	}
+
+	operation, err := c.InstancesDelete(project, zone, name)
+
+	if err == nil {
+		err = operationError(operation)
+	}
+
+	return errors.Wrapf(err, "failed to delete instance %s", name)
+}
+
+func (g *gcpImpl) LocationRecord() error {
+	return g.factory.LocationRecord()
+}
+
+//Returns the client with the project and zone it works in.
+func (g *gcpImpl) location() (Compute, string, string, error) {
+	c, err := g.factory.Compute()
+
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	project, err := g.factory.Project()
+
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	zone, err := g.factory.Zone()
+
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	return c, project, zone, nil
+}
+
+//Returns the errors an operation reported, if any.
+func operationError(operation *Operation) error {
+	if operation == nil || operation.Error == nil || len(operation.Error.Errors) == 0 {
+		return nil
+	}
+
+	var messages []string
+
+	for _, failure := range operation.Error.Errors {
+		messages = append(messages, fmt.Sprintf("%s: %s", failure.Code, failure.Message))
+	}
+
+	return errors.New(strings.Join(messages, ", "))
+}
+
+func lastSegment(url string) string {
+	return url[strings.LastIndex(url, "/")+1:]
+}
+
+var labelInvalidCharacters = regexp.MustCompile(`[^a-z0-9_-]`)
+
+//Turns a value into a valid GCE label value: lowercase letters, digits, "_" and "-", 63 characters at most.
+func LabelValue(value string) string {
+	label := labelInvalidCharacters.ReplaceAllString(strings.ToLower(value), "_")
+
+	if len(label) > 63 {
+		label = label[:63]
+	}
+
+	return label
+}
@@ -0,0 +1,121 @@
+package gcp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeFactory struct {
+	baseURL string
+}
+
+func (f *fakeFactory) Compute() (Compute, error) {
+	return createRESTCompute(f.baseURL, func() (string, error) { return "token", nil }), nil
+}
+func (f *fakeFactory) Project() (string, error) { return "builds", nil }
+func (f *fakeFactory) Zone() (string, error)    { return "europe-west1-b", nil }
+func (f *fakeFactory) LocationRecord() error    { return nil }
+
+func TestInstanceCreate(t *testing.T) {
+	// Tear up.
+	var received Instance
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/projects/builds/zones/europe-west1-b/instances", r.URL.Path)
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&received))
+
+		_, _ = w.Write([]byte(`{"name": "operation-1", "status": "RUNNING"}`))
+	}))
+	defer server.Close()
+
+	g := &gcpImpl{&fakeFactory{baseURL: server.URL}}
+
+	//Assertions
+	name, err := g.InstanceCreate(&InstanceCreateParams{
+		Name:          "docker-remote-barney",
+		MachineType:   "e2-small",
+		Image:         "projects/ubuntu-os-cloud/global/images/family/ubuntu-2004-lts",
+		StartupScript: "#!/bin/bash\n",
+		SSHKeys:       map[string]string{"barney": "ssh-rsa AAAA barney\n"},
+		Labels:        map[string]string{"owner": "barney", "managed_by": "docker-remote"},
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "docker-remote-barney", name)
+	assert.Equal(t, "zones/europe-west1-b/machineTypes/e2-small", received.MachineType)
+	assert.Equal(t, "global/networks/default", received.NetworkInterfaces[0].Network)
+	assert.Equal(t, []MetadataItem{
+		{Key: "startup-script", Value: "#!/bin/bash\n"},
+		{Key: "ssh-keys", Value: "barney:ssh-rsa AAAA barney"},
+	}, received.Metadata.Items)
+	assert.Equal(t, "barney", received.Labels["owner"])
+}
+
+func TestInstanceList(t *testing.T) {
+	// Tear up.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, `labels.managed_by = "docker-remote" AND labels.owner = "barney"`, r.URL.Query().Get("filter"))
+
+		if r.URL.Query().Get("pageToken") == "" {
+			_, _ = w.Write([]byte(`{"items": [{
+				"name": "docker-remote-barney",
+				"status": "TERMINATED",
+				"machineType": "https://compute.googleapis.com/compute/v1/projects/builds/zones/europe-west1-b/machineTypes/e2-small"
+			}], "nextPageToken": "2"}`))
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"items": [{
+			"name": "docker-remote-barney-2",
+			"status": "RUNNING",
+			"creationTimestamp": "2020-11-02T08:12:41.521-08:00",
+			"machineType": "https://compute.googleapis.com/compute/v1/projects/builds/zones/europe-west1-b/machineTypes/e2-medium",
+			"networkInterfaces": [{"networkIP": "10.132.0.2", "accessConfigs": [{"natIP": "34.76.1.2"}]}]
+		}]}`))
+	}))
+	defer server.Close()
+
+	g := &gcpImpl{&fakeFactory{baseURL: server.URL}}
+
+	//Assertions
+	instance, err := g.InstanceDescribe(
+		map[string]string{"owner": "barney", "managed_by": "docker-remote"},
+		[]string{"PROVISIONING", "STAGING", "RUNNING"},
+	)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "docker-remote-barney-2", instance.Name)
+	assert.Equal(t, "e2-medium", instance.MachineType)
+	assert.Equal(t, "34.76.1.2", instance.PublicIp)
+	assert.Equal(t, "10.132.0.2", instance.PrivateIp)
+	assert.NotNil(t, instance.LaunchTime)
+}
+
+func TestInstanceIsReadyNotFound(t *testing.T) {
+	// Tear up.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error": {"message": "not found"}}`))
+	}))
+	defer server.Close()
+
+	g := &gcpImpl{&fakeFactory{baseURL: server.URL}}
+
+	//Assertions
+	ready, err := g.InstanceIsReady("docker-remote-barney")
+
+	assert.Nil(t, err)
+	assert.False(t, ready)
+}
+
+func TestLabelValue(t *testing.T) {
+	//Assertions
+	assert.Equal(t, "kevin_lambert", LabelValue("Kevin.Lambert"))
+	assert.Equal(t, "corp_barney", LabelValue(`CORP\barney`))
+}
@@ -1,29 +1,56 @@
 package host
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+
 	"github.com/knlambert/docker-remote.git/pkg/docker"
+	"github.com/knlambert/docker-remote.git/pkg/provision"
 	"github.com/knlambert/docker-remote.git/pkg/sshutil"
+	"github.com/knlambert/docker-remote.git/pkg/std/ioutil"
 	"github.com/knlambert/docker-remote.git/pkg/std/user"
 	"github.com/pkg/errors"
 )
 
 type PluginHelpers interface {
 	DefaultMetadata() (map[string]string, error)
-	RegisterToDocker(name string, dockerHost string) error
+	//Returns the tags of the hosts created by the versions owning them by the display name of the
+	//local user, nil when the user has none.
+	LegacyMetadata() (map[string]string, error)
+	//Returns the docker host URL of a target, registering an SSH alias when needed.
+	DockerHost(alias string, target *sshutil.Target) (string, error)
+	Provision() provision.Provision
+	//Reads the public key given to new hosts: the given path, the key pair path with a ".pub"
+	//suffix, then ~/.ssh/id_rsa.pub.
+	PublicKey(publicKeyPath string, keyPairPath string) (string, error)
+	//Registers the docker context of a host, the metadata is recorded along with it.
+	RegisterToDocker(name string, dockerHost string, metadata map[string]string) error
+	//Copies local registry credentials to the docker configuration of a host user.
+	RegistrySync(target *sshutil.Target, registries []string) ([]string, error)
 	SSHUtils() sshutil.SSHUtils
+	UnregisterFromDocker(name string) error
 }
 
 func CreatePluginHelpers() PluginHelpers {
 	return &pluginHelperImpl{
 		user:   user.CreateUser(),
+		io:     ioutil.CreateIOUtil(),
 		docker: docker.CreateDocker(),
+		provision: provision.CreateProvision(),
 		sshUtils: sshutil.CreateSSHUtils(),
 	}
 }
 
 type pluginHelperImpl struct {
 	user   user.User
+	io     ioutil.IOUtil
 	docker docker.Docker
+	provision provision.Provision
 	sshUtils sshutil.SSHUtils
 }
 
@@ -36,22 +63,312 @@ func (b *pluginHelperImpl) DefaultMetadata() (map[string]string, error) {
 		return nil, err
 	}
 
-	metadata["owner"] = currentUser.Name
+	//The display name is often empty, the login is not.
+	metadata["owner"] = currentUser.Username
 	metadata["managed_by"] = "docker-remote"
 
 	return metadata, nil
 }
 
+//Returns the tags of the hosts created by the versions owning them by the display name of the
+//local user, nil when the user has none.
+func (b *pluginHelperImpl) LegacyMetadata() (map[string]string, error) {
+	currentUser, err := b.user.Current()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if currentUser.Name == "" {
+		return nil, nil
+	}
+
+	return map[string]string{"owner": currentUser.Name, "managed_by": "docker-remote"}, nil
+}
+
+//Returns the docker host URL of a target. As docker relies on the ssh command line, a
+//target behind jump hosts or a proxy command is registered as an alias of the SSH config.
+func (b *pluginHelperImpl) DockerHost(alias string, target *sshutil.Target) (string, error) {
+	if len(target.Jumps) == 0 && target.ProxyCommand == "" {
+		return fmt.Sprintf("ssh://%s", target.String()), nil
+	}
+
+	if err := b.sshUtils.SSHConfigSet(alias, target); err != nil {
+		return "", errors.Wrap(err, "failed to configure the SSH alias of the host")
+	}
+
+	return fmt.Sprintf("ssh://%s", alias), nil
+}
+
 //Registers a docker service on the local machine leveraging the Docker contexts.
-func (b *pluginHelperImpl) RegisterToDocker(name string, dockerHost string) error {
-	if err := b.docker.ContextSet(name, dockerHost); err != nil {
+func (b *pluginHelperImpl) RegisterToDocker(name string, dockerHost string, metadata map[string]string) error {
+	if err := b.docker.ContextSet(name, dockerHost, metadata); err != nil {
 		return errors.Wrap(err, "failed to save the docker host to a docker context")
 	}
 	return nil
 }
 
+//Removes the docker context of a host from the local machine.
+func (b *pluginHelperImpl) UnregisterFromDocker(name string) error {
+	if err := b.docker.ContextRemove(name); err != nil {
+		return errors.Wrap(err, "failed to remove the docker context")
+	}
+	return nil
+}
+
+//Copies local registry credentials (all the known ones when none is given) to the docker
+//configuration of a host user, keeping the other settings and registries of the file.
+func (b *pluginHelperImpl) RegistrySync(target *sshutil.Target, registries []string) ([]string, error) {
+	auths, err := b.docker.RegistryAuths(registries)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the local registry credentials")
+	}
+
+	if len(auths) == 0 {
+		return nil, errors.New("no local registry credentials found, run docker login first")
+	}
+
+	config, err := readDockerConfig(b.sshUtils, target)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if store, ok := config["credsStore"]; ok {
+		log.Printf("Warning: docker on the host uses the '%s' credentials store, which takes precedence", store)
+	}
+
+	remoteAuths, _ := config["auths"].(map[string]interface{})
+
+	if remoteAuths == nil {
+		remoteAuths = map[string]interface{}{}
+	}
+
+	var synced []string
+
+	for registry, auth := range auths {
+		remoteAuths[registry] = auth
+		synced = append(synced, registry)
+	}
+
+	config["auths"] = remoteAuths
+
+	if err := writeDockerConfig(b.sshUtils, target, config); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(synced)
+
+	return synced, nil
+}
+
+//Reads the docker configuration of the host user, empty when there is none.
+func readDockerConfig(sshUtils sshutil.SSHUtils, target *sshutil.Target) (map[string]interface{}, error) {
+	current, err := sshUtils.SSHRun(target, "cat ~/.docker/config.json 2>/dev/null || true")
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the docker configuration of the host")
+	}
+
+	config := map[string]interface{}{}
+
+	if len(bytes.TrimSpace(current)) > 0 {
+		if err := json.Unmarshal(current, &config); err != nil {
+			return nil, errors.Wrap(err, "failed to parse the docker configuration of the host")
+		}
+	}
+
+	return config, nil
+}
+
+//Replaces the docker configuration of the host user, readable by them only.
+func writeDockerConfig(sshUtils sshutil.SSHUtils, target *sshutil.Target, config map[string]interface{}) error {
+	encoded, err := json.MarshalIndent(config, "", "  ")
+
+	if err != nil {
+		return errors.Wrap(err, "failed to encode the docker configuration")
+	}
+
+	if out, err := sshUtils.SSHRunInput(
+		target,
+		"umask 077 && mkdir -p ~/.docker && cat > ~/.docker/config.json && chmod 600 ~/.docker/config.json",
+		encoded,
+	); err != nil {
+		return errors.Wrapf(err, "failed to write the docker configuration of the host: %s", bytes.TrimSpace(out))
+	}
+
+	return nil
+}
+
+func (b *pluginHelperImpl) Provision() provision.Provision {
+	return b.provision
+}
+
+func (b *pluginHelperImpl) PublicKey(publicKeyPath string, keyPairPath string) (string, error) {
+	path := publicKeyPath
+
+	if path == "" && keyPairPath != "" {
+		path = keyPairPath + ".pub"
+	} else if path == "" {
+		currentUser, err := b.user.Current()
+
+		if err != nil {
+			return "", errors.Wrap(err, "failed to determine current user")
+		}
+
+		path = filepath.Join(currentUser.HomeDir, ".ssh", "id_rsa.pub")
+	}
+
+	content, err := b.io.ReadFile(path)
+
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read the public key %s, use --public-key-path", path)
+	}
+
+	return string(content), nil
+}
+
 func (b *pluginHelperImpl) SSHUtils() sshutil.SSHUtils {
 	return b.sshUtils
 }
 
 
+
+func sliceContainsString(s []string, v string) bool {
+	for _, value := range s {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+//Opens a shell on the host of a driver, located by target.
+func shellTo(helpers PluginHelpers, target func() (*sshutil.Target, error)) error {
+	t, err := target()
+
+	if err != nil {
+		return err
+	}
+
+	return helpers.SSHUtils().SSHConnection(t)
+}
+
+//Forwards a local port to the host of a driver, located by target, reporting the events to the
+//OnEvent of the params when set.
+func forwardTo(helpers PluginHelpers, params interface{}, target func() (*sshutil.Target, error)) error {
+	fwdParams := params.(*ForwardParams)
+
+	t, err := target()
+
+	if err != nil {
+		return err
+	}
+
+	return helpers.SSHUtils().LocalPortForward(
+		fwdParams.LocalPort,
+		fwdParams.RemoteAddr,
+		fwdParams.RemotePort,
+		t,
+		fwdParams.notify,
+	)
+}
+
+//The comment of the SSH agent key of a docker context, so that each driver only removes its own.
+func agentKeyComment(contextName string) string {
+	return contextName + "-key"
+}
+
+//The up step loading the key pair in the SSH agent, nothing to do without key pair.
+func agentKeyStep(helpers PluginHelpers, contextName string, keyPairPath string) Step {
+	var added bool
+
+	return Step{
+		Name: "SSH agent key",
+		Run: func(ctx context.Context) error {
+			if keyPairPath == "" {
+				return nil
+			}
+
+			if err := helpers.SSHUtils().SSHAgentAddKey(keyPairPath, agentKeyComment(contextName)); err != nil {
+				return err
+			}
+
+			added = true
+
+			return nil
+		},
+		Rollback: func() error {
+			if !added {
+				return nil
+			}
+			return helpers.SSHUtils().SSHAgentRemoveKey(agentKeyComment(contextName))
+		},
+		Resource: func() string {
+			if !added {
+				return ""
+			}
+			return fmt.Sprintf("SSH agent key %s", keyPairPath)
+		},
+	}
+}
+
+//The up step recording the host key of the host and registering its docker context. The host is
+//located by target, and the optional metadata of the context returned by metadata, both called
+//when the step runs as the host is only known once it is ready.
+func dockerContextStep(
+	helpers PluginHelpers,
+	name string,
+	target func() (*sshutil.Target, error),
+	metadata func() map[string]string,
+) Step {
+	//Set once the SSH alias or the context may have been written.
+	var registering bool
+
+	return Step{
+		Name: "docker context",
+		Run: func(ctx context.Context) error {
+			t, err := target()
+
+			if err != nil {
+				return err
+			}
+
+			if err := helpers.SSHUtils().SSHKnownHostsAdd(t); err != nil {
+				return errors.Wrap(err, "failed to record the host key")
+			}
+
+			registering = true
+			dockerHost, err := helpers.DockerHost(name, t)
+
+			if err != nil {
+				return err
+			}
+
+			var contextMetadata map[string]string
+
+			if metadata != nil {
+				contextMetadata = metadata()
+			}
+
+			return helpers.RegisterToDocker(name, dockerHost, contextMetadata)
+		},
+		Rollback: func() error {
+			if !registering {
+				return nil
+			}
+
+			if err := helpers.SSHUtils().SSHConfigRemove(name); err != nil {
+				return err
+			}
+			return helpers.UnregisterFromDocker(name)
+		},
+		Resource: func() string {
+			if !registering {
+				return ""
+			}
+			return fmt.Sprintf("docker context %s", name)
+		},
+	}
+}
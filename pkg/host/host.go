@@ -1,22 +1,228 @@
 package host
 
-import "github.com/spf13/cobra"
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/knlambert/docker-remote.git/pkg/output"
+	"github.com/knlambert/docker-remote.git/pkg/provision"
+	"github.com/pkg/errors"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
 
 type Command string
 
 const (
-	Down        Command = "down"
-	PortForward Command = "port-forward"
-	Shell       Command = "shell"
-	Up          Command = "up"
+	Adopt        Command = "adopt"
+	Attach       Command = "attach"
+	Cost         Command = "cost"
+	Down         Command = "down"
+	EcrLogin     Command = "ecr-login"
+	Extend       Command = "extend"
+	List         Command = "list"
+	PortForward  Command = "port-forward"
+	Reap         Command = "reap"
+	RegistrySync Command = "registry-sync"
+	Shell        Command = "shell"
+	SSMProxy     Command = "ssm-proxy"
+	Status       Command = "status"
+	Up           Command = "up"
 )
 
 type DockerHostSystem interface {
 	CobraCommand(
 		command Command,
 	) *cobra.Command
-	Down() error
+	Cost() (*CostResult, error)
+	Down(params interface{}) (*DownResult, error)
+	Extend(params interface{}) (*ExtendResult, error)
+	List() (*ListResult, error)
 	PortForward(params interface{}) error
+	Reap(params interface{}) (*ReapResult, error)
 	Shell(params interface{}) error
-	Up(params interface{}) error
+	Status() (*StatusResult, error)
+	Up(ctx context.Context, params interface{}) (*UpResult, error)
+}
+
+//Runs a command and prints its result in the format chosen with the global output flag.
+func runAndPrint(cmd *cobra.Command, run func() (output.Result, error)) {
+	printer, err := output.FromCommand(cmd)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	result, err := run()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := printer.Print(result); err != nil {
+		log.Fatal(err)
+	}
+}
+
+//Builds a command printing the result of run.
+func resultCommand(command Command, short string, run func() (output.Result, error)) *cobra.Command {
+	return &cobra.Command{
+		Use:   string(command),
+		Short: short,
+		Run: func(cmd *cobra.Command, args []string) {
+			runAndPrint(cmd, run)
+		},
+	}
+}
+
+//Builds the commands the drivers share: list, port-forward, shell, status, and down described by
+//downShort as what it does to the host differs. Returns nil for the other commands.
+func commonCommand(command Command, system DockerHostSystem, downShort string) *cobra.Command {
+	switch command {
+	case Down:
+		return resultCommand(command, downShort, func() (output.Result, error) {
+			return system.Down(nil)
+		})
+	case List:
+		return resultCommand(command, "List the docker hosts managed by docker-remote", func() (output.Result, error) {
+			return system.List()
+		})
+	case PortForward:
+		return portForwardCommand(command, system.PortForward)
+	case Shell:
+		return &cobra.Command{
+			Use:   string(command),
+			Short: "Open a shell to the remote host",
+			Run: func(cmd *cobra.Command, args []string) {
+				if err := system.Shell(nil); err != nil {
+					log.Fatal(err)
+				}
+			},
+		}
+	case Status:
+		return resultCommand(command, "Describe the docker host", func() (output.Result, error) {
+			return system.Status()
+		})
+	}
+
+	return nil
+}
+
+//Adds the flags every up command has: the key pair, the readiness timeout and the rollback.
+func addUpFlags(flags *pflag.FlagSet, keyPairPath *string, timeout *time.Duration, rollback *bool) {
+	flags.StringVarP(
+		keyPairPath,
+		"key-pair-path", "", "",
+		"The path to the PEM key file to use for connection",
+	)
+
+	flags.DurationVarP(
+		timeout, "timeout", "", 10*time.Minute,
+		"How long to wait for the host to be ready",
+	)
+
+	flags.BoolVarP(
+		rollback, "rollback", "", false,
+		"Remove the resources created so far if the command fails or is interrupted, without asking",
+	)
+}
+
+//Adds the flag of the public key given to new hosts, the way described by usage.
+func addPublicKeyFlag(flags *pflag.FlagSet, publicKeyPath *string, usage string) {
+	flags.StringVarP(
+		publicKeyPath,
+		"public-key-path", "", "",
+		fmt.Sprintf("The public key %s (default: the key pair path with a .pub suffix, then ~/.ssh/id_rsa.pub)", usage),
+	)
+}
+
+//Adds the flags of the provisioning script to an up command.
+func addUserDataFlags(flags *pflag.FlagSet, params *provision.Params, format *string, print *bool) {
+	flags.StringVarP(
+		format, "user-data-format", "", string(provision.Bash),
+		"The format of the default provisioning script (bash, cloud-config)",
+	)
+
+	flags.StringVarP(
+		&params.Template, "user-data-template", "", "",
+		"A template path or name (~/.docker-remote/templates/<name>.tmpl) replacing the default provisioning",
+	)
+
+	flags.StringToStringVarP(
+		&params.Vars, "user-data-var", "", map[string]string{},
+		"A variable given to the provisioning template, example: 'swap_size=2G'",
+	)
+
+	flags.StringVarP(
+		&params.VarsFile, "user-data-vars", "", "",
+		"A YAML file of variables given to the provisioning template",
+	)
+
+	flags.StringArrayVarP(
+		&params.Snippets, "user-data-file", "", []string{},
+		"A script appended to the provisioning (can be repeated)",
+	)
+
+	flags.BoolVarP(
+		print, "print-user-data", "", false,
+		"Print the rendered provisioning script and exit",
+	)
+}
+
+//Builds the port-forward command of a driver, printing the forwarding events.
+func portForwardCommand(command Command, forward func(params interface{}) error) *cobra.Command {
+	fwdParams := ForwardParams{}
+	fwdCmd := cobra.Command{
+		Use:   string(command),
+		Args:  cobra.MinimumNArgs(1),
+		Short: "Forward the connection from the remote host",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			r := regexp.MustCompile(`(\d{2,5}):(\d{2,5})`)
+
+			if len(r.FindStringSubmatch(args[0])) == 0 {
+				return errors.Errorf("parameter must be 'localPort:remotePort, example: '8080:80'")
+			}
+
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			ports := strings.Split(args[0], ":")
+
+			var converted, _ = strconv.ParseUint(ports[0], 10, 0)
+			fwdParams.LocalPort = uint(converted)
+
+			converted, _ = strconv.ParseUint(ports[1], 10, 0)
+			fwdParams.RemotePort = uint(converted)
+
+			printer, err := output.FromCommand(cmd)
+
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			fwdParams.OnEvent = func(event *PortForwardEvent) {
+				if err := printer.Print(event); err != nil {
+					log.Println(err)
+				}
+			}
+
+			if err := forward(&fwdParams); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+
+	fwdCmd.Flags().StringVarP(
+		&fwdParams.RemoteAddr,
+		"remote-addr", "", "127.0.0.1",
+		"The address to forward from on the remote machine",
+	)
+
+	return &fwdCmd
 }
@@ -0,0 +1,460 @@
+package host
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/knlambert/docker-remote.git/pkg/host/openstack"
+	"github.com/knlambert/docker-remote.git/pkg/output"
+	"github.com/knlambert/docker-remote.git/pkg/provision"
+	"github.com/knlambert/docker-remote.git/pkg/sshutil"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+//The docker context (and SSH alias) of the host.
+const serverDockerContextName = "docker-remote-openstack"
+
+var (
+	//The statuses of a server that is, or will be, active.
+	serverActiveStatuses = []string{"BUILD", "ACTIVE"}
+	//The statuses of a server that exists.
+	serverStatuses = []string{"BUILD", "ACTIVE", "SHUTOFF", "PAUSED", "SUSPENDED", "ERROR"}
+)
+
+func CreateOpenStackHost() DockerHostSystem {
+	return &serverHostImpl{
+		openStack: openstack.Create(),
+		helpers:   CreatePluginHelpers(),
+	}
+}
+
+type serverHostImpl struct {
+	openStack openstack.OpenStack
+	helpers   PluginHelpers
+}
+
+type ServerUpParams struct {
+	//The image, flavor and network of the server, by name or ID.
+	Image   string
+	Flavor  string
+	Network string
+	//The external network to allocate a floating IP from, none when empty.
+	FloatingIPNetwork string
+	//The user of the image.
+	SSHUser     string
+	KeyPairPath string
+	//The public key uploaded as a keypair, KeyPairPath with a ".pub" suffix or ~/.ssh/id_rsa.pub when empty.
+	PublicKeyPath string
+	UserData      provision.Params
+	PrintUserData bool
+	Timeout       time.Duration
+	Rollback      bool
+}
+
+func (o *serverHostImpl) CobraCommand(
+	command Command,
+) *cobra.Command {
+	switch command {
+	case Up:
+		upParams := ServerUpParams{}
+		var userDataFormat string
+		upCmd := cobra.Command{
+			Use:   string(command),
+			Short: "Boot an OpenStack server running docker and use it as the docker host",
+			Run: func(cmd *cobra.Command, args []string) {
+				upParams.UserData.Format = provision.Format(userDataFormat)
+
+				if upParams.PrintUserData {
+					userData, err := o.userData(&upParams)
+
+					if err != nil {
+						log.Fatal(err)
+					}
+
+					fmt.Print(userData)
+					return
+				}
+
+				ctx, cancel := interruptibleContext()
+				defer cancel()
+
+				runAndPrint(cmd, func() (output.Result, error) {
+					return o.Up(ctx, &upParams)
+				})
+			},
+		}
+
+		upCmd.Flags().StringVarP(&upParams.Image, "image", "", "", "The image of the server, by name or ID")
+		upCmd.Flags().StringVarP(&upParams.Flavor, "flavor", "", "", "The flavor of the server, by name or ID")
+		upCmd.Flags().StringVarP(
+			&upParams.Network, "network", "", "",
+			"The network of the server, by name or ID (default: the project's one)",
+		)
+		upCmd.Flags().StringVarP(
+			&upParams.FloatingIPNetwork, "floating-ip-network", "", "",
+			"An external network to give the server a floating IP from, by name or ID",
+		)
+		upCmd.Flags().StringVarP(&upParams.SSHUser, "ssh-user", "", "centos", "The user of the image")
+
+		addPublicKeyFlag(upCmd.Flags(), &upParams.PublicKeyPath, "uploaded as a keypair")
+
+		addUserDataFlags(upCmd.Flags(), &upParams.UserData, &userDataFormat, &upParams.PrintUserData)
+
+		addUpFlags(upCmd.Flags(), &upParams.KeyPairPath, &upParams.Timeout, &upParams.Rollback)
+
+		return &upCmd
+	}
+
+	return commonCommand(command, o, "Delete the docker host")
+}
+
+func (o *serverHostImpl) Cost() (*CostResult, error) {
+	return nil, errors.New("cost is not supported by the openstack driver")
+}
+
+func (o *serverHostImpl) Extend(params interface{}) (*ExtendResult, error) {
+	return nil, errors.New("extend is not supported by the openstack driver")
+}
+
+func (o *serverHostImpl) Reap(params interface{}) (*ReapResult, error) {
+	return nil, errors.New("reap is not supported by the openstack driver")
+}
+
+//Deletes the server of the current user, along with the floating IP docker-remote gave it.
+func (o *serverHostImpl) Down(params interface{}) (*DownResult, error) {
+	metadata, err := o.helpers.DefaultMetadata()
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to calculate metadata")
+	}
+
+	server, err := o.openStack.ServerDescribe(metadata, serverStatuses)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := DownResult{}
+
+	if server != nil {
+		released, err := o.openStack.FloatingIPRelease(server.ID)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err := o.openStack.ServerDelete(server.ID); err != nil {
+			return nil, errors.Wrapf(err, "failed to shutdown the docker host")
+		}
+
+		result.ID = server.ID
+		result.Terminated = true
+		result.ReleasedIP = strings.Join(released, ", ")
+	}
+
+	if err := o.helpers.SSHUtils().SSHAgentRemoveKey(agentKeyComment(serverDockerContextName)); err != nil {
+		return nil, err
+	}
+
+	if err := o.helpers.SSHUtils().SSHConfigRemove(serverDockerContextName); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+//Lists every docker host managed by docker-remote, whoever owns it.
+func (o *serverHostImpl) List() (*ListResult, error) {
+	servers, err := o.openStack.ServerList(map[string]string{"managed_by": "docker-remote"}, serverStatuses)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list openstack hosts")
+	}
+
+	result := ListResult{Hosts: []HostSummary{}}
+
+	for _, server := range servers {
+		result.Hosts = append(result.Hosts, *summarizeServer(server))
+	}
+
+	return &result, nil
+}
+
+func (o *serverHostImpl) PortForward(params interface{}) error {
+	return forwardTo(o.helpers, params, o.activeTarget)
+}
+
+func (o *serverHostImpl) Shell(params interface{}) error {
+	return shellTo(o.helpers, o.activeTarget)
+}
+
+//Describes the docker host of the current user.
+func (o *serverHostImpl) Status() (*StatusResult, error) {
+	metadata, err := o.helpers.DefaultMetadata()
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to calculate metadata")
+	}
+
+	server, err := o.openStack.ServerDescribe(metadata, serverStatuses)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to describe openstack host")
+	}
+
+	if server == nil {
+		return &StatusResult{}, nil
+	}
+
+	return &StatusResult{Host: summarizeServer(server)}, nil
+}
+
+func summarizeServer(server *openstack.ServerDescription) *HostSummary {
+	return &HostSummary{
+		ID:           server.ID,
+		Owner:        server.Metadata["owner"],
+		State:        strings.ToLower(server.Status),
+		InstanceType: server.Flavor,
+		LaunchTime:   server.Created,
+		Address:      serverAddress(server),
+	}
+}
+
+func (o *serverHostImpl) Up(ctx context.Context, params interface{}) (*UpResult, error) {
+	upParams := params.(*ServerUpParams)
+
+	userData, err := o.userData(upParams)
+
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := o.helpers.DefaultMetadata()
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to calculate metadata")
+	}
+
+	var server *openstack.ServerDescription
+	var createdServerId string
+
+	completed, err := RunSteps(ctx, []Step{{
+		Name: "server creation",
+		Run: func(ctx context.Context) error {
+			server, err = o.openStack.ServerDescribe(metadata, serverStatuses)
+
+			if err != nil {
+				return errors.Wrap(err, "failed to describe openstack host")
+			}
+
+			if server != nil {
+				if !sliceContainsString(serverActiveStatuses, server.Status) {
+					return errors.Errorf(
+						"server %s is %s, start it or delete it with down", server.ID, strings.ToLower(server.Status),
+					)
+				}
+				return nil
+			}
+
+			if upParams.Image == "" || upParams.Flavor == "" {
+				return errors.New("--image and --flavor are required to create the server")
+			}
+
+			publicKey, err := o.helpers.PublicKey(upParams.PublicKeyPath, upParams.KeyPairPath)
+
+			if err != nil {
+				return err
+			}
+
+			keyName := fmt.Sprintf("docker-remote-%s", metadata["owner"])
+
+			if err := o.openStack.KeypairEnsure(keyName, publicKey); err != nil {
+				return err
+			}
+
+			serverMetadata := map[string]string{sshUserTag: upParams.SSHUser}
+
+			for key, value := range metadata {
+				serverMetadata[key] = value
+			}
+
+			createdServerId, err = o.openStack.ServerCreate(&openstack.ServerCreateParams{
+				Name:     keyName,
+				Image:    upParams.Image,
+				Flavor:   upParams.Flavor,
+				Network:  upParams.Network,
+				KeyName:  keyName,
+				UserData: userData,
+				Metadata: serverMetadata,
+			})
+
+			if err != nil {
+				return err
+			}
+
+			log.Printf("Server %s created", createdServerId)
+			server = &openstack.ServerDescription{ID: createdServerId, Metadata: serverMetadata}
+
+			return nil
+		},
+		Rollback: func() error {
+			if createdServerId == "" {
+				return nil
+			}
+
+			if _, err := o.openStack.FloatingIPRelease(createdServerId); err != nil {
+				return err
+			}
+
+			return o.openStack.ServerDelete(createdServerId)
+		},
+		Resource: func() string {
+			if createdServerId == "" {
+				return ""
+			}
+			return fmt.Sprintf("OpenStack server %s and its floating IP", createdServerId)
+		},
+	}, agentKeyStep(o.helpers, serverDockerContextName, upParams.KeyPairPath), {
+		Name: "readiness",
+		Run: func(ctx context.Context) error {
+			if err := createReadinessWaiter(upParams.Timeout).Wait(
+				ctx, o.readinessStages(upParams, metadata, &server),
+			); err != nil {
+				return err
+			}
+
+			log.Println("Server is ready !")
+
+			return nil
+		},
+	}, dockerContextStep(o.helpers, serverDockerContextName, func() (*sshutil.Target, error) {
+		return serverTarget(server), nil
+	}, nil)})
+
+	if err != nil {
+		HandleStepsFailure(completed, upParams.Rollback)
+		return nil, err
+	}
+
+	return &UpResult{
+		ID:            server.ID,
+		Address:       serverAddress(server),
+		DockerContext: serverDockerContextName,
+		Created:       createdServerId != "",
+	}, nil
+}
+
+//Stages a freshly booted server goes through before docker can be used on it.
+func (o *serverHostImpl) readinessStages(
+	upParams *ServerUpParams,
+	metadata map[string]string,
+	server **openstack.ServerDescription,
+) []ReadinessStage {
+	sshUtils := o.helpers.SSHUtils()
+
+	return []ReadinessStage{{
+		Name: "server active",
+		Probe: func(ctx context.Context) error {
+			ready, err := o.openStack.ServerIsReady((*server).ID)
+
+			if err != nil {
+				return err
+			} else if !ready {
+				return errors.New("server is not active yet")
+			}
+
+			*server, err = o.openStack.ServerDescribe(metadata, []string{"ACTIVE"})
+
+			if err == nil && *server == nil {
+				return errors.New("server is not listed yet")
+			}
+
+			return err
+		},
+	}, {
+		Name: "floating IP",
+		Probe: func(ctx context.Context) error {
+			if upParams.FloatingIPNetwork == "" || (*server).PublicIp != "" {
+				return nil
+			}
+
+			address, err := o.openStack.FloatingIPAttach((*server).ID, upParams.FloatingIPNetwork)
+
+			if err != nil {
+				return err
+			}
+
+			log.Printf("Floating IP %s attached", address)
+			(*server).PublicIp = address
+
+			return nil
+		},
+	}, {
+		Name: "SSH",
+		Probe: func(ctx context.Context) error {
+			_, err := sshUtils.SSHRun(serverTarget(*server), "true")
+			return err
+		},
+	}, {
+		Name: "cloud-init",
+		Probe: func(ctx context.Context) error {
+			_, err := sshUtils.SSHRun(serverTarget(*server), "test -f /var/lib/cloud/instance/boot-finished")
+			return err
+		},
+	}, {
+		Name: "docker daemon",
+		Probe: func(ctx context.Context) error {
+			return sshUtils.DockerPing(serverTarget(*server))
+		},
+	}}
+}
+
+//Returns the address of a server, the floating IP when it has one, the fixed IP otherwise.
+func serverAddress(server *openstack.ServerDescription) string {
+	if server.PublicIp != "" {
+		return server.PublicIp
+	}
+	return server.PrivateIp
+}
+
+func serverTarget(server *openstack.ServerDescription) *sshutil.Target {
+	return &sshutil.Target{Endpoint: sshutil.Endpoint{User: server.Metadata[sshUserTag], Host: serverAddress(server)}}
+}
+
+//Returns the server of the current user, fails if it is not active.
+func (o *serverHostImpl) activeTarget() (*sshutil.Target, error) {
+	metadata, err := o.helpers.DefaultMetadata()
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to calculate metadata")
+	}
+
+	server, err := o.openStack.ServerDescribe(metadata, []string{"ACTIVE"})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if server == nil {
+		return nil, errors.Errorf("Please create the host first")
+	}
+
+	return serverTarget(server), nil
+}
+
+//Renders the provisioning script of the server, the shared one by default.
+func (o *serverHostImpl) userData(upParams *ServerUpParams) (string, error) {
+	upParams.UserData.User = upParams.SSHUser
+
+	userData, err := o.helpers.Provision().Render(&upParams.UserData)
+
+	if err != nil {
+		return "", errors.Wrap(err, "failed to render the provisioning script")
+	}
+
+	return userData, nil
+}
@@ -0,0 +1,420 @@
+package openstack
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+//The OpenStack APIs (Nova, Neutron, Glance), the subset docker-remote uses.
+type Client interface {
+	FlavorsList() ([]*Resource, error)
+	FloatingIPsCreate(networkId string, portId string, description string) (*FloatingIP, error)
+	FloatingIPsDelete(id string) error
+	FloatingIPsList(portId string) ([]*FloatingIP, error)
+	ImagesList(name string) ([]*Resource, error)
+	//Returns a keypair, nil if it does not exist.
+	KeypairsGet(name string) (*Resource, error)
+	KeypairsCreate(name string, publicKey string) error
+	NetworksList(name string) ([]*Resource, error)
+	PortsList(deviceId string) ([]*Resource, error)
+	ServersCreate(request *ServerCreateRequest) (string, error)
+	ServersDelete(id string) error
+	//Returns a server, nil if it does not exist.
+	ServersGet(id string) (*Server, error)
+	ServersList() ([]*Server, error)
+}
+
+//A named resource: flavor, image, keypair, network or port.
+type Resource struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type ServerCreateRequest struct {
+	Name      string            `json:"name"`
+	ImageRef  string            `json:"imageRef"`
+	FlavorRef string            `json:"flavorRef"`
+	KeyName   string            `json:"key_name,omitempty"`
+	UserData  string            `json:"user_data,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	Networks  []ServerNetwork   `json:"networks,omitempty"`
+}
+
+type ServerNetwork struct {
+	UUID string `json:"uuid"`
+}
+
+type Server struct {
+	ID       string            `json:"id"`
+	Name     string            `json:"name"`
+	Status   string            `json:"status"`
+	Created  string            `json:"created"`
+	Metadata map[string]string `json:"metadata"`
+	Flavor   struct {
+		ID string `json:"id"`
+	} `json:"flavor"`
+	Addresses map[string][]struct {
+		Addr    string `json:"addr"`
+		Version int    `json:"version"`
+		Type    string `json:"OS-EXT-IPS:type"`
+	} `json:"addresses"`
+}
+
+type FloatingIP struct {
+	ID          string `json:"id"`
+	Address     string `json:"floating_ip_address"`
+	Description string `json:"description"`
+}
+
+func createRESTClient(cloud func() (*Cloud, error)) Client {
+	return &restClient{
+		cloud:  cloud,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+type restClient struct {
+	cloud  func() (*Cloud, error)
+	client *http.Client
+
+	//The token and service endpoints, fetched once on first use.
+	once      sync.Once
+	token     string
+	endpoints map[string]string
+	failure   error
+}
+
+func (c *restClient) FlavorsList() ([]*Resource, error) {
+	var res struct {
+		Flavors []*Resource `json:"flavors"`
+	}
+	return res.Flavors, c.do("compute", http.MethodGet, "/flavors", nil, &res)
+}
+
+func (c *restClient) FloatingIPsCreate(networkId string, portId string, description string) (*FloatingIP, error) {
+	var res struct {
+		FloatingIP *FloatingIP `json:"floatingip"`
+	}
+
+	request := map[string]interface{}{"floatingip": map[string]string{
+		"floating_network_id": networkId,
+		"port_id":             portId,
+		"description":         description,
+	}}
+
+	return res.FloatingIP, c.do("network", http.MethodPost, "/v2.0/floatingips", request, &res)
+}
+
+func (c *restClient) FloatingIPsDelete(id string) error {
+	return c.do("network", http.MethodDelete, "/v2.0/floatingips/"+id, nil, nil)
+}
+
+func (c *restClient) FloatingIPsList(portId string) ([]*FloatingIP, error) {
+	var res struct {
+		FloatingIPs []*FloatingIP `json:"floatingips"`
+	}
+	return res.FloatingIPs, c.do(
+		"network", http.MethodGet, "/v2.0/floatingips?port_id="+url.QueryEscape(portId), nil, &res,
+	)
+}
+
+func (c *restClient) ImagesList(name string) ([]*Resource, error) {
+	var res struct {
+		Images []*Resource `json:"images"`
+	}
+	return res.Images, c.do("image", http.MethodGet, "/v2/images?name="+url.QueryEscape(name), nil, &res)
+}
+
+func (c *restClient) KeypairsGet(name string) (*Resource, error) {
+	var res struct {
+		Keypair *Resource `json:"keypair"`
+	}
+
+	if err := c.do("compute", http.MethodGet, "/os-keypairs/"+url.PathEscape(name), nil, &res); err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return res.Keypair, nil
+}
+
+func (c *restClient) KeypairsCreate(name string, publicKey string) error {
+	request := map[string]interface{}{"keypair": map[string]string{
+		"name":       name,
+		"public_key": strings.TrimSpace(publicKey),
+	}}
+
+	return c.do("compute", http.MethodPost, "/os-keypairs", request, nil)
+}
+
+func (c *restClient) NetworksList(name string) ([]*Resource, error) {
+	var res struct {
+		Networks []*Resource `json:"networks"`
+	}
+	return res.Networks, c.do("network", http.MethodGet, "/v2.0/networks?name="+url.QueryEscape(name), nil, &res)
+}
+
+func (c *restClient) PortsList(deviceId string) ([]*Resource, error) {
+	var res struct {
+		Ports []*Resource `json:"ports"`
+	}
+	return res.Ports, c.do("network", http.MethodGet, "/v2.0/ports?device_id="+url.QueryEscape(deviceId), nil, &res)
+}
+
+func (c *restClient) ServersCreate(request *ServerCreateRequest) (string, error) {
+	var res struct {
+		Server struct {
+			ID string `json:"id"`
+		} `json:"server"`
+	}
+
+	err := c.do("compute", http.MethodPost, "/servers", map[string]interface{}{"server": request}, &res)
+
+	return res.Server.ID, err
+}
+
+func (c *restClient) ServersDelete(id string) error {
+	return c.do("compute", http.MethodDelete, "/servers/"+id, nil, nil)
+}
+
+func (c *restClient) ServersGet(id string) (*Server, error) {
+	var res struct {
+		Server *Server `json:"server"`
+	}
+
+	if err := c.do("compute", http.MethodGet, "/servers/"+id, nil, &res); err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return res.Server, nil
+}
+
+func (c *restClient) ServersList() ([]*Server, error) {
+	var servers []*Server
+	path := "/servers/detail"
+
+	for path != "" {
+		var res struct {
+			Servers []*Server `json:"servers"`
+			Links   []struct {
+				Rel  string `json:"rel"`
+				Href string `json:"href"`
+			} `json:"servers_links"`
+		}
+
+		if err := c.do("compute", http.MethodGet, path, nil, &res); err != nil {
+			return nil, err
+		}
+
+		servers = append(servers, res.Servers...)
+		path = ""
+
+		for _, link := range res.Links {
+			if link.Rel == "next" {
+				path = link.Href
+			}
+		}
+	}
+
+	return servers, nil
+}
+
+//An error answered by an API.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("OpenStack API answered %d: %s", e.StatusCode, e.Message)
+}
+
+func isNotFound(err error) bool {
+	apiErr, ok := errors.Cause(err).(*APIError)
+	return ok && apiErr.StatusCode == http.StatusNotFound
+}
+
+//Sends an authenticated JSON request to a service of the catalog, decodes the response into out.
+//The path may also be an absolute URL, as the pagination links are.
+func (c *restClient) do(service string, method string, path string, in interface{}, out interface{}) error {
+	c.once.Do(func() {
+		c.failure = c.authenticate()
+	})
+
+	if c.failure != nil {
+		return c.failure
+	}
+
+	endpoint, ok := c.endpoints[service]
+
+	if !ok {
+		return errors.Errorf("no %s service in the OpenStack catalog", service)
+	}
+
+	target := path
+
+	if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") {
+		target = endpoint + path
+	}
+
+	_, err := c.send(method, target, in, map[string]string{"X-Auth-Token": c.token}, out)
+
+	return err
+}
+
+//Gets a token and the service endpoints from Keystone (v3).
+func (c *restClient) authenticate() error {
+	cloud, err := c.cloud()
+
+	if err != nil {
+		return err
+	}
+
+	identity := map[string]interface{}{}
+	auth := map[string]interface{}{"identity": identity}
+
+	if cloud.Auth.ApplicationCredentialID != "" {
+		identity["methods"] = []string{"application_credential"}
+		identity["application_credential"] = map[string]string{
+			"id":     cloud.Auth.ApplicationCredentialID,
+			"secret": cloud.Auth.ApplicationCredentialSecret,
+		}
+	} else {
+		identity["methods"] = []string{"password"}
+		identity["password"] = map[string]interface{}{"user": map[string]interface{}{
+			"name":     cloud.Auth.Username,
+			"password": cloud.Auth.Password,
+			"domain":   map[string]string{"name": defaultDomain(cloud.Auth.UserDomainName)},
+		}}
+
+		if cloud.Auth.ProjectID != "" {
+			auth["scope"] = map[string]interface{}{"project": map[string]string{"id": cloud.Auth.ProjectID}}
+		} else if cloud.Auth.ProjectName != "" {
+			auth["scope"] = map[string]interface{}{"project": map[string]interface{}{
+				"name":   cloud.Auth.ProjectName,
+				"domain": map[string]string{"name": defaultDomain(cloud.Auth.ProjectDomainName)},
+			}}
+		}
+	}
+
+	authURL := strings.TrimSuffix(cloud.Auth.AuthURL, "/")
+
+	if !strings.HasSuffix(authURL, "/v3") {
+		authURL += "/v3"
+	}
+
+	var token struct {
+		Token struct {
+			Catalog []struct {
+				Type      string `json:"type"`
+				Endpoints []struct {
+					Interface string `json:"interface"`
+					Region    string `json:"region"`
+					URL       string `json:"url"`
+				} `json:"endpoints"`
+			} `json:"catalog"`
+		} `json:"token"`
+	}
+
+	res, err := c.send(http.MethodPost, authURL+"/auth/tokens", map[string]interface{}{"auth": auth}, nil, &token)
+
+	if err != nil {
+		return errors.Wrap(err, "failed to authenticate to OpenStack")
+	}
+
+	c.token = res.Header.Get("X-Subject-Token")
+	c.endpoints = map[string]string{}
+
+	for _, service := range token.Token.Catalog {
+		for _, endpoint := range service.Endpoints {
+			if endpoint.Interface == cloud.Interface && (cloud.RegionName == "" || endpoint.Region == cloud.RegionName) {
+				c.endpoints[service.Type] = strings.TrimSuffix(endpoint.URL, "/")
+			}
+		}
+	}
+
+	//Neutron and Glance endpoints often miss the API version of the paths used here.
+	for service, version := range map[string]string{"network": "/v2.0", "image": "/v2"} {
+		c.endpoints[service] = strings.TrimSuffix(c.endpoints[service], version)
+	}
+
+	return nil
+}
+
+func defaultDomain(name string) string {
+	if name == "" {
+		return "Default"
+	}
+	return name
+}
+
+//Sends a JSON request, decodes the response into out. The returned response is only
+//meant for its headers, the body is consumed.
+func (c *restClient) send(
+	method string,
+	target string,
+	in interface{},
+	headers map[string]string,
+	out interface{},
+) (*http.Response, error) {
+	var body bytes.Buffer
+
+	if in != nil {
+		if err := json.NewEncoder(&body).Encode(in); err != nil {
+			return nil, errors.Wrap(err, "failed to encode the request")
+		}
+	}
+
+	req, err := http.NewRequest(method, target, &body)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create the request")
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	res, err := c.client.Do(req)
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to call %s %s", method, target)
+	}
+
+	defer res.Body.Close()
+
+	content, err := ioutil.ReadAll(res.Body)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the response")
+	}
+
+	if res.StatusCode >= 300 {
+		return nil, &APIError{StatusCode: res.StatusCode, Message: strings.TrimSpace(string(content))}
+	}
+
+	if out != nil && len(content) > 0 {
+		if err := json.Unmarshal(content, out); err != nil {
+			return nil, errors.Wrap(err, "failed to decode the response")
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,162 @@
+package openstack
+
+import (
+	goos "os"
+	"path/filepath"
+
+	"github.com/knlambert/docker-remote.git/pkg/std/ioutil"
+	"github.com/knlambert/docker-remote.git/pkg/std/os"
+	"github.com/knlambert/docker-remote.git/pkg/std/user"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v2"
+)
+
+//Options of the OpenStack session, the OS_CLOUD and OS_* environment applies to empty ones.
+type SessionConfig struct {
+	//The entry of clouds.yaml to use.
+	Cloud string
+}
+
+//The session options given on the command line, see AddFlags.
+var CommandLineConfig = SessionConfig{}
+
+//Adds the flags filling CommandLineConfig.
+func AddFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(
+		&CommandLineConfig.Cloud, "os-cloud", "", "",
+		"The OpenStack cloud of clouds.yaml to use (default: OS_CLOUD, then the OS_* variables)",
+	)
+}
+
+//A cloud, as described in clouds.yaml.
+type Cloud struct {
+	Auth struct {
+		AuthURL                     string `yaml:"auth_url"`
+		Username                    string `yaml:"username"`
+		Password                    string `yaml:"password"`
+		ProjectName                 string `yaml:"project_name"`
+		ProjectID                   string `yaml:"project_id"`
+		UserDomainName              string `yaml:"user_domain_name"`
+		ProjectDomainName           string `yaml:"project_domain_name"`
+		ApplicationCredentialID     string `yaml:"application_credential_id"`
+		ApplicationCredentialSecret string `yaml:"application_credential_secret"`
+	} `yaml:"auth"`
+	RegionName string `yaml:"region_name"`
+	//The endpoints interface of the catalog, "public" by default.
+	Interface string `yaml:"interface"`
+}
+
+//Loads the cloud to use: a clouds.yaml entry, completed (or replaced when there is none) by the OS_* variables.
+type cloudLoader struct {
+	io     ioutil.IOUtil
+	os     os.OS
+	user   user.User
+	getenv func(string) string
+}
+
+func createCloudLoader() *cloudLoader {
+	return &cloudLoader{
+		io:     ioutil.CreateIOUtil(),
+		os:     os.CreateOS(),
+		user:   user.CreateUser(),
+		getenv: goos.Getenv,
+	}
+}
+
+func (l *cloudLoader) Load(config *SessionConfig) (*Cloud, error) {
+	cloud := Cloud{}
+	name := config.Cloud
+
+	if name == "" {
+		name = l.getenv("OS_CLOUD")
+	}
+
+	if name != "" {
+		path, err := l.cloudsFile()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if path == "" {
+			return nil, errors.Errorf("cloud '%s' requested but no clouds.yaml found", name)
+		}
+
+		content, err := l.io.ReadFile(path)
+
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s", path)
+		}
+
+		var file struct {
+			Clouds map[string]Cloud `yaml:"clouds"`
+		}
+
+		if err := yaml.Unmarshal(content, &file); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse %s", path)
+		}
+
+		found, ok := file.Clouds[name]
+
+		if !ok {
+			return nil, errors.Errorf("no cloud '%s' in %s", name, path)
+		}
+
+		cloud = found
+	}
+
+	for _, variable := range []struct {
+		name  string
+		value *string
+	}{
+		{"OS_AUTH_URL", &cloud.Auth.AuthURL},
+		{"OS_USERNAME", &cloud.Auth.Username},
+		{"OS_PASSWORD", &cloud.Auth.Password},
+		{"OS_PROJECT_NAME", &cloud.Auth.ProjectName},
+		{"OS_PROJECT_ID", &cloud.Auth.ProjectID},
+		{"OS_USER_DOMAIN_NAME", &cloud.Auth.UserDomainName},
+		{"OS_PROJECT_DOMAIN_NAME", &cloud.Auth.ProjectDomainName},
+		{"OS_APPLICATION_CREDENTIAL_ID", &cloud.Auth.ApplicationCredentialID},
+		{"OS_APPLICATION_CREDENTIAL_SECRET", &cloud.Auth.ApplicationCredentialSecret},
+		{"OS_REGION_NAME", &cloud.RegionName},
+		{"OS_INTERFACE", &cloud.Interface},
+	} {
+		if *variable.value == "" {
+			*variable.value = l.getenv(variable.name)
+		}
+	}
+
+	if cloud.Auth.AuthURL == "" {
+		return nil, errors.New("no OpenStack cloud configured, use --os-cloud or the OS_* variables")
+	}
+
+	if cloud.Interface == "" {
+		cloud.Interface = "public"
+	}
+
+	return &cloud, nil
+}
+
+//Returns the first clouds.yaml of the standard locations, empty if there is none.
+func (l *cloudLoader) cloudsFile() (string, error) {
+	currentUser, err := l.user.Current()
+
+	if err != nil {
+		return "", errors.Wrap(err, "failed to determine current user")
+	}
+
+	for _, path := range []string{
+		"clouds.yaml",
+		filepath.Join(currentUser.HomeDir, ".config", "openstack", "clouds.yaml"),
+		"/etc/openstack/clouds.yaml",
+	} {
+		if exists, err := l.os.PathExists(path); err != nil {
+			return "", errors.Wrapf(err, "failed to check %s path existence", path)
+		} else if exists {
+			return path, nil
+		}
+	}
+
+	return "", nil
+}
@@ -0,0 +1,87 @@
+package openstack
+
+import (
+	"os/user"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	mock_ioutil "github.com/knlambert/docker-remote.git/pkg/mock/std/ioutil"
+	mock_os "github.com/knlambert/docker-remote.git/pkg/mock/std/os"
+	mock_user "github.com/knlambert/docker-remote.git/pkg/mock/std/user"
+	"github.com/stretchr/testify/assert"
+)
+
+func stubbedCloudLoader(ctrl *gomock.Controller, env map[string]string) (
+	*cloudLoader,
+	*mock_ioutil.MockIOUtil,
+	*mock_os.MockOS,
+	*mock_user.MockUser,
+) {
+	ioMock := mock_ioutil.NewMockIOUtil(ctrl)
+	osMock := mock_os.NewMockOS(ctrl)
+	userMock := mock_user.NewMockUser(ctrl)
+
+	return &cloudLoader{
+		io:   ioMock,
+		os:   osMock,
+		user: userMock,
+		getenv: func(name string) string {
+			return env[name]
+		},
+	}, ioMock, osMock, userMock
+}
+
+func TestLoadCloudsYAML(t *testing.T) {
+	// Tear up.
+	ctrl := gomock.NewController(t)
+	l, ioMock, osMock, userMock := stubbedCloudLoader(ctrl, map[string]string{"OS_PASSWORD": "secret"})
+
+	userMock.EXPECT().Current().Return(&user.User{HomeDir: "/home/barney"}, nil)
+	osMock.EXPECT().PathExists("clouds.yaml").Return(false, nil)
+	osMock.EXPECT().PathExists("/home/barney/.config/openstack/clouds.yaml").Return(true, nil)
+	ioMock.EXPECT().ReadFile("/home/barney/.config/openstack/clouds.yaml").Return([]byte(`
+clouds:
+  internal:
+    auth:
+      auth_url: https://keystone.corp:5000
+      username: barney
+      project_name: builds
+      user_domain_name: Corp
+    region_name: RegionTwo
+`), nil)
+
+	//Assertions
+	cloud, err := l.Load(&SessionConfig{Cloud: "internal"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "https://keystone.corp:5000", cloud.Auth.AuthURL)
+	assert.Equal(t, "Corp", cloud.Auth.UserDomainName)
+	assert.Equal(t, "secret", cloud.Auth.Password, "the environment completes clouds.yaml")
+	assert.Equal(t, "RegionTwo", cloud.RegionName)
+	assert.Equal(t, "public", cloud.Interface)
+
+	ctrl.Finish()
+}
+
+func TestLoadEnvironment(t *testing.T) {
+	// Tear up.
+	ctrl := gomock.NewController(t)
+	l, _, _, _ := stubbedCloudLoader(ctrl, map[string]string{
+		"OS_AUTH_URL":     "https://keystone.corp:5000/v3",
+		"OS_USERNAME":     "barney",
+		"OS_PROJECT_NAME": "builds",
+	})
+
+	//Assertions
+	cloud, err := l.Load(&SessionConfig{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "barney", cloud.Auth.Username)
+
+	l.getenv = func(string) string { return "" }
+	_, err = l.Load(&SessionConfig{})
+
+	assert.EqualError(t, err, "no OpenStack cloud configured, use --os-cloud or the OS_* variables")
+
+	ctrl.Finish()
+}
@@ -0,0 +1,316 @@
+package openstack
+
+import (
+	"encoding/base64"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+//The description of the floating IPs docker-remote allocates, only those are released.
+const floatingIPDescription = "managed by docker-remote"
+
+type OpenStack interface {
+	//Allocates a floating IP on an external network and associates it to the server.
+	FloatingIPAttach(serverId string, network string) (string, error)
+	//Releases the floating IPs docker-remote allocated for the server.
+	FloatingIPRelease(serverId string) ([]string, error)
+	//Uploads the public key as a keypair if there is none with this name.
+	KeypairEnsure(name string, publicKey string) error
+	//Creates a server, returns its ID.
+	ServerCreate(params *ServerCreateParams) (string, error)
+	ServerDelete(id string) error
+	//Returns the first server having all the metadata, in one of the statuses, nil if there is none.
+	ServerDescribe(metadata map[string]string, statuses []string) (*ServerDescription, error)
+	ServerIsReady(id string) (bool, error)
+	ServerList(metadata map[string]string, statuses []string) ([]*ServerDescription, error)
+}
+
+func Create() OpenStack {
+	return &openStackImpl{
+		client: createRESTClient(func() (*Cloud, error) {
+			return createCloudLoader().Load(&CommandLineConfig)
+		}),
+	}
+}
+
+type openStackImpl struct {
+	client Client
+}
+
+type ServerCreateParams struct {
+	Name string
+	//The image, flavor and network, by name or ID.
+	Image    string
+	Flavor   string
+	Network  string
+	KeyName  string
+	UserData string
+	Metadata map[string]string
+}
+
+func (o *openStackImpl) ServerCreate(params *ServerCreateParams) (string, error) {
+	imageId, err := o.resolve("image", params.Image, func() ([]*Resource, error) {
+		return o.client.ImagesList(params.Image)
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	flavorId, err := o.resolve("flavor", params.Flavor, o.client.FlavorsList)
+
+	if err != nil {
+		return "", err
+	}
+
+	request := ServerCreateRequest{
+		Name:      params.Name,
+		ImageRef:  imageId,
+		FlavorRef: flavorId,
+		KeyName:   params.KeyName,
+		UserData:  base64.StdEncoding.EncodeToString([]byte(params.UserData)),
+		Metadata:  params.Metadata,
+	}
+
+	if params.Network != "" {
+		networkId, err := o.resolve("network", params.Network, func() ([]*Resource, error) {
+			return o.client.NetworksList(params.Network)
+		})
+
+		if err != nil {
+			return "", err
+		}
+
+		request.Networks = []ServerNetwork{{UUID: networkId}}
+	}
+
+	id, err := o.client.ServersCreate(&request)
+
+	if err != nil {
+		return "", errors.Wrap(err, "failed to kick a server in OpenStack")
+	}
+
+	return id, nil
+}
+
+//Returns the ID of a resource given by name or ID.
+func (o *openStackImpl) resolve(kind string, nameOrId string, list func() ([]*Resource, error)) (string, error) {
+	resources, err := list()
+
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to list the %ss", kind)
+	}
+
+	for _, resource := range resources {
+		if resource.ID == nameOrId {
+			return resource.ID, nil
+		}
+	}
+
+	var matches []string
+
+	for _, resource := range resources {
+		if resource.Name == nameOrId {
+			matches = append(matches, resource.ID)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		//Lists filtered by name miss the IDs, the API checks them.
+		return nameOrId, nil
+	case 1:
+		return matches[0], nil
+	}
+
+	return "", errors.Errorf("%d %ss are named '%s', use an ID", len(matches), kind, nameOrId)
+}
+
+func (o *openStackImpl) ServerDelete(id string) error {
+	return errors.Wrapf(o.client.ServersDelete(id), "failed to delete server %s", id)
+}
+
+type ServerDescription struct {
+	ID        string
+	Name      string
+	Status    string
+	Flavor    string
+	PublicIp  string
+	PrivateIp string
+	Created   *time.Time
+	Metadata  map[string]string
+}
+
+func (o *openStackImpl) ServerDescribe(
+	metadata map[string]string, statuses []string,
+) (*ServerDescription, error) {
+	servers, err := o.ServerList(metadata, statuses)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(servers) > 0 {
+		return servers[0], nil
+	}
+
+	return nil, nil
+}
+
+//Returns the servers having all the metadata, in one of the statuses.
+func (o *openStackImpl) ServerList(
+	metadata map[string]string, statuses []string,
+) ([]*ServerDescription, error) {
+	res, err := o.client.ServersList()
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list the servers")
+	}
+
+	var servers []*ServerDescription
+
+	for _, server := range res {
+		if hasMetadata(server.Metadata, metadata) && contains(statuses, server.Status) {
+			servers = append(servers, describeServer(server))
+		}
+	}
+
+	return servers, nil
+}
+
+func describeServer(server *Server) *ServerDescription {
+	description := ServerDescription{
+		ID:       server.ID,
+		Name:     server.Name,
+		Status:   server.Status,
+		Flavor:   server.Flavor.ID,
+		Metadata: server.Metadata,
+	}
+
+	if created, err := time.Parse(time.RFC3339, server.Created); err == nil {
+		description.Created = &created
+	}
+
+	for _, addresses := range server.Addresses {
+		for _, address := range addresses {
+			if address.Version != 4 {
+				continue
+			}
+
+			if address.Type == "floating" {
+				description.PublicIp = address.Addr
+			} else if description.PrivateIp == "" {
+				description.PrivateIp = address.Addr
+			}
+		}
+	}
+
+	return &description
+}
+
+func (o *openStackImpl) ServerIsReady(id string) (bool, error) {
+	server, err := o.client.ServersGet(id)
+
+	if err != nil {
+		return false, errors.Wrapf(err, "can't describe server %s", id)
+	}
+
+	if server != nil && server.Status == "ERROR" {
+		return false, errors.Errorf("server %s failed to build", id)
+	}
+
+	return server != nil && server.Status == "ACTIVE", nil
+}
+
+func (o *openStackImpl) KeypairEnsure(name string, publicKey string) error {
+	keypair, err := o.client.KeypairsGet(name)
+
+	if err != nil {
+		return errors.Wrapf(err, "failed to look keypair %s up", name)
+	}
+
+	if keypair != nil {
+		return nil
+	}
+
+	return errors.Wrapf(o.client.KeypairsCreate(name, publicKey), "failed to upload keypair %s", name)
+}
+
+func (o *openStackImpl) FloatingIPAttach(serverId string, network string) (string, error) {
+	networkId, err := o.resolve("network", network, func() ([]*Resource, error) {
+		return o.client.NetworksList(network)
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	ports, err := o.client.PortsList(serverId)
+
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to list the ports of server %s", serverId)
+	}
+
+	if len(ports) == 0 {
+		return "", errors.Errorf("server %s has no port yet", serverId)
+	}
+
+	floatingIP, err := o.client.FloatingIPsCreate(networkId, ports[0].ID, floatingIPDescription)
+
+	if err != nil {
+		return "", errors.Wrap(err, "failed to allocate a floating IP")
+	}
+
+	return floatingIP.Address, nil
+}
+
+func (o *openStackImpl) FloatingIPRelease(serverId string) ([]string, error) {
+	ports, err := o.client.PortsList(serverId)
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list the ports of server %s", serverId)
+	}
+
+	var released []string
+
+	for _, port := range ports {
+		floatingIPs, err := o.client.FloatingIPsList(port.ID)
+
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list the floating IPs")
+		}
+
+		for _, floatingIP := range floatingIPs {
+			if floatingIP.Description != floatingIPDescription {
+				continue
+			}
+
+			if err := o.client.FloatingIPsDelete(floatingIP.ID); err != nil {
+				return nil, errors.Wrapf(err, "failed to release floating IP %s", floatingIP.Address)
+			}
+
+			released = append(released, floatingIP.Address)
+		}
+	}
+
+	return released, nil
+}
+
+func hasMetadata(metadata map[string]string, wanted map[string]string) bool {
+	for key, value := range wanted {
+		if metadata[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package openstack
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//A cloud answering Keystone, then the given handler for the other services.
+func stubbedOpenStack(t *testing.T, handler http.HandlerFunc) (*openStackImpl, *httptest.Server) {
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/identity/v3/auth/tokens" {
+			w.Header().Set("X-Subject-Token", "token")
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"token": {"catalog": [
+				{"type": "compute", "endpoints": [
+					{"interface": "internal", "region": "RegionOne", "url": "http://internal"},
+					{"interface": "public", "region": "RegionOne", "url": "%[1]s/compute/v2.1"}
+				]},
+				{"type": "network", "endpoints": [{"interface": "public", "region": "RegionOne", "url": "%[1]s/network/"}]},
+				{"type": "image", "endpoints": [{"interface": "public", "region": "RegionOne", "url": "%[1]s/image"}]}
+			]}}`, server.URL)
+			return
+		}
+
+		assert.Equal(t, "token", r.Header.Get("X-Auth-Token"))
+		handler(w, r)
+	}))
+
+	cloud := Cloud{Interface: "public", RegionName: "RegionOne"}
+	cloud.Auth.AuthURL = server.URL + "/identity"
+
+	return &openStackImpl{
+		client: createRESTClient(func() (*Cloud, error) { return &cloud, nil }),
+	}, server
+}
+
+func TestServerCreate(t *testing.T) {
+	// Tear up.
+	var received struct {
+		Server ServerCreateRequest `json:"server"`
+	}
+
+	o, server := stubbedOpenStack(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/image/v2/images":
+			assert.Equal(t, "ubuntu-20.04", r.URL.Query().Get("name"))
+			_, _ = w.Write([]byte(`{"images": [{"id": "img-1", "name": "ubuntu-20.04"}]}`))
+		case "/compute/v2.1/flavors":
+			_, _ = w.Write([]byte(`{"flavors": [{"id": "1", "name": "m1.small"}, {"id": "2", "name": "m1.medium"}]}`))
+		case "/network/v2.0/networks":
+			_, _ = w.Write([]byte(`{"networks": []}`))
+		case "/compute/v2.1/servers":
+			assert.Nil(t, json.NewDecoder(r.Body).Decode(&received))
+			w.WriteHeader(http.StatusAccepted)
+			_, _ = w.Write([]byte(`{"server": {"id": "srv-1"}}`))
+		default:
+			t.Errorf("unexpected call to %s", r.URL.Path)
+		}
+	})
+	defer server.Close()
+
+	//Assertions
+	id, err := o.ServerCreate(&ServerCreateParams{
+		Name:     "docker-remote-barney",
+		Image:    "ubuntu-20.04",
+		Flavor:   "m1.medium",
+		Network:  "net-42",
+		KeyName:  "docker-remote-barney",
+		UserData: "#!/bin/bash\n",
+		Metadata: map[string]string{"owner": "barney", "managed_by": "docker-remote"},
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "srv-1", id)
+	assert.Equal(t, "img-1", received.Server.ImageRef)
+	assert.Equal(t, "2", received.Server.FlavorRef)
+	assert.Equal(t, []ServerNetwork{{UUID: "net-42"}}, received.Server.Networks, "unknown names are IDs")
+	assert.Equal(t, "IyEvYmluL2Jhc2gK", received.Server.UserData)
+	assert.Equal(t, "barney", received.Server.Metadata["owner"])
+}
+
+func TestServerDescribe(t *testing.T) {
+	// Tear up.
+	o, server := stubbedOpenStack(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/compute/v2.1/servers/detail", r.URL.Path)
+
+		_, _ = w.Write([]byte(`{"servers": [
+			{"id": "srv-0", "status": "ACTIVE", "metadata": {"owner": "fred", "managed_by": "docker-remote"}},
+			{
+				"id": "srv-1",
+				"status": "ACTIVE",
+				"created": "2020-11-02T16:12:41Z",
+				"flavor": {"id": "2"},
+				"metadata": {"owner": "barney", "managed_by": "docker-remote"},
+				"addresses": {"private": [
+					{"addr": "fd00::2", "version": 6, "OS-EXT-IPS:type": "fixed"},
+					{"addr": "10.0.0.12", "version": 4, "OS-EXT-IPS:type": "fixed"},
+					{"addr": "172.24.4.20", "version": 4, "OS-EXT-IPS:type": "floating"}
+				]}
+			}
+		]}`))
+	})
+	defer server.Close()
+
+	//Assertions
+	description, err := o.ServerDescribe(
+		map[string]string{"owner": "barney", "managed_by": "docker-remote"},
+		[]string{"BUILD", "ACTIVE"},
+	)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "srv-1", description.ID)
+	assert.Equal(t, "10.0.0.12", description.PrivateIp)
+	assert.Equal(t, "172.24.4.20", description.PublicIp)
+	assert.NotNil(t, description.Created)
+}
+
+func TestFloatingIPRelease(t *testing.T) {
+	// Tear up.
+	var deleted []string
+
+	o, server := stubbedOpenStack(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/network/v2.0/ports":
+			assert.Equal(t, "srv-1", r.URL.Query().Get("device_id"))
+			_, _ = w.Write([]byte(`{"ports": [{"id": "port-1"}]}`))
+		case r.URL.Path == "/network/v2.0/floatingips":
+			_, _ = w.Write([]byte(`{"floatingips": [
+				{"id": "fip-1", "floating_ip_address": "172.24.4.20", "description": "managed by docker-remote"},
+				{"id": "fip-2", "floating_ip_address": "172.24.4.21", "description": "reserved by ops"}
+			]}`))
+		case r.Method == http.MethodDelete:
+			deleted = append(deleted, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+	defer server.Close()
+
+	//Assertions
+	released, err := o.FloatingIPRelease("srv-1")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"172.24.4.20"}, released)
+	assert.Equal(t, []string{"/network/v2.0/floatingips/fip-1"}, deleted)
+}
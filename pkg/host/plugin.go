@@ -0,0 +1,354 @@
+package host
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/knlambert/docker-remote.git/pkg/output"
+	"github.com/knlambert/docker-remote.git/pkg/plugin"
+	"github.com/knlambert/docker-remote.git/pkg/sshutil"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+//Returns a driver talking to a plugin executable, the plugin manages the hosts while
+//SSH, the agent and the docker context are handled here.
+func CreatePluginHost(driverPlugin *plugin.Plugin) DockerHostSystem {
+	return &pluginHostImpl{
+		plugin:  driverPlugin,
+		start:   plugin.Start,
+		helpers: CreatePluginHelpers(),
+	}
+}
+
+type pluginHostImpl struct {
+	plugin  *plugin.Plugin
+	start   func(driverPlugin *plugin.Plugin) (plugin.Client, error)
+	helpers PluginHelpers
+}
+
+type PluginUpParams struct {
+	//The driver specific options, passed as is to the plugin.
+	Options     map[string]string
+	KeyPairPath string
+	//The public key given to the plugin, KeyPairPath with a ".pub" suffix or ~/.ssh/id_rsa.pub when empty.
+	PublicKeyPath string
+	Timeout       time.Duration
+	Rollback      bool
+}
+
+func (p *pluginHostImpl) CobraCommand(
+	command Command,
+) *cobra.Command {
+	switch command {
+	case Up:
+		upParams := PluginUpParams{}
+		upCmd := cobra.Command{
+			Use:   string(command),
+			Short: fmt.Sprintf("Create a host with the %s plugin and use it as the docker host", p.plugin.Name),
+			Run: func(cmd *cobra.Command, args []string) {
+				ctx, cancel := interruptibleContext()
+				defer cancel()
+
+				runAndPrint(cmd, func() (output.Result, error) {
+					return p.Up(ctx, &upParams)
+				})
+			},
+		}
+
+		upCmd.Flags().StringToStringVarP(
+			&upParams.Options, "opt", "", map[string]string{},
+			"A driver specific option given to the plugin, key=value (repeatable)",
+		)
+
+		addPublicKeyFlag(upCmd.Flags(), &upParams.PublicKeyPath, "given to the plugin")
+
+		addUpFlags(upCmd.Flags(), &upParams.KeyPairPath, &upParams.Timeout, &upParams.Rollback)
+
+		return &upCmd
+	}
+
+	return commonCommand(command, p, "Destroy the docker host")
+}
+
+func (p *pluginHostImpl) Cost() (*CostResult, error) {
+	return nil, errors.Errorf("cost is not supported by the %s driver", p.plugin.Name)
+}
+
+func (p *pluginHostImpl) Extend(params interface{}) (*ExtendResult, error) {
+	return nil, errors.Errorf("extend is not supported by the %s driver", p.plugin.Name)
+}
+
+func (p *pluginHostImpl) Reap(params interface{}) (*ReapResult, error) {
+	return nil, errors.Errorf("reap is not supported by the %s driver", p.plugin.Name)
+}
+
+func (p *pluginHostImpl) Down(params interface{}) (*DownResult, error) {
+	metadata, err := p.helpers.DefaultMetadata()
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to calculate metadata")
+	}
+
+	var down plugin.DownResult
+
+	if err := p.call(context.Background(), plugin.MethodDown, &plugin.HostParams{Metadata: metadata}, &down); err != nil {
+		return nil, errors.Wrapf(err, "failed to shutdown the docker host")
+	}
+
+	if err := p.helpers.SSHUtils().SSHAgentRemoveKey(agentKeyComment(p.dockerContextName())); err != nil {
+		return nil, err
+	}
+
+	if err := p.helpers.SSHUtils().SSHConfigRemove(p.dockerContextName()); err != nil {
+		return nil, err
+	}
+
+	return &DownResult{ID: down.ID, Terminated: down.ID != ""}, nil
+}
+
+//Lists every docker host managed by docker-remote, whoever owns it.
+func (p *pluginHostImpl) List() (*ListResult, error) {
+	var list plugin.ListResult
+
+	if err := p.call(context.Background(), plugin.MethodList, &plugin.ListParams{
+		Metadata: map[string]string{"managed_by": "docker-remote"},
+	}, &list); err != nil {
+		return nil, errors.Wrapf(err, "failed to list %s hosts", p.plugin.Name)
+	}
+
+	result := ListResult{Hosts: []HostSummary{}}
+
+	for _, pluginHost := range list.Hosts {
+		result.Hosts = append(result.Hosts, *summarizePluginHost(&pluginHost))
+	}
+
+	return &result, nil
+}
+
+func (p *pluginHostImpl) PortForward(params interface{}) error {
+	return forwardTo(p.helpers, params, p.activeTarget)
+}
+
+func (p *pluginHostImpl) Shell(params interface{}) error {
+	return shellTo(p.helpers, p.activeTarget)
+}
+
+//Describes the docker host of the current user.
+func (p *pluginHostImpl) Status() (*StatusResult, error) {
+	metadata, err := p.helpers.DefaultMetadata()
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to calculate metadata")
+	}
+
+	described, err := p.describe(context.Background(), metadata)
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to describe %s host", p.plugin.Name)
+	}
+
+	if described == nil {
+		return &StatusResult{}, nil
+	}
+
+	return &StatusResult{Host: summarizePluginHost(described)}, nil
+}
+
+func summarizePluginHost(pluginHost *plugin.Host) *HostSummary {
+	return &HostSummary{
+		ID:           pluginHost.ID,
+		Owner:        pluginHost.Owner,
+		State:        pluginHost.State,
+		InstanceType: pluginHost.InstanceType,
+		LaunchTime:   pluginHost.LaunchTime,
+		Address:      pluginHost.Address,
+	}
+}
+
+func (p *pluginHostImpl) Up(ctx context.Context, params interface{}) (*UpResult, error) {
+	upParams := params.(*PluginUpParams)
+
+	metadata, err := p.helpers.DefaultMetadata()
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to calculate metadata")
+	}
+
+	dockerContextName := p.dockerContextName()
+
+	var up plugin.UpResult
+	var target *sshutil.Target
+
+	completed, err := RunSteps(ctx, []Step{{
+		Name: "host creation",
+		Run: func(ctx context.Context) error {
+			publicKey, err := p.helpers.PublicKey(upParams.PublicKeyPath, upParams.KeyPairPath)
+
+			if err != nil {
+				return err
+			}
+
+			if err := p.call(ctx, plugin.MethodUp, &plugin.UpParams{
+				HostParams: plugin.HostParams{Metadata: metadata},
+				PublicKey:  publicKey,
+				Options:    upParams.Options,
+			}, &up); err != nil {
+				return err
+			}
+
+			if up.Created {
+				log.Printf("Host %s created", up.Host.ID)
+			}
+
+			return nil
+		},
+		Rollback: func() error {
+			if !up.Created {
+				return nil
+			}
+			return p.call(context.Background(), plugin.MethodDown, &plugin.HostParams{Metadata: metadata}, nil)
+		},
+		Resource: func() string {
+			if !up.Created {
+				return ""
+			}
+			return fmt.Sprintf("%s host %s", p.plugin.Name, up.Host.ID)
+		},
+	}, agentKeyStep(p.helpers, p.dockerContextName(), upParams.KeyPairPath), {
+		Name: "readiness",
+		Run: func(ctx context.Context) error {
+			if err := createReadinessWaiter(upParams.Timeout).Wait(
+				ctx, p.readinessStages(metadata, &target),
+			); err != nil {
+				return err
+			}
+
+			log.Println("Host is ready !")
+
+			return nil
+		},
+	}, dockerContextStep(p.helpers, dockerContextName, func() (*sshutil.Target, error) {
+		return target, nil
+	}, nil)})
+
+	if err != nil {
+		HandleStepsFailure(completed, upParams.Rollback)
+		return nil, err
+	}
+
+	return &UpResult{
+		ID:            up.Host.ID,
+		Address:       target.Host,
+		DockerContext: dockerContextName,
+		Created:       up.Created,
+	}, nil
+}
+
+//Stages a host goes through before docker can be used on it, the plugin tells
+//how to reach it as soon as it can.
+func (p *pluginHostImpl) readinessStages(
+	metadata map[string]string,
+	target **sshutil.Target,
+) []ReadinessStage {
+	sshUtils := p.helpers.SSHUtils()
+
+	return []ReadinessStage{{
+		Name: "connection info",
+		Probe: func(ctx context.Context) error {
+			var err error
+			*target, err = p.connection(ctx, metadata)
+			return err
+		},
+	}, {
+		Name: "SSH",
+		Probe: func(ctx context.Context) error {
+			_, err := sshUtils.SSHRun(*target, "true")
+			return err
+		},
+	}, {
+		Name: "docker daemon",
+		Probe: func(ctx context.Context) error {
+			return sshUtils.DockerPing(*target)
+		},
+	}}
+}
+
+//Returns how to reach the host of the current user over SSH.
+func (p *pluginHostImpl) connection(ctx context.Context, metadata map[string]string) (*sshutil.Target, error) {
+	var connection plugin.ConnectionResult
+
+	if err := p.call(ctx, plugin.MethodConnection, &plugin.HostParams{Metadata: metadata}, &connection); err != nil {
+		return nil, err
+	}
+
+	if connection.Host == "" {
+		return nil, errors.New("the host has no address yet")
+	}
+
+	jumps, err := sshutil.ParseJumps(connection.Jumps)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid jump hosts returned by the plugin")
+	}
+
+	return &sshutil.Target{
+		Endpoint:     sshutil.Endpoint{User: connection.User, Host: connection.Host, Port: connection.Port},
+		Jumps:        jumps,
+		ProxyCommand: connection.ProxyCommand,
+	}, nil
+}
+
+func (p *pluginHostImpl) describe(ctx context.Context, metadata map[string]string) (*plugin.Host, error) {
+	var describe plugin.DescribeResult
+
+	if err := p.call(ctx, plugin.MethodDescribe, &plugin.HostParams{Metadata: metadata}, &describe); err != nil {
+		return nil, err
+	}
+
+	return describe.Host, nil
+}
+
+//Returns how to reach the host of the current user, fails if there is none.
+func (p *pluginHostImpl) activeTarget() (*sshutil.Target, error) {
+	metadata, err := p.helpers.DefaultMetadata()
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to calculate metadata")
+	}
+
+	described, err := p.describe(context.Background(), metadata)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if described == nil {
+		return nil, errors.Errorf("Please create the host first")
+	}
+
+	return p.connection(context.Background(), metadata)
+}
+
+//Runs a method in a new plugin process.
+func (p *pluginHostImpl) call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	client, err := p.start(p.plugin)
+
+	if err != nil {
+		return err
+	}
+
+	if err := client.Call(ctx, method, params, result); err != nil {
+		_ = client.Close()
+		return err
+	}
+
+	return client.Close()
+}
+
+//The docker context (and SSH alias) of the hosts of the plugin.
+func (p *pluginHostImpl) dockerContextName() string {
+	return fmt.Sprintf("docker-remote-%s", p.plugin.Name)
+}
@@ -0,0 +1,389 @@
+package host
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/knlambert/docker-remote.git/pkg/host/proxmox"
+	"github.com/knlambert/docker-remote.git/pkg/output"
+	"github.com/knlambert/docker-remote.git/pkg/sshutil"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	//The docker context (and SSH alias) of the host.
+	vmDockerContextName = "docker-remote-proxmox"
+	//The prefix of the VM names, followed by the owner.
+	vmNamePrefix = "docker-remote-"
+)
+
+func CreateProxmoxHost() DockerHostSystem {
+	return &vmHostImpl{
+		proxmox: proxmox.Create(),
+		helpers: CreatePluginHelpers(),
+	}
+}
+
+type vmHostImpl struct {
+	proxmox proxmox.Proxmox
+	helpers PluginHelpers
+}
+
+type VMUpParams struct {
+	//The cloud-init enabled template to clone, by name or VM ID.
+	Template    string
+	FullClone   bool
+	IPConfig    string
+	CICustom    string
+	KeyPairPath string
+	//The public key given to cloud-init, KeyPairPath with a ".pub" suffix or ~/.ssh/id_rsa.pub when empty.
+	PublicKeyPath string
+	Timeout       time.Duration
+	Rollback      bool
+}
+
+func (v *vmHostImpl) CobraCommand(
+	command Command,
+) *cobra.Command {
+	switch command {
+	case Up:
+		upParams := VMUpParams{}
+		upCmd := cobra.Command{
+			Use:   string(command),
+			Short: "Clone a Proxmox template running docker and use it as the docker host",
+			Run: func(cmd *cobra.Command, args []string) {
+				ctx, cancel := interruptibleContext()
+				defer cancel()
+
+				runAndPrint(cmd, func() (output.Result, error) {
+					return v.Up(ctx, &upParams)
+				})
+			},
+		}
+
+		upCmd.Flags().StringVarP(
+			&upParams.Template, "template", "", "",
+			"The cloud-init enabled template to clone, by name or VM ID (with the QEMU guest agent installed)",
+		)
+		upCmd.Flags().BoolVarP(
+			&upParams.FullClone, "full-clone", "", false, "Make a full clone instead of a linked one",
+		)
+		upCmd.Flags().StringVarP(
+			&upParams.IPConfig, "ip-config", "", "ip=dhcp",
+			"The cloud-init network configuration, 'ip=10.0.0.20/24,gw=10.0.0.1' for instance",
+		)
+		upCmd.Flags().StringVarP(
+			&upParams.CICustom, "cicustom", "", "",
+			"Custom cloud-init files (user-data), 'user=local:snippets/docker.yaml' for instance",
+		)
+
+		addPublicKeyFlag(upCmd.Flags(), &upParams.PublicKeyPath, "given to cloud-init")
+
+		addUpFlags(upCmd.Flags(), &upParams.KeyPairPath, &upParams.Timeout, &upParams.Rollback)
+
+		return &upCmd
+	}
+
+	return commonCommand(command, v, "Stop and destroy the docker host")
+}
+
+func (v *vmHostImpl) Cost() (*CostResult, error) {
+	return nil, errors.New("cost is not supported by the proxmox driver")
+}
+
+func (v *vmHostImpl) Extend(params interface{}) (*ExtendResult, error) {
+	return nil, errors.New("extend is not supported by the proxmox driver")
+}
+
+func (v *vmHostImpl) Reap(params interface{}) (*ReapResult, error) {
+	return nil, errors.New("reap is not supported by the proxmox driver")
+}
+
+//Stops and destroys the clone of the current user.
+func (v *vmHostImpl) Down(params interface{}) (*DownResult, error) {
+	name, _, err := v.identity()
+
+	if err != nil {
+		return nil, err
+	}
+
+	vm, err := v.proxmox.VMDescribe(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := DownResult{}
+
+	if vm != nil {
+		if err := v.proxmox.VMDestroy(vm); err != nil {
+			return nil, errors.Wrapf(err, "failed to shutdown the docker host")
+		}
+
+		result.ID = strconv.Itoa(vm.ID)
+		result.Terminated = true
+	}
+
+	if err := v.helpers.SSHUtils().SSHAgentRemoveKey(agentKeyComment(vmDockerContextName)); err != nil {
+		return nil, err
+	}
+
+	if err := v.helpers.SSHUtils().SSHConfigRemove(vmDockerContextName); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+//Lists every docker host managed by docker-remote, whoever owns it.
+func (v *vmHostImpl) List() (*ListResult, error) {
+	vms, err := v.proxmox.VMList()
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list proxmox hosts")
+	}
+
+	result := ListResult{Hosts: []HostSummary{}}
+
+	for _, vm := range vms {
+		result.Hosts = append(result.Hosts, *summarizeVM(vm, ""))
+	}
+
+	return &result, nil
+}
+
+func (v *vmHostImpl) PortForward(params interface{}) error {
+	return forwardTo(v.helpers, params, v.runningTarget)
+}
+
+func (v *vmHostImpl) Shell(params interface{}) error {
+	return shellTo(v.helpers, v.runningTarget)
+}
+
+//Describes the docker host of the current user.
+func (v *vmHostImpl) Status() (*StatusResult, error) {
+	name, _, err := v.identity()
+
+	if err != nil {
+		return nil, err
+	}
+
+	vm, err := v.proxmox.VMDescribe(name)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to describe proxmox host")
+	}
+
+	if vm == nil {
+		return &StatusResult{}, nil
+	}
+
+	address := ""
+
+	if vm.Status == "running" {
+		if address, err = v.proxmox.VMAddress(vm); err != nil {
+			return nil, err
+		}
+	}
+
+	return &StatusResult{Host: summarizeVM(vm, address)}, nil
+}
+
+func summarizeVM(vm *proxmox.VMDescription, address string) *HostSummary {
+	return &HostSummary{
+		ID:           strconv.Itoa(vm.ID),
+		Owner:        strings.TrimPrefix(vm.Name, vmNamePrefix),
+		State:        vm.Status,
+		InstanceType: fmt.Sprintf("%s on %s", vm.Size(), vm.Node),
+		LaunchTime:   vm.StartTime,
+		Address:      address,
+	}
+}
+
+func (v *vmHostImpl) Up(ctx context.Context, params interface{}) (*UpResult, error) {
+	upParams := params.(*VMUpParams)
+
+	name, sshUser, err := v.identity()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var vm *proxmox.VMDescription
+	var createdVM *proxmox.VMDescription
+	var target *sshutil.Target
+
+	completed, err := RunSteps(ctx, []Step{{
+		Name: "VM creation",
+		Run: func(ctx context.Context) error {
+			vm, err = v.proxmox.VMDescribe(name)
+
+			if err != nil {
+				return errors.Wrap(err, "failed to describe proxmox host")
+			}
+
+			if vm != nil {
+				if vm.Status != "running" {
+					return errors.Errorf("VM %d is %s, start it or destroy it with down", vm.ID, vm.Status)
+				}
+				return nil
+			}
+
+			if upParams.Template == "" {
+				return errors.New("--template is required to create the VM")
+			}
+
+			publicKey, err := v.helpers.PublicKey(upParams.PublicKeyPath, upParams.KeyPairPath)
+
+			if err != nil {
+				return err
+			}
+
+			vm, err = v.proxmox.VMCreate(&proxmox.VMCreateParams{
+				Name:      name,
+				Template:  upParams.Template,
+				FullClone: upParams.FullClone,
+				User:      sshUser,
+				PublicKey: publicKey,
+				IPConfig:  upParams.IPConfig,
+				CICustom:  upParams.CICustom,
+			})
+
+			//A clone is returned along with configuration errors, the rollback destroys it.
+			createdVM = vm
+
+			if err != nil {
+				return err
+			}
+
+			log.Printf("VM %d created on %s", vm.ID, vm.Node)
+
+			return nil
+		},
+		Rollback: func() error {
+			if createdVM == nil {
+				return nil
+			}
+			return v.proxmox.VMDestroy(createdVM)
+		},
+		Resource: func() string {
+			if createdVM == nil {
+				return ""
+			}
+			return fmt.Sprintf("Proxmox VM %d", createdVM.ID)
+		},
+	}, agentKeyStep(v.helpers, vmDockerContextName, upParams.KeyPairPath), {
+		Name: "readiness",
+		Run: func(ctx context.Context) error {
+			return createReadinessWaiter(upParams.Timeout).Wait(ctx, []ReadinessStage{{
+				Name: "guest agent address",
+				Probe: func(ctx context.Context) error {
+					address, err := v.proxmox.VMAddress(vm)
+
+					if err != nil {
+						return err
+					} else if address == "" {
+						return errors.New("the guest agent reports no address yet")
+					}
+
+					target = &sshutil.Target{Endpoint: sshutil.Endpoint{User: sshUser, Host: address}}
+
+					return nil
+				},
+			}, {
+				Name: "SSH",
+				Probe: func(ctx context.Context) error {
+					_, err := v.helpers.SSHUtils().SSHRun(target, "true")
+					return err
+				},
+			}, {
+				Name: "cloud-init",
+				Probe: func(ctx context.Context) error {
+					_, err := v.helpers.SSHUtils().SSHRun(target, "test -f /var/lib/cloud/instance/boot-finished")
+					return err
+				},
+			}})
+		},
+	}, {
+		Name: "docker installation",
+		Run: func(ctx context.Context) error {
+			if err := installDocker(v.helpers.SSHUtils(), target); err != nil {
+				return err
+			}
+
+			return createReadinessWaiter(upParams.Timeout).Wait(ctx, []ReadinessStage{{
+				Name: "docker daemon",
+				Probe: func(ctx context.Context) error {
+					return v.helpers.SSHUtils().DockerPing(target)
+				},
+			}})
+		},
+	}, dockerContextStep(v.helpers, vmDockerContextName, func() (*sshutil.Target, error) {
+		return target, nil
+	}, nil)})
+
+	if err != nil {
+		HandleStepsFailure(completed, upParams.Rollback)
+		return nil, err
+	}
+
+	return &UpResult{
+		ID:            strconv.Itoa(vm.ID),
+		Address:       target.Host,
+		DockerContext: vmDockerContextName,
+		Created:       createdVM != nil,
+	}, nil
+}
+
+//Returns the VM of the current user, fails if it is not running.
+func (v *vmHostImpl) runningTarget() (*sshutil.Target, error) {
+	name, sshUser, err := v.identity()
+
+	if err != nil {
+		return nil, err
+	}
+
+	vm, err := v.proxmox.VMDescribe(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if vm == nil || vm.Status != "running" {
+		return nil, errors.Errorf("Please create the host first")
+	}
+
+	address, err := v.proxmox.VMAddress(vm)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if address == "" {
+		return nil, errors.Errorf("the guest agent of VM %d reports no address", vm.ID)
+	}
+
+	return &sshutil.Target{Endpoint: sshutil.Endpoint{User: sshUser, Host: address}}, nil
+}
+
+var vmNameInvalidCharacters = regexp.MustCompile(`[^a-z0-9-]`)
+
+//Returns the VM name and the cloud-init user of the current user. VM names are DNS names,
+//the owner is the part after the prefix.
+func (v *vmHostImpl) identity() (string, string, error) {
+	metadata, err := v.helpers.DefaultMetadata()
+
+	if err != nil {
+		return "", "", errors.Wrap(err, "failed to calculate metadata")
+	}
+
+	owner := vmNameInvalidCharacters.ReplaceAllString(strings.ToLower(metadata["owner"]), "-")
+
+	return vmNamePrefix + owner, owner, nil
+}
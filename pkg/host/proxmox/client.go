@@ -0,0 +1,218 @@
+package proxmox
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+//The Proxmox VE API, the subset docker-remote uses. Methods starting a task return its UPID.
+type Client interface {
+	AgentInterfaces(node string, vmid int) ([]*GuestInterface, error)
+	ClusterResources() ([]*Resource, error)
+	NextID() (int, error)
+	TaskStatus(node string, upid string) (*Task, error)
+	VMClone(node string, vmid int, newid int, name string, full bool) (string, error)
+	VMConfig(node string, vmid int, config url.Values) error
+	VMDelete(node string, vmid int) (string, error)
+	VMStart(node string, vmid int) (string, error)
+	VMStop(node string, vmid int) (string, error)
+}
+
+//A VM (or container) of the cluster.
+type Resource struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	VMID     int    `json:"vmid"`
+	Name     string `json:"name"`
+	Node     string `json:"node"`
+	Status   string `json:"status"`
+	Template int    `json:"template"`
+	Tags     string `json:"tags"`
+	MaxCPU   int    `json:"maxcpu"`
+	MaxMem   int64  `json:"maxmem"`
+	Uptime   int64  `json:"uptime"`
+}
+
+//A network interface reported by the QEMU guest agent.
+type GuestInterface struct {
+	Name        string `json:"name"`
+	IPAddresses []struct {
+		Address string `json:"ip-address"`
+		Type    string `json:"ip-address-type"`
+	} `json:"ip-addresses"`
+}
+
+type Task struct {
+	//"running" or "stopped".
+	Status string `json:"status"`
+	//"OK" when the task succeeded.
+	ExitStatus string `json:"exitstatus"`
+}
+
+func createRESTClient(config func() (*session, error)) Client {
+	return &restClient{session: config}
+}
+
+//The resolved connection settings.
+type session struct {
+	url         string
+	tokenID     string
+	tokenSecret string
+	insecure    bool
+}
+
+type restClient struct {
+	session func() (*session, error)
+}
+
+func (c *restClient) AgentInterfaces(node string, vmid int) ([]*GuestInterface, error) {
+	var res struct {
+		Result []*GuestInterface `json:"result"`
+	}
+	return res.Result, c.do(http.MethodGet, fmt.Sprintf("/nodes/%s/qemu/%d/agent/network-get-interfaces", node, vmid), nil, &res)
+}
+
+func (c *restClient) ClusterResources() ([]*Resource, error) {
+	var res []*Resource
+	return res, c.do(http.MethodGet, "/cluster/resources?type=vm", nil, &res)
+}
+
+func (c *restClient) NextID() (int, error) {
+	//Older versions answer a number, newer ones a string.
+	var res json.Number
+
+	if err := c.do(http.MethodGet, "/cluster/nextid", nil, &res); err != nil {
+		return 0, err
+	}
+
+	id, err := res.Int64()
+
+	return int(id), errors.Wrap(err, "invalid next VM ID")
+}
+
+func (c *restClient) TaskStatus(node string, upid string) (*Task, error) {
+	var res Task
+	return &res, c.do(http.MethodGet, fmt.Sprintf("/nodes/%s/tasks/%s/status", node, url.PathEscape(upid)), nil, &res)
+}
+
+func (c *restClient) VMClone(node string, vmid int, newid int, name string, full bool) (string, error) {
+	form := url.Values{}
+	form.Set("newid", fmt.Sprint(newid))
+	form.Set("name", name)
+
+	if full {
+		form.Set("full", "1")
+	}
+
+	var upid string
+	return upid, c.do(http.MethodPost, fmt.Sprintf("/nodes/%s/qemu/%d/clone", node, vmid), form, &upid)
+}
+
+func (c *restClient) VMConfig(node string, vmid int, config url.Values) error {
+	return c.do(http.MethodPost, fmt.Sprintf("/nodes/%s/qemu/%d/config", node, vmid), config, nil)
+}
+
+func (c *restClient) VMDelete(node string, vmid int) (string, error) {
+	var upid string
+	return upid, c.do(http.MethodDelete, fmt.Sprintf("/nodes/%s/qemu/%d?purge=1", node, vmid), nil, &upid)
+}
+
+func (c *restClient) VMStart(node string, vmid int) (string, error) {
+	var upid string
+	return upid, c.do(http.MethodPost, fmt.Sprintf("/nodes/%s/qemu/%d/status/start", node, vmid), url.Values{}, &upid)
+}
+
+func (c *restClient) VMStop(node string, vmid int) (string, error) {
+	var upid string
+	return upid, c.do(http.MethodPost, fmt.Sprintf("/nodes/%s/qemu/%d/status/stop", node, vmid), url.Values{}, &upid)
+}
+
+//An error answered by the API.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("Proxmox API answered %d: %s", e.StatusCode, e.Message)
+}
+
+//Sends a form request authenticated with the API token, decodes the "data" of the response into out.
+func (c *restClient) do(method string, path string, form url.Values, out interface{}) error {
+	s, err := c.session()
+
+	if err != nil {
+		return err
+	}
+
+	var body string
+
+	if form != nil {
+		body = form.Encode()
+	}
+
+	req, err := http.NewRequest(method, s.url+"/api2/json"+path, strings.NewReader(body))
+
+	if err != nil {
+		return errors.Wrap(err, "failed to create the request")
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("PVEAPIToken=%s=%s", s.tokenID, s.tokenSecret))
+
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: s.insecure},
+		},
+	}
+
+	res, err := client.Do(req)
+
+	if err != nil {
+		return errors.Wrapf(err, "failed to call %s %s", method, path)
+	}
+
+	defer res.Body.Close()
+
+	content, err := ioutil.ReadAll(res.Body)
+
+	if err != nil {
+		return errors.Wrap(err, "failed to read the response")
+	}
+
+	if res.StatusCode >= 300 {
+		//The reason is in the status line, the parameter errors in the body.
+		message := strings.TrimSpace(res.Status + " " + string(content))
+		return &APIError{StatusCode: res.StatusCode, Message: message}
+	}
+
+	if out != nil {
+		var envelope struct {
+			Data json.RawMessage `json:"data"`
+		}
+
+		if err := json.Unmarshal(content, &envelope); err != nil {
+			return errors.Wrap(err, "failed to decode the response")
+		}
+
+		if len(envelope.Data) > 0 && string(envelope.Data) != "null" {
+			if err := json.Unmarshal(envelope.Data, out); err != nil {
+				return errors.Wrap(err, "failed to decode the response")
+			}
+		}
+	}
+
+	return nil
+}
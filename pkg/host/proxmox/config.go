@@ -0,0 +1,31 @@
+package proxmox
+
+import (
+	"github.com/spf13/pflag"
+)
+
+//Options of the Proxmox client, the PROXMOX_* environment applies to empty ones.
+type SessionConfig struct {
+	//The API URL, "https://pve.example.com:8006".
+	URL string
+	//The API token ID, "user@realm!token".
+	TokenID string
+	//Skips the verification of the certificate, self-signed by default on Proxmox.
+	Insecure bool
+}
+
+//The session options given on the command line, see AddFlags.
+var CommandLineConfig = SessionConfig{}
+
+//Adds the flags filling CommandLineConfig.
+func AddFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(&CommandLineConfig.URL, "proxmox-url", "", "", "The Proxmox API URL (default: PROXMOX_URL)")
+	flags.StringVarP(
+		&CommandLineConfig.TokenID, "proxmox-token-id", "", "",
+		"The Proxmox API token ID, 'user@realm!token' (default: PROXMOX_TOKEN_ID), its secret is read from PROXMOX_TOKEN_SECRET",
+	)
+	flags.BoolVarP(
+		&CommandLineConfig.Insecure, "proxmox-insecure", "", false,
+		"Skip the verification of the Proxmox certificate",
+	)
+}
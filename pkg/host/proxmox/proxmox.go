@@ -0,0 +1,315 @@
+package proxmox
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+//The tag of the VMs managed by docker-remote.
+const managedTag = "docker-remote"
+
+type Proxmox interface {
+	//Clones a template, configures the cloud-init of the clone and starts it. On failure, the
+	//clone created so far is returned along with the error.
+	VMCreate(params *VMCreateParams) (*VMDescription, error)
+	//Returns the managed VM with this name, nil if there is none.
+	VMDescribe(name string) (*VMDescription, error)
+	//Stops and destroys a VM.
+	VMDestroy(vm *VMDescription) error
+	//Returns the IPv4 address the guest agent reports, empty while the agent is not running.
+	VMAddress(vm *VMDescription) (string, error)
+	VMList() ([]*VMDescription, error)
+}
+
+func Create() Proxmox {
+	return &proxmoxImpl{
+		client: createRESTClient(func() (*session, error) {
+			return resolveSession(&CommandLineConfig, os.Getenv)
+		}),
+		pollInterval: 2 * time.Second,
+	}
+}
+
+type proxmoxImpl struct {
+	client Client
+	//How often the tasks are polled.
+	pollInterval time.Duration
+}
+
+func resolveSession(config *SessionConfig, getenv func(string) string) (*session, error) {
+	s := session{
+		url:         config.URL,
+		tokenID:     config.TokenID,
+		tokenSecret: getenv("PROXMOX_TOKEN_SECRET"),
+		insecure:    config.Insecure || getenv("PROXMOX_INSECURE") == "true",
+	}
+
+	if s.url == "" {
+		s.url = getenv("PROXMOX_URL")
+	}
+
+	if s.tokenID == "" {
+		s.tokenID = getenv("PROXMOX_TOKEN_ID")
+	}
+
+	if s.url == "" || s.tokenID == "" || s.tokenSecret == "" {
+		return nil, errors.New(
+			"no Proxmox API configured, set PROXMOX_URL, PROXMOX_TOKEN_ID and PROXMOX_TOKEN_SECRET",
+		)
+	}
+
+	s.url = strings.TrimSuffix(strings.TrimSuffix(s.url, "/"), "/api2/json")
+
+	return &s, nil
+}
+
+type VMCreateParams struct {
+	Name string
+	//The template to clone, by name or VM ID.
+	Template string
+	//Makes a full clone instead of a linked one.
+	FullClone bool
+	//The cloud-init user and its SSH public key.
+	User      string
+	PublicKey string
+	//The cloud-init network configuration, "ip=dhcp" when empty.
+	IPConfig string
+	//Custom cloud-init files, "user=local:snippets/docker.yaml" for instance.
+	CICustom string
+}
+
+type VMDescription struct {
+	ID     int
+	Node   string
+	Name   string
+	Status string
+	CPUs   int
+	Memory int64
+	//When the VM was started, nil when it's not running.
+	StartTime *time.Time
+}
+
+func (p *proxmoxImpl) VMCreate(params *VMCreateParams) (*VMDescription, error) {
+	template, err := p.template(params.Template)
+
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := p.client.NextID()
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get a VM ID")
+	}
+
+	upid, err := p.client.VMClone(template.Node, template.VMID, id, params.Name, params.FullClone)
+
+	if err == nil {
+		err = p.wait(template.Node, upid)
+	}
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to clone template %s", params.Template)
+	}
+
+	vm := VMDescription{ID: id, Node: template.Node, Name: params.Name, Status: "stopped"}
+
+	ipConfig := params.IPConfig
+
+	if ipConfig == "" {
+		ipConfig = "ip=dhcp"
+	}
+
+	config := url.Values{}
+	config.Set("tags", managedTag)
+	config.Set("agent", "1")
+	config.Set("ciuser", params.User)
+	//The keys are URL encoded once more, as the API expects.
+	config.Set("sshkeys", strings.Replace(url.QueryEscape(strings.TrimSpace(params.PublicKey)), "+", "%20", -1))
+	config.Set("ipconfig0", ipConfig)
+
+	if params.CICustom != "" {
+		config.Set("cicustom", params.CICustom)
+	}
+
+	if err := p.client.VMConfig(vm.Node, vm.ID, config); err != nil {
+		return &vm, errors.Wrapf(err, "failed to configure VM %d", vm.ID)
+	}
+
+	upid, err = p.client.VMStart(vm.Node, vm.ID)
+
+	if err == nil {
+		err = p.wait(vm.Node, upid)
+	}
+
+	if err != nil {
+		return &vm, errors.Wrapf(err, "failed to start VM %d", vm.ID)
+	}
+
+	vm.Status = "running"
+
+	return &vm, nil
+}
+
+//Returns the template given by name or VM ID.
+func (p *proxmoxImpl) template(nameOrId string) (*Resource, error) {
+	resources, err := p.client.ClusterResources()
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list the VMs")
+	}
+
+	var matches []*Resource
+
+	for _, resource := range resources {
+		if resource.Template == 1 && (resource.Name == nameOrId || strconv.Itoa(resource.VMID) == nameOrId) {
+			matches = append(matches, resource)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, errors.Errorf("no template '%s'", nameOrId)
+	case 1:
+		return matches[0], nil
+	}
+
+	return nil, errors.Errorf("%d templates are named '%s', use a VM ID", len(matches), nameOrId)
+}
+
+//Waits for a task to end, fails if it did not succeed.
+func (p *proxmoxImpl) wait(node string, upid string) error {
+	for {
+		task, err := p.client.TaskStatus(node, upid)
+
+		if err != nil {
+			return errors.Wrapf(err, "failed to follow task %s", upid)
+		}
+
+		if task.Status == "stopped" {
+			if task.ExitStatus != "OK" {
+				return errors.Errorf("task %s failed: %s", upid, task.ExitStatus)
+			}
+			return nil
+		}
+
+		time.Sleep(p.pollInterval)
+	}
+}
+
+func (p *proxmoxImpl) VMDescribe(name string) (*VMDescription, error) {
+	vms, err := p.VMList()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, vm := range vms {
+		if vm.Name == name {
+			return vm, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (p *proxmoxImpl) VMList() ([]*VMDescription, error) {
+	resources, err := p.client.ClusterResources()
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list the VMs")
+	}
+
+	var vms []*VMDescription
+
+	for _, resource := range resources {
+		if resource.Type != "qemu" || resource.Template == 1 || !hasTag(resource.Tags, managedTag) {
+			continue
+		}
+
+		vm := VMDescription{
+			ID:     resource.VMID,
+			Node:   resource.Node,
+			Name:   resource.Name,
+			Status: resource.Status,
+			CPUs:   resource.MaxCPU,
+			Memory: resource.MaxMem,
+		}
+
+		if resource.Uptime > 0 {
+			startTime := time.Now().Add(-time.Duration(resource.Uptime) * time.Second).Truncate(time.Second)
+			vm.StartTime = &startTime
+		}
+
+		vms = append(vms, &vm)
+	}
+
+	return vms, nil
+}
+
+func hasTag(tags string, tag string) bool {
+	for _, value := range strings.FieldsFunc(tags, func(r rune) bool { return r == ';' || r == ',' || r == ' ' }) {
+		if value == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *proxmoxImpl) VMAddress(vm *VMDescription) (string, error) {
+	interfaces, err := p.client.AgentInterfaces(vm.Node, vm.ID)
+
+	if _, ok := errors.Cause(err).(*APIError); ok {
+		//The API answers an error until the agent runs.
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	for _, networkInterface := range interfaces {
+		if networkInterface.Name == "lo" {
+			continue
+		}
+
+		for _, address := range networkInterface.IPAddresses {
+			if address.Type == "ipv4" && !strings.HasPrefix(address.Address, "127.") {
+				return address.Address, nil
+			}
+		}
+	}
+
+	return "", nil
+}
+
+func (p *proxmoxImpl) VMDestroy(vm *VMDescription) error {
+	if vm.Status == "running" {
+		upid, err := p.client.VMStop(vm.Node, vm.ID)
+
+		if err == nil {
+			err = p.wait(vm.Node, upid)
+		}
+
+		if err != nil {
+			return errors.Wrapf(err, "failed to stop VM %d", vm.ID)
+		}
+	}
+
+	upid, err := p.client.VMDelete(vm.Node, vm.ID)
+
+	if err == nil {
+		err = p.wait(vm.Node, upid)
+	}
+
+	return errors.Wrapf(err, "failed to destroy VM %d", vm.ID)
+}
+
+//Formats the size of a VM, "2 vCPU / 4 GiB".
+func (vm *VMDescription) Size() string {
+	return fmt.Sprintf("%d vCPU / %d GiB", vm.CPUs, vm.Memory>>30)
+}
@@ -0,0 +1,167 @@
+package proxmox
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//A fake Proxmox API, recording the calls it gets.
+type fakeAPI struct {
+	sync.Mutex
+	calls  []string
+	config url.Values
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+
+	if r.Header.Get("Authorization") != "PVEAPIToken=docker@pve!remote=secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	_ = r.ParseForm()
+	f.calls = append(f.calls, fmt.Sprintf("%s %s", r.Method, r.URL.Path))
+
+	switch r.URL.Path {
+	case "/api2/json/cluster/resources":
+		_, _ = w.Write([]byte(`{"data": [
+			{"type": "qemu", "vmid": 9000, "name": "ubuntu-cloud", "node": "pve1", "template": 1},
+			{"type": "qemu", "vmid": 101, "name": "docker-remote-barney", "node": "pve2", "status": "running",
+				"tags": "docker-remote", "maxcpu": 2, "maxmem": 4294967296, "uptime": 60},
+			{"type": "qemu", "vmid": 102, "name": "docker-remote-fred", "node": "pve2", "status": "running"}
+		]}`))
+	case "/api2/json/cluster/nextid":
+		_, _ = w.Write([]byte(`{"data": "105"}`))
+	case "/api2/json/nodes/pve1/qemu/105/config":
+		f.config = r.PostForm
+		_, _ = w.Write([]byte(`{"data": null}`))
+	case "/api2/json/nodes/pve2/qemu/101/agent/network-get-interfaces":
+		_, _ = w.Write([]byte(`{"data": {"result": [
+			{"name": "lo", "ip-addresses": [{"ip-address": "127.0.0.1", "ip-address-type": "ipv4"}]},
+			{"name": "eth0", "ip-addresses": [
+				{"ip-address": "fe80::1", "ip-address-type": "ipv6"},
+				{"ip-address": "192.168.1.50", "ip-address-type": "ipv4"}
+			]}
+		]}}`))
+	case "/api2/json/nodes/pve1/qemu/106/agent/network-get-interfaces":
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(`{"data": null}`))
+	default:
+		if len(r.URL.Path) > len("/api2/json/nodes/pve1/tasks/") && r.Method == http.MethodGet {
+			_, _ = w.Write([]byte(`{"data": {"status": "stopped", "exitstatus": "OK"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data": "UPID:pve1:0001:task"}`))
+	}
+}
+
+func stubbedProxmox() (*proxmoxImpl, *fakeAPI, *httptest.Server) {
+	api := &fakeAPI{}
+	server := httptest.NewServer(api)
+
+	return &proxmoxImpl{
+		client: createRESTClient(func() (*session, error) {
+			return &session{url: server.URL, tokenID: "docker@pve!remote", tokenSecret: "secret"}, nil
+		}),
+	}, api, server
+}
+
+func TestVMCreate(t *testing.T) {
+	// Tear up.
+	p, api, server := stubbedProxmox()
+	defer server.Close()
+
+	//Assertions
+	vm, err := p.VMCreate(&VMCreateParams{
+		Name:      "docker-remote-barney",
+		Template:  "ubuntu-cloud",
+		User:      "barney",
+		PublicKey: "ssh-ed25519 AAAA barney@laptop\n",
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, &VMDescription{ID: 105, Node: "pve1", Name: "docker-remote-barney", Status: "running"}, vm)
+	assert.Equal(t, []string{
+		"GET /api2/json/cluster/resources",
+		"GET /api2/json/cluster/nextid",
+		"POST /api2/json/nodes/pve1/qemu/9000/clone",
+		"GET /api2/json/nodes/pve1/tasks/UPID:pve1:0001:task/status",
+		"POST /api2/json/nodes/pve1/qemu/105/config",
+		"POST /api2/json/nodes/pve1/qemu/105/status/start",
+		"GET /api2/json/nodes/pve1/tasks/UPID:pve1:0001:task/status",
+	}, api.calls)
+	assert.Equal(t, "ssh-ed25519%20AAAA%20barney%40laptop", api.config.Get("sshkeys"))
+	assert.Equal(t, "ip=dhcp", api.config.Get("ipconfig0"))
+	assert.Equal(t, "docker-remote", api.config.Get("tags"))
+}
+
+func TestVMDescribeAndAddress(t *testing.T) {
+	// Tear up.
+	p, _, server := stubbedProxmox()
+	defer server.Close()
+
+	//Assertions
+	vm, err := p.VMDescribe("docker-remote-barney")
+
+	assert.Nil(t, err)
+	assert.Equal(t, 101, vm.ID)
+	assert.Equal(t, "2 vCPU / 4 GiB", vm.Size())
+	assert.NotNil(t, vm.StartTime)
+
+	address, err := p.VMAddress(vm)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "192.168.1.50", address)
+
+	address, err = p.VMAddress(&VMDescription{ID: 106, Node: "pve1"})
+
+	assert.Nil(t, err, "the agent is not running yet")
+	assert.Equal(t, "", address)
+
+	vm, err = p.VMDescribe("docker-remote-fred")
+
+	assert.Nil(t, err)
+	assert.Nil(t, vm, "VMs without the tag are not managed")
+}
+
+func TestVMDestroy(t *testing.T) {
+	// Tear up.
+	p, api, server := stubbedProxmox()
+	defer server.Close()
+
+	//Assertions
+	assert.Nil(t, p.VMDestroy(&VMDescription{ID: 101, Node: "pve2", Status: "running"}))
+	assert.Equal(t, []string{
+		"POST /api2/json/nodes/pve2/qemu/101/status/stop",
+		"GET /api2/json/nodes/pve2/tasks/UPID:pve1:0001:task/status",
+		"DELETE /api2/json/nodes/pve2/qemu/101",
+		"GET /api2/json/nodes/pve2/tasks/UPID:pve1:0001:task/status",
+	}, api.calls)
+}
+
+func TestResolveSession(t *testing.T) {
+	env := map[string]string{
+		"PROXMOX_URL":          "https://pve.corp:8006/api2/json/",
+		"PROXMOX_TOKEN_ID":     "docker@pve!remote",
+		"PROXMOX_TOKEN_SECRET": "secret",
+	}
+
+	//Assertions
+	s, err := resolveSession(&SessionConfig{}, func(name string) string { return env[name] })
+
+	assert.Nil(t, err)
+	assert.Equal(t, "https://pve.corp:8006", s.url)
+	assert.Equal(t, "docker@pve!remote", s.tokenID)
+
+	_, err = resolveSession(&SessionConfig{URL: "https://pve.corp:8006"}, func(string) string { return "" })
+
+	assert.EqualError(t, err, "no Proxmox API configured, set PROXMOX_URL, PROXMOX_TOKEN_ID and PROXMOX_TOKEN_SECRET")
+}
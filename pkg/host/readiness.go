@@ -0,0 +1,89 @@
+package host
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/knlambert/docker-remote.git/pkg/backoff"
+	"github.com/pkg/errors"
+)
+
+//A step of the host readiness, probed until it returns no error.
+type ReadinessStage struct {
+	Name string
+	//Given a context cancelled at the deadline of the wait, or when the wait is interrupted.
+	Probe func(ctx context.Context) error
+}
+
+func createReadinessWaiter(timeout time.Duration) *readinessWaiter {
+	return &readinessWaiter{
+		timeout: timeout,
+		backoff: backoff.CreateBackoff(2*time.Second, 30*time.Second),
+		now:     time.Now,
+		sleep:   sleepContext,
+	}
+}
+
+type readinessWaiter struct {
+	timeout time.Duration
+	backoff *backoff.Backoff
+	now     func() time.Time
+	sleep   func(ctx context.Context, d time.Duration) error
+}
+
+//Probes each stage in order, backing off between attempts, until all of them pass or the timeout expires.
+//The last attempt is made at the deadline.
+func (w *readinessWaiter) Wait(ctx context.Context, stages []ReadinessStage) error {
+	deadline := w.now().Add(w.timeout)
+	probeCtx, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+
+	for i, stage := range stages {
+		log.Printf("[%d/%d] Waiting for %s ...", i+1, len(stages), stage.Name)
+
+		startedAt := w.now()
+		w.backoff.Reset()
+
+		for {
+			err := stage.Probe(probeCtx)
+
+			if err == nil {
+				break
+			}
+
+			remaining := deadline.Sub(w.now())
+
+			if remaining <= 0 {
+				return errors.Wrapf(err, "timed out after %s waiting for %s", w.timeout, stage.Name)
+			}
+
+			delay := w.backoff.Next()
+
+			if delay > remaining {
+				delay = remaining
+			}
+
+			if err := w.sleep(ctx, delay); err != nil {
+				return err
+			}
+		}
+
+		log.Printf("[%d/%d] %s ok (%s)", i+1, len(stages), stage.Name, w.now().Sub(startedAt).Round(time.Second))
+	}
+
+	return nil
+}
+
+//Sleeps for a duration, returns early with an error if the context is cancelled.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,92 @@
+package host
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/knlambert/docker-remote.git/pkg/backoff"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func stubbedReadinessWaiter(timeout time.Duration) (*readinessWaiter, *[]time.Duration) {
+	clock := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	var sleeps []time.Duration
+
+	return &readinessWaiter{
+		timeout: timeout,
+		backoff: backoff.CreateBackoff(time.Second, 4*time.Second),
+		now: func() time.Time {
+			return clock
+		},
+		sleep: func(ctx context.Context, d time.Duration) error {
+			sleeps = append(sleeps, d)
+			clock = clock.Add(d)
+			return ctx.Err()
+		},
+	}, &sleeps
+}
+
+func failingProbe(failures int) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if failures > 0 {
+			failures--
+			return errors.New("not ready")
+		}
+		return nil
+	}
+}
+
+func TestReadinessWaitBacksOffPerStage(t *testing.T) {
+	// Tear up.
+	w, sleeps := stubbedReadinessWaiter(time.Minute)
+
+	//Assertions
+	err := w.Wait(context.Background(), []ReadinessStage{
+		{Name: "first", Probe: failingProbe(4)},
+		{Name: "second", Probe: failingProbe(1)},
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []time.Duration{
+		time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second, time.Second,
+	}, *sleeps)
+}
+
+func TestReadinessWaitTimesOut(t *testing.T) {
+	// Tear up.
+	w, sleeps := stubbedReadinessWaiter(5 * time.Second)
+	secondProbed := false
+
+	//Assertions
+	err := w.Wait(context.Background(), []ReadinessStage{
+		{Name: "ssh", Probe: failingProbe(10)},
+		{Name: "docker", Probe: func(ctx context.Context) error {
+			secondProbed = true
+			return nil
+		}},
+	})
+
+	assert.EqualError(t, err, "timed out after 5s waiting for ssh: not ready")
+	assert.Equal(
+		t, []time.Duration{time.Second, 2 * time.Second, 2 * time.Second}, *sleeps,
+		"the last delay should be cut to probe at the deadline",
+	)
+	assert.False(t, secondProbed, "stages after a timed out one should not be probed")
+}
+
+func TestReadinessWaitStopsWhenCancelled(t *testing.T) {
+	// Tear up.
+	w, sleeps := stubbedReadinessWaiter(time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	//Assertions
+	err := w.Wait(ctx, []ReadinessStage{
+		{Name: "ssh", Probe: failingProbe(10)},
+	})
+
+	assert.Equal(t, context.Canceled, err)
+	assert.Len(t, *sleeps, 1)
+}
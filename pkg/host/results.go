@@ -0,0 +1,347 @@
+package host
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+//A docker host as listed by the list and status commands.
+type HostSummary struct {
+	ID           string      `json:"id" yaml:"id"`
+	Owner        string      `json:"owner" yaml:"owner"`
+	State        string      `json:"state" yaml:"state"`
+	InstanceType string      `json:"instance_type" yaml:"instance_type"`
+	Address      string      `json:"address" yaml:"address"`
+	LaunchTime   *time.Time  `json:"launch_time,omitempty" yaml:"launch_time,omitempty"`
+	ExpiresAt    *time.Time  `json:"expires_at,omitempty" yaml:"expires_at,omitempty"`
+	Idle         *IdleStatus `json:"idle,omitempty" yaml:"idle,omitempty"`
+	Cost         *HostCost   `json:"cost,omitempty" yaml:"cost,omitempty"`
+}
+
+//What a docker host costs in USD, prices are nil when unknown.
+type HostCost struct {
+	//"on-demand" or "spot".
+	Lifecycle string `json:"lifecycle" yaml:"lifecycle"`
+	//The price of an hour of the instance, 0 when it is not running.
+	HourlyRate *float64 `json:"hourly_rate" yaml:"hourly_rate"`
+	//The size of the attached volumes, in GB.
+	StorageSize int64 `json:"storage_size" yaml:"storage_size"`
+	//The price of an hour of the attached volumes.
+	StorageHourlyRate *float64 `json:"storage_hourly_rate" yaml:"storage_hourly_rate"`
+	//What the running instance cost since it was last started, nil when it is stopped. EC2 resets
+	//the launch time at each start, the earlier sessions are not counted.
+	SessionCost *float64 `json:"session_cost" yaml:"session_cost"`
+}
+
+//The countdown of the on-host idle watchdog.
+type IdleStatus struct {
+	//Minutes of inactivity before the host shuts down.
+	Timeout int `json:"timeout" yaml:"timeout"`
+	//Seconds since the last activity.
+	IdleFor int64 `json:"idle_for" yaml:"idle_for"`
+	//Seconds before the host shuts down if it stays idle.
+	ShutdownIn int64 `json:"shutdown_in" yaml:"shutdown_in"`
+}
+
+type UpResult struct {
+	ID            string `json:"id" yaml:"id"`
+	Address       string `json:"address" yaml:"address"`
+	DockerContext string `json:"docker_context" yaml:"docker_context"`
+	Created       bool   `json:"created" yaml:"created"`
+	//The instance type and subnet the host was created with, when there was a choice.
+	InstanceType string `json:"instance_type,omitempty" yaml:"instance_type,omitempty"`
+	SubnetId     string `json:"subnet_id,omitempty" yaml:"subnet_id,omitempty"`
+	//The architecture of the host, amd64 or arm64.
+	Architecture string `json:"architecture,omitempty" yaml:"architecture,omitempty"`
+}
+
+func (r *UpResult) Text() string {
+	text := fmt.Sprintf("Host %s ready at %s, docker context %s set", r.ID, r.Address, r.DockerContext)
+
+	if r.InstanceType != "" && r.SubnetId != "" {
+		text += fmt.Sprintf(" (%s in %s)", r.InstanceType, r.SubnetId)
+	} else if r.InstanceType != "" {
+		text += fmt.Sprintf(" (%s)", r.InstanceType)
+	}
+
+	return text
+}
+
+type AttachResult struct {
+	ID            string `json:"id" yaml:"id"`
+	Address       string `json:"address" yaml:"address"`
+	DockerContext string `json:"docker_context" yaml:"docker_context"`
+	Adopted       bool   `json:"adopted" yaml:"adopted"`
+}
+
+func (r *AttachResult) Text() string {
+	action := "attached"
+
+	if r.Adopted {
+		action = "adopted"
+	}
+
+	return fmt.Sprintf("Host %s (%s) %s, docker context %s set", r.ID, r.Address, action, r.DockerContext)
+}
+
+type EcrLoginResult struct {
+	ID string `json:"id" yaml:"id"`
+	//The registries logged in with a token, or handed to the credential helper.
+	Registries       []string `json:"registries" yaml:"registries"`
+	CredentialHelper bool     `json:"credential_helper" yaml:"credential_helper"`
+}
+
+func (r *EcrLoginResult) Text() string {
+	if r.CredentialHelper {
+		return fmt.Sprintf("ECR credential helper configured on %s for %s", r.ID, strings.Join(r.Registries, ", "))
+	}
+	return fmt.Sprintf("Docker on %s logged in %s", r.ID, strings.Join(r.Registries, ", "))
+}
+
+type RegistrySyncResult struct {
+	ID         string   `json:"id" yaml:"id"`
+	Registries []string `json:"registries" yaml:"registries"`
+}
+
+func (r *RegistrySyncResult) Text() string {
+	return fmt.Sprintf("Credentials of %s copied to %s", strings.Join(r.Registries, ", "), r.ID)
+}
+
+type DownResult struct {
+	ID         string `json:"id,omitempty" yaml:"id,omitempty"`
+	Terminated bool   `json:"terminated" yaml:"terminated"`
+	//The Elastic or floating IP released along with the host.
+	ReleasedIP string `json:"released_ip,omitempty" yaml:"released_ip,omitempty"`
+	//Set when the host was only forgotten, not shut down.
+	Deregistered bool `json:"deregistered,omitempty" yaml:"deregistered,omitempty"`
+}
+
+func (r *DownResult) Text() string {
+	text := "Docker host is already down"
+
+	if r.Terminated {
+		text = fmt.Sprintf("Shutdown signal sent to %s", r.ID)
+	} else if r.Deregistered {
+		text = fmt.Sprintf("Docker host %s deregistered", r.ID)
+	}
+
+	if r.ReleasedIP != "" {
+		text += fmt.Sprintf("\nAddress %s released", r.ReleasedIP)
+	}
+
+	return text
+}
+
+type ListResult struct {
+	Hosts []HostSummary `json:"hosts" yaml:"hosts"`
+}
+
+func (r *ListResult) Text() string {
+	var buffer bytes.Buffer
+	w := tabwriter.NewWriter(&buffer, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(w, "ID\tOWNER\tSTATE\tTYPE\tADDRESS\tUPTIME\tHOURLY\tSESSION")
+
+	for _, h := range r.Hosts {
+		hourly, session := "-", "-"
+
+		if h.Cost != nil {
+			hourly, session = price(h.Cost.totalHourlyRate()), sessionPrice(h.State, h.Cost)
+		}
+
+		fmt.Fprintf(
+			w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			h.ID, h.Owner, h.State, h.InstanceType, h.Address, uptime(h.LaunchTime), hourly, session,
+		)
+	}
+
+	w.Flush()
+
+	return string(bytes.TrimRight(buffer.Bytes(), "\n"))
+}
+
+type StatusResult struct {
+	Host *HostSummary `json:"host" yaml:"host"`
+}
+
+func (r *StatusResult) Text() string {
+	if r.Host == nil {
+		return "No docker host, please create it first"
+	}
+
+	var buffer bytes.Buffer
+	w := tabwriter.NewWriter(&buffer, 0, 4, 1, ' ', 0)
+
+	fmt.Fprintf(w, "ID:\t%s\n", r.Host.ID)
+	fmt.Fprintf(w, "Owner:\t%s\n", r.Host.Owner)
+	fmt.Fprintf(w, "State:\t%s\n", r.Host.State)
+	fmt.Fprintf(w, "Type:\t%s\n", r.Host.InstanceType)
+	fmt.Fprintf(w, "Address:\t%s\n", r.Host.Address)
+	fmt.Fprintf(w, "Uptime:\t%s\n", uptime(r.Host.LaunchTime))
+
+	if r.Host.ExpiresAt != nil {
+		fmt.Fprintf(w, "Expires:\t%s\n", r.Host.ExpiresAt.Local().Format(time.RFC1123))
+	}
+
+	if r.Host.Idle != nil {
+		fmt.Fprintf(
+			w, "Idle:\t%s, shuts down in %s\n",
+			(time.Duration(r.Host.Idle.IdleFor) * time.Second).Round(time.Minute),
+			time.Duration(r.Host.Idle.ShutdownIn)*time.Second,
+		)
+	}
+
+	if r.Host.Cost != nil {
+		fmt.Fprintf(w, "Hourly rate:\t%s (%s)\n", price(r.Host.Cost.HourlyRate), r.Host.Cost.Lifecycle)
+		fmt.Fprintf(w, "Storage:\t%d GB, %s\n", r.Host.Cost.StorageSize, price(r.Host.Cost.StorageHourlyRate))
+		fmt.Fprintf(w, "Current session:\t%s\n", sessionPrice(r.Host.State, r.Host.Cost))
+	}
+
+	w.Flush()
+
+	return string(bytes.TrimRight(buffer.Bytes(), "\n"))
+}
+
+//Spend of the managed hosts, per owner.
+type CostResult struct {
+	Owners []OwnerCost `json:"owners" yaml:"owners"`
+	//Hosts without a known price, not counted in the totals.
+	UnpricedHosts []string `json:"unpriced_hosts" yaml:"unpriced_hosts"`
+}
+
+type OwnerCost struct {
+	Owner      string  `json:"owner" yaml:"owner"`
+	Hosts      int     `json:"hosts" yaml:"hosts"`
+	HourlyRate float64 `json:"hourly_rate" yaml:"hourly_rate"`
+	//What the running hosts cost since they were last started.
+	SessionCost float64 `json:"session_cost" yaml:"session_cost"`
+}
+
+func (r *CostResult) Text() string {
+	var buffer bytes.Buffer
+	w := tabwriter.NewWriter(&buffer, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(w, "OWNER\tHOSTS\tHOURLY\tSESSIONS")
+
+	for _, o := range r.Owners {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", o.Owner, o.Hosts, price(&o.HourlyRate), price(&o.SessionCost))
+	}
+
+	w.Flush()
+
+	if len(r.UnpricedHosts) > 0 {
+		fmt.Fprintf(&buffer, "No known price for: %s\n", strings.Join(r.UnpricedHosts, ", "))
+	}
+
+	return string(bytes.TrimRight(buffer.Bytes(), "\n"))
+}
+
+type ReapResult struct {
+	DryRun bool         `json:"dry_run" yaml:"dry_run"`
+	Hosts  []ReapedHost `json:"hosts" yaml:"hosts"`
+}
+
+//An expired host, terminated unless in dry-run.
+type ReapedHost struct {
+	ID         string    `json:"id" yaml:"id"`
+	Owner      string    `json:"owner" yaml:"owner"`
+	ExpiresAt  time.Time `json:"expires_at" yaml:"expires_at"`
+	Terminated bool      `json:"terminated" yaml:"terminated"`
+}
+
+func (r *ReapResult) Text() string {
+	if len(r.Hosts) == 0 {
+		return "No expired host"
+	}
+
+	var buffer bytes.Buffer
+	w := tabwriter.NewWriter(&buffer, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(w, "ID\tOWNER\tEXPIRED\tTERMINATED")
+
+	for _, h := range r.Hosts {
+		terminated := fmt.Sprintf("%t", h.Terminated)
+
+		if r.DryRun {
+			terminated = "dry-run"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", h.ID, h.Owner, h.ExpiresAt.Local().Format(time.RFC1123), terminated)
+	}
+
+	w.Flush()
+
+	return string(bytes.TrimRight(buffer.Bytes(), "\n"))
+}
+
+type ExtendResult struct {
+	ID        string    `json:"id" yaml:"id"`
+	ExpiresAt time.Time `json:"expires_at" yaml:"expires_at"`
+}
+
+func (r *ExtendResult) Text() string {
+	return fmt.Sprintf("Host %s now expires %s", r.ID, r.ExpiresAt.Local().Format(time.RFC1123))
+}
+
+type PortForwardEventType string
+
+const (
+	PortForwardListening  PortForwardEventType = "listening"
+	PortForwardConnection PortForwardEventType = "connection"
+	PortForwardError      PortForwardEventType = "error"
+)
+
+//Emitted while port-forwarding, one per new state or connection.
+type PortForwardEvent struct {
+	Event      PortForwardEventType `json:"event" yaml:"event"`
+	LocalPort  uint                 `json:"local_port" yaml:"local_port"`
+	RemoteAddr string               `json:"remote_addr" yaml:"remote_addr"`
+	RemotePort uint                 `json:"remote_port" yaml:"remote_port"`
+	Client     string               `json:"client,omitempty" yaml:"client,omitempty"`
+	Error      string               `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+func (r *PortForwardEvent) Text() string {
+	switch r.Event {
+	case PortForwardListening:
+		return fmt.Sprintf("Port-forwarding %d -> %s:%d", r.LocalPort, r.RemoteAddr, r.RemotePort)
+	case PortForwardConnection:
+		return fmt.Sprintf("Forwarding connection from %s", r.Client)
+	}
+	return r.Error
+}
+
+//The price of an hour of the host, instance and storage included.
+func (c *HostCost) totalHourlyRate() *float64 {
+	if c.HourlyRate == nil || c.StorageHourlyRate == nil {
+		return nil
+	}
+
+	total := *c.HourlyRate + *c.StorageHourlyRate
+	return &total
+}
+
+//The cost of the current session, "-" when the host is not running.
+func sessionPrice(state string, cost *HostCost) string {
+	if state != "running" && state != "pending" {
+		return "-"
+	}
+	return price(cost.SessionCost)
+}
+
+func price(value *float64) string {
+	if value == nil {
+		return "unknown"
+	}
+	return fmt.Sprintf("$%.4f", *value)
+}
+
+func uptime(launchTime *time.Time) string {
+	if launchTime == nil {
+		return "-"
+	}
+	return time.Since(*launchTime).Round(time.Minute).String()
+}
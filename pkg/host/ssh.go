@@ -0,0 +1,315 @@
+package host
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/knlambert/docker-remote.git/pkg/output"
+	"github.com/knlambert/docker-remote.git/pkg/provision"
+	"github.com/knlambert/docker-remote.git/pkg/sshutil"
+	"github.com/knlambert/docker-remote.git/pkg/state"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	//The docker context (and SSH alias) of the host.
+	sshDockerContextName = "docker-remote-ssh"
+	//The state keys of the registered host.
+	sshTargetStateKey = "target"
+	sshJumpsStateKey  = "jumps"
+)
+
+//A machine we already own, reached over SSH: up installs docker on it, down only forgets it.
+func CreateSSHHost() DockerHostSystem {
+	return &sshHostImpl{
+		helpers: CreatePluginHelpers(),
+		state:   state.CreateStore(),
+	}
+}
+
+type sshHostImpl struct {
+	helpers PluginHelpers
+	state   state.Store
+}
+
+type SSHUpParams struct {
+	//The machine to use, "user@host[:port]".
+	Host        string
+	KeyPairPath string
+	Jumps       []string
+	Timeout     time.Duration
+	Rollback    bool
+}
+
+func (s *sshHostImpl) CobraCommand(
+	command Command,
+) *cobra.Command {
+	switch command {
+	case List:
+		return &cobra.Command{
+			Use:   string(command),
+			Short: "List the registered docker host",
+			Run: func(cmd *cobra.Command, args []string) {
+				runAndPrint(cmd, func() (output.Result, error) {
+					return s.List()
+				})
+			},
+		}
+	case Up:
+		upParams := SSHUpParams{}
+		upCmd := cobra.Command{
+			Use:   fmt.Sprintf("%s <user@host[:port]>", command),
+			Short: "Installs docker on a machine if needed and uses it as the docker host",
+			Args:  cobra.ExactArgs(1),
+			Run: func(cmd *cobra.Command, args []string) {
+				upParams.Host = args[0]
+
+				ctx, cancel := interruptibleContext()
+				defer cancel()
+
+				runAndPrint(cmd, func() (output.Result, error) {
+					return s.Up(ctx, &upParams)
+				})
+			},
+		}
+
+		upCmd.Flags().StringSliceVarP(
+			&upParams.Jumps, "jump", "", []string{},
+			"A jump host to reach the machine through, 'user@host[:port]' (can be repeated or comma separated)",
+		)
+
+		addUpFlags(upCmd.Flags(), &upParams.KeyPairPath, &upParams.Timeout, &upParams.Rollback)
+
+		return &upCmd
+	}
+
+	return commonCommand(command, s, "Forget the docker host, leaving the machine untouched")
+}
+
+func (s *sshHostImpl) Cost() (*CostResult, error) {
+	return nil, errors.New("cost is not supported by the ssh driver")
+}
+
+func (s *sshHostImpl) Extend(params interface{}) (*ExtendResult, error) {
+	return nil, errors.New("extend is not supported by the ssh driver")
+}
+
+func (s *sshHostImpl) Reap(params interface{}) (*ReapResult, error) {
+	return nil, errors.New("reap is not supported by the ssh driver")
+}
+
+//Forgets the docker host: its docker context, SSH alias and agent key. The machine is untouched.
+func (s *sshHostImpl) Down(params interface{}) (*DownResult, error) {
+	target, err := s.target()
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := DownResult{}
+
+	if target == nil {
+		return &result, nil
+	}
+
+	if err := s.helpers.UnregisterFromDocker(sshDockerContextName); err != nil {
+		return nil, err
+	}
+
+	if err := s.helpers.SSHUtils().SSHConfigRemove(sshDockerContextName); err != nil {
+		return nil, err
+	}
+
+	if err := s.helpers.SSHUtils().SSHAgentRemoveKey(agentKeyComment(sshDockerContextName)); err != nil {
+		return nil, err
+	}
+
+	for _, key := range []string{sshTargetStateKey, sshJumpsStateKey} {
+		if err := s.state.Set(string(SSH), key, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	result.ID = target.String()
+	result.Deregistered = true
+
+	return &result, nil
+}
+
+func (s *sshHostImpl) List() (*ListResult, error) {
+	result := ListResult{Hosts: []HostSummary{}}
+
+	status, err := s.Status()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if status.Host != nil {
+		result.Hosts = append(result.Hosts, *status.Host)
+	}
+
+	return &result, nil
+}
+
+func (s *sshHostImpl) PortForward(params interface{}) error {
+	return forwardTo(s.helpers, params, s.registeredTarget)
+}
+
+func (s *sshHostImpl) Shell(params interface{}) error {
+	return shellTo(s.helpers, s.registeredTarget)
+}
+
+//Describes the registered host, reachable when SSH works.
+func (s *sshHostImpl) Status() (*StatusResult, error) {
+	target, err := s.target()
+
+	if err != nil || target == nil {
+		return &StatusResult{}, err
+	}
+
+	summary := HostSummary{
+		ID:      target.String(),
+		Owner:   target.User,
+		State:   "reachable",
+		Address: target.Host,
+	}
+
+	if _, err := s.helpers.SSHUtils().SSHRun(target, "true"); err != nil {
+		log.Printf("Failed to reach %s: %s", target.String(), err)
+		summary.State = "unreachable"
+	}
+
+	return &StatusResult{Host: &summary}, nil
+}
+
+//Installs docker on the machine if needed, then registers it as the docker host.
+func (s *sshHostImpl) Up(ctx context.Context, params interface{}) (*UpResult, error) {
+	upParams := params.(*SSHUpParams)
+
+	endpoint, err := sshutil.ParseEndpoint(upParams.Host)
+
+	if err != nil {
+		return nil, err
+	}
+
+	jumps, err := sshutil.ParseJumps(upParams.Jumps)
+
+	if err != nil {
+		return nil, err
+	}
+
+	target := &sshutil.Target{Endpoint: *endpoint, Jumps: jumps}
+	sshUtils := s.helpers.SSHUtils()
+
+	completed, err := RunSteps(ctx, []Step{agentKeyStep(s.helpers, sshDockerContextName, upParams.KeyPairPath), {
+		Name: "docker installation",
+		Run: func(ctx context.Context) error {
+			return installDocker(sshUtils, target)
+		},
+	}, {
+		Name: "readiness",
+		Run: func(ctx context.Context) error {
+			return createReadinessWaiter(upParams.Timeout).Wait(ctx, []ReadinessStage{{
+				Name: "docker daemon",
+				Probe: func(ctx context.Context) error {
+					return sshUtils.DockerPing(target)
+				},
+			}})
+		},
+	}, dockerContextStep(s.helpers, sshDockerContextName, func() (*sshutil.Target, error) {
+		return target, nil
+	}, nil), {
+		Name: "host record",
+		Run: func(ctx context.Context) error {
+			if err := s.state.Set(string(SSH), sshTargetStateKey, target.String()); err != nil {
+				return err
+			}
+
+			return s.state.Set(string(SSH), sshJumpsStateKey, target.ProxyJump())
+		},
+	}})
+
+	if err != nil {
+		HandleStepsFailure(completed, upParams.Rollback)
+		return nil, err
+	}
+
+	return &UpResult{
+		ID:            target.String(),
+		Address:       target.Host,
+		DockerContext: sshDockerContextName,
+	}, nil
+}
+
+//Installs docker on a machine if it's missing, for the distribution it runs.
+func installDocker(sshUtils sshutil.SSHUtils, target *sshutil.Target) error {
+	osRelease, err := sshUtils.SSHRun(target, "cat /etc/os-release")
+
+	if err != nil {
+		return errors.Wrapf(err, "failed to detect the distribution of %s", target.String())
+	}
+
+	distribution := provision.ParseOSRelease(osRelease)
+	script, err := provision.DockerInstallScript(distribution, target.User)
+
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Installing docker on %s if needed", distribution.Name)
+
+	if out, err := sshUtils.SSHRun(target, script); err != nil {
+		return errors.Wrapf(err, "failed to install docker: %s", strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+//Returns the registered host, nil if there is none.
+func (s *sshHostImpl) target() (*sshutil.Target, error) {
+	value, err := s.state.Get(string(SSH), sshTargetStateKey)
+
+	if err != nil || value == "" {
+		return nil, err
+	}
+
+	endpoint, err := sshutil.ParseEndpoint(value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	jumps, err := s.state.Get(string(SSH), sshJumpsStateKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	target := sshutil.Target{Endpoint: *endpoint}
+
+	if target.Jumps, err = sshutil.ParseJumps([]string{jumps}); err != nil {
+		return nil, err
+	}
+
+	return &target, nil
+}
+
+//Returns the registered host, fails if there is none.
+func (s *sshHostImpl) registeredTarget() (*sshutil.Target, error) {
+	target, err := s.target()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if target == nil {
+		return nil, errors.Errorf("Please register the host first")
+	}
+
+	return target, nil
+}
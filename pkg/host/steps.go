@@ -0,0 +1,160 @@
+package host
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+//An operation of a command which can be undone if it or a later one fails.
+type Step struct {
+	Name string
+	Run  func(ctx context.Context) error
+	//Undoes Run, nil when there is nothing to undo. As a failed Run may have created something
+	//before failing, it undoes what was actually created.
+	Rollback func() error
+	//Describes the resource created by Run, empty when none was.
+	Resource func() string
+}
+
+//Runs steps in order until one fails or the context is cancelled.
+//Returns the steps which ran, the failed one included, to be able to roll them back.
+func RunSteps(ctx context.Context, steps []Step) ([]Step, error) {
+	var completed []Step
+
+	for _, step := range steps {
+		if err := ctx.Err(); err != nil {
+			return completed, err
+		}
+
+		completed = append(completed, step)
+
+		if err := step.Run(ctx); err != nil {
+			if ctx.Err() != nil {
+				return completed, ctx.Err()
+			}
+			return completed, err
+		}
+	}
+
+	return completed, nil
+}
+
+//Returns the resources created by completed steps.
+func StepsResources(completed []Step) []string {
+	var resources []string
+
+	for _, step := range completed {
+		if step.Resource == nil {
+			continue
+		}
+
+		if resource := step.Resource(); resource != "" {
+			resources = append(resources, resource)
+		}
+	}
+
+	return resources
+}
+
+//Undoes completed steps in reverse order, returns the resources which could not be removed.
+func RollbackSteps(completed []Step) []string {
+	var remaining []string
+
+	for i := len(completed) - 1; i >= 0; i-- {
+		step := completed[i]
+
+		if step.Rollback == nil {
+			continue
+		}
+
+		log.Printf("Rolling back %s ...", step.Name)
+
+		if err := step.Rollback(); err != nil {
+			log.Printf("Failed to roll back %s: %s", step.Name, err)
+
+			if step.Resource != nil && step.Resource() != "" {
+				remaining = append(remaining, step.Resource())
+			}
+		}
+	}
+
+	return remaining
+}
+
+//Handles a failed sequence of steps: rolls back automatically, or after asking the user,
+//then reports what is left behind.
+func HandleStepsFailure(completed []Step, rollback bool) {
+	remaining := StepsResources(completed)
+
+	if len(remaining) == 0 {
+		return
+	}
+
+	if !rollback && isInteractive() {
+		rollback = confirm(fmt.Sprintf(
+			"Remove the resources created so far (%s)?", strings.Join(remaining, ", "),
+		))
+	}
+
+	if rollback {
+		remaining = RollbackSteps(completed)
+	}
+
+	if len(remaining) == 0 {
+		log.Println("No resource remains")
+		return
+	}
+
+	log.Println("Resources remaining:")
+
+	for _, resource := range remaining {
+		log.Printf("  - %s", resource)
+	}
+}
+
+//Returns a context cancelled on the first interrupt. Following interrupts are not caught anymore,
+//so that a second Ctrl-C kills the process.
+func interruptibleContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case <-signals:
+			log.Println("Interrupted, stopping ...")
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(signals)
+	}()
+
+	return ctx, cancel
+}
+
+func isInteractive() bool {
+	return terminal.IsTerminal(int(os.Stdin.Fd()))
+}
+
+//Asks a yes/no question on the terminal, defaults to no.
+func confirm(question string) bool {
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+
+	if err != nil {
+		return false
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,88 @@
+package host
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func recordedStep(name string, runErr error, rollbackErr error, calls *[]string) Step {
+	return Step{
+		Name: name,
+		Run: func(ctx context.Context) error {
+			*calls = append(*calls, "run "+name)
+			return runErr
+		},
+		Rollback: func() error {
+			*calls = append(*calls, "rollback "+name)
+			return rollbackErr
+		},
+		Resource: func() string {
+			return name
+		},
+	}
+}
+
+func TestRunStepsStopsAtFirstFailure(t *testing.T) {
+	// Tear up.
+	var calls []string
+
+	//Assertions
+	completed, err := RunSteps(context.Background(), []Step{
+		recordedStep("instance", nil, nil, &calls),
+		recordedStep("key", nil, nil, &calls),
+		recordedStep("context", errors.New("boom"), nil, &calls),
+		recordedStep("never", nil, nil, &calls),
+	})
+
+	assert.EqualError(t, err, "boom")
+	assert.Equal(t, []string{"instance", "key", "context"}, StepsResources(completed))
+	assert.Equal(t, []string{"run instance", "run key", "run context"}, calls)
+
+	calls = nil
+
+	assert.Empty(t, RollbackSteps(completed))
+	assert.Equal(
+		t, []string{"rollback context", "rollback key", "rollback instance"}, calls,
+		"the failed step should be rolled back too",
+	)
+}
+
+func TestRunStepsHonorsCancellation(t *testing.T) {
+	// Tear up.
+	var calls []string
+	ctx, cancel := context.WithCancel(context.Background())
+
+	cancelling := recordedStep("instance", nil, nil, &calls)
+	cancelling.Run = func(ctx context.Context) error {
+		cancel()
+		return nil
+	}
+
+	//Assertions
+	completed, err := RunSteps(ctx, []Step{
+		cancelling,
+		recordedStep("key", nil, nil, &calls),
+	})
+
+	assert.Equal(t, context.Canceled, err)
+	assert.Len(t, completed, 1)
+	assert.Empty(t, calls)
+}
+
+func TestRollbackStepsReportsRemainingResources(t *testing.T) {
+	// Tear up.
+	var calls []string
+	completed := []Step{
+		recordedStep("instance", nil, errors.New("denied"), &calls),
+		recordedStep("key", nil, nil, &calls),
+	}
+
+	//Assertions
+	remaining := RollbackSteps(completed)
+
+	assert.Equal(t, []string{"instance"}, remaining)
+	assert.Equal(t, []string{"rollback key", "rollback instance"}, calls)
+}
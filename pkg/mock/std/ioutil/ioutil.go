@@ -33,6 +33,21 @@ func (m *MockIOUtil) EXPECT() *MockIOUtilMockRecorder {
 	return m.recorder
 }
 
+// ReadFile mocks base method
+func (m *MockIOUtil) ReadFile(filename string) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadFile", filename)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReadFile indicates an expected call of ReadFile
+func (mr *MockIOUtilMockRecorder) ReadFile(filename interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadFile", reflect.TypeOf((*MockIOUtil)(nil).ReadFile), filename)
+}
+
 // WriteFile mocks base method
 func (m *MockIOUtil) WriteFile(filename string, data []byte, perm os.FileMode) error {
 	m.ctrl.T.Helper()
@@ -32,6 +32,20 @@ func (m *MockRuntime) EXPECT() *MockRuntimeMockRecorder {
 	return m.recorder
 }
 
+// CurrentArch mocks base method
+func (m *MockRuntime) CurrentArch() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CurrentArch")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// CurrentArch indicates an expected call of CurrentArch
+func (mr *MockRuntimeMockRecorder) CurrentArch() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CurrentArch", reflect.TypeOf((*MockRuntime)(nil).CurrentArch))
+}
+
 // CurrentOS mocks base method
 func (m *MockRuntime) CurrentOS() string {
 	m.ctrl.T.Helper()
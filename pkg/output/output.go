@@ -0,0 +1,86 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+type Format string
+
+const (
+	Text Format = "text"
+	JSON Format = "json"
+	YAML Format = "yaml"
+)
+
+//The name of the global flag selecting the output format.
+const FlagName = "output"
+
+//A command result, which can be rendered for humans.
+type Result interface {
+	Text() string
+}
+
+//Prints command results in the chosen format.
+//JSON results are printed one per line, YAML ones as separate documents.
+type Printer interface {
+	Print(result Result) error
+}
+
+func CreatePrinter(format Format, writer io.Writer) (Printer, error) {
+	switch format {
+	case Text, JSON, YAML:
+		return &printerImpl{
+			format: format,
+			writer: writer,
+		}, nil
+	}
+
+	return nil, errors.Errorf("unknown output format '%s', expected one of text, json, yaml", format)
+}
+
+//Creates a printer writing to stdout in the format given by the global flag of a command.
+func FromCommand(cmd *cobra.Command) (Printer, error) {
+	format, err := cmd.Flags().GetString(FlagName)
+
+	if err != nil {
+		format = string(Text)
+	}
+
+	return CreatePrinter(Format(format), os.Stdout)
+}
+
+type printerImpl struct {
+	format Format
+	writer io.Writer
+}
+
+func (p *printerImpl) Print(result Result) error {
+	var serialized []byte
+	var err error
+
+	switch p.format {
+	case JSON:
+		serialized, err = json.Marshal(result)
+		serialized = append(serialized, '\n')
+	case YAML:
+		serialized, err = yaml.Marshal(result)
+		serialized = append([]byte("---\n"), serialized...)
+	default:
+		_, err = fmt.Fprintln(p.writer, result.Text())
+		return err
+	}
+
+	if err != nil {
+		return errors.Wrapf(err, "failed to serialize the result to %s", p.format)
+	}
+
+	_, err = p.writer.Write(serialized)
+	return err
+}
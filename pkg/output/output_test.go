@@ -0,0 +1,40 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeResult struct {
+	ID    string `json:"id" yaml:"id"`
+	Ready bool   `json:"ready" yaml:"ready"`
+}
+
+func (r *fakeResult) Text() string {
+	return "Host " + r.ID
+}
+
+func TestPrintFormats(t *testing.T) {
+	for format, expected := range map[Format]string{
+		Text: "Host i-1\n",
+		JSON: "{\"id\":\"i-1\",\"ready\":true}\n",
+		YAML: "---\nid: i-1\nready: true\n",
+	} {
+		// Tear up.
+		var buffer bytes.Buffer
+		printer, err := CreatePrinter(format, &buffer)
+
+		//Assertions
+		assert.Nil(t, err)
+		assert.Nil(t, printer.Print(&fakeResult{ID: "i-1", Ready: true}))
+		assert.Equal(t, expected, buffer.String(), "unexpected %s output", format)
+	}
+}
+
+func TestCreatePrinterRejectsUnknownFormat(t *testing.T) {
+	_, err := CreatePrinter("xml", &bytes.Buffer{})
+
+	assert.EqualError(t, err, "unknown output format 'xml', expected one of text, json, yaml")
+}
@@ -0,0 +1,112 @@
+package plugin
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//The prefix of the driver plugin executables, followed by the driver name.
+const Prefix = "docker-remote-driver-"
+
+//A driver plugin executable.
+type Plugin struct {
+	//The driver name, the executable name without the Prefix.
+	Name string
+	Path string
+}
+
+//The plugins found by the first discovery, the directories are scanned once per process.
+var (
+	discovery  sync.Once
+	discovered []Plugin
+)
+
+//Returns the driver plugins of ~/.docker-remote/plugins then of the PATH, by name. When
+//several executables have the same name, the first one found wins.
+func Discover() []Plugin {
+	discovery.Do(func() {
+		var dirs []string
+
+		if home, err := os.UserHomeDir(); err == nil {
+			dirs = append(dirs, filepath.Join(home, ".docker-remote", "plugins"))
+		}
+
+		dirs = append(dirs, filepath.SplitList(os.Getenv("PATH"))...)
+
+		discovered = discover(dirs)
+	})
+
+	return discovered
+}
+
+//Returns the plugin of a driver, nil if there is none.
+func Find(name string) *Plugin {
+	for _, plugin := range Discover() {
+		if plugin.Name == name {
+			return &plugin
+		}
+	}
+	return nil
+}
+
+func discover(dirs []string) []Plugin {
+	found := map[string]Plugin{}
+
+	for _, dir := range dirs {
+		files, err := ioutil.ReadDir(dir)
+
+		if err != nil {
+			//Missing or unreadable PATH entries are common.
+			continue
+		}
+
+		for _, file := range files {
+			name := pluginName(file)
+
+			if name == "" {
+				continue
+			}
+
+			if _, ok := found[name]; !ok {
+				found[name] = Plugin{Name: name, Path: filepath.Join(dir, file.Name())}
+			}
+		}
+	}
+
+	plugins := make([]Plugin, 0, len(found))
+
+	for _, plugin := range found {
+		plugins = append(plugins, plugin)
+	}
+
+	sort.Slice(plugins, func(i, j int) bool {
+		return plugins[i].Name < plugins[j].Name
+	})
+
+	return plugins
+}
+
+//Returns the driver name of a plugin executable, empty if the file is not one.
+func pluginName(file os.FileInfo) string {
+	if file.IsDir() || !strings.HasPrefix(file.Name(), Prefix) {
+		return ""
+	}
+
+	name := strings.TrimPrefix(file.Name(), Prefix)
+
+	if runtime.GOOS == "windows" {
+		if !strings.EqualFold(filepath.Ext(name), ".exe") {
+			return ""
+		}
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	} else if file.Mode()&0111 == 0 {
+		return ""
+	}
+
+	return name
+}
@@ -0,0 +1,140 @@
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiscover(t *testing.T) {
+	// Tear up.
+	first, _ := ioutil.TempDir("", "plugins")
+	second, _ := ioutil.TempDir("", "path")
+	defer os.RemoveAll(first)
+	defer os.RemoveAll(second)
+
+	_ = ioutil.WriteFile(filepath.Join(first, "docker-remote-driver-hetzner"), []byte("#!/bin/sh\n"), 0755)
+	_ = ioutil.WriteFile(filepath.Join(second, "docker-remote-driver-hetzner"), []byte("#!/bin/sh\n"), 0755)
+	_ = ioutil.WriteFile(filepath.Join(second, "docker-remote-driver-vultr"), []byte("#!/bin/sh\n"), 0755)
+	_ = ioutil.WriteFile(filepath.Join(second, "docker-remote-driver-notes"), []byte("not executable"), 0644)
+	_ = ioutil.WriteFile(filepath.Join(second, "docker-remote"), []byte("#!/bin/sh\n"), 0755)
+
+	//Assertions
+	assert.Equal(t, []Plugin{
+		{Name: "hetzner", Path: filepath.Join(first, "docker-remote-driver-hetzner")},
+		{Name: "vultr", Path: filepath.Join(second, "docker-remote-driver-vultr")},
+	}, discover([]string{first, "/does/not/exist", second}))
+}
+
+func TestDiscoverScansOnce(t *testing.T) {
+	// Tear up.
+	dir, _ := ioutil.TempDir("", "path")
+	defer os.RemoveAll(dir)
+
+	for key, value := range map[string]string{"HOME": dir, "PATH": dir} {
+		defer os.Setenv(key, os.Getenv(key))
+		os.Setenv(key, value)
+	}
+
+	_ = ioutil.WriteFile(filepath.Join(dir, "docker-remote-driver-hetzner"), []byte("#!/bin/sh\n"), 0755)
+
+	//Assertions
+	assert.Equal(t, &Plugin{Name: "hetzner", Path: filepath.Join(dir, "docker-remote-driver-hetzner")}, Find("hetzner"))
+
+	_ = ioutil.WriteFile(filepath.Join(dir, "docker-remote-driver-vultr"), []byte("#!/bin/sh\n"), 0755)
+
+	assert.Nil(t, Find("vultr"), "the plugins should not be scanned again")
+}
+
+//Answers the requests of a client like a plugin would, with the answer function.
+func servePlugin(in io.Reader, out io.Writer, answer func(req map[string]interface{}) string) {
+	scanner := bufio.NewScanner(in)
+
+	for scanner.Scan() {
+		var req map[string]interface{}
+		_ = json.Unmarshal(scanner.Bytes(), &req)
+		_, _ = io.WriteString(out, answer(req)+"\n")
+	}
+}
+
+func TestCall(t *testing.T) {
+	// Tear up.
+	clientIn, pluginIn := io.Pipe()
+	pluginOut, clientOut := io.Pipe()
+
+	var received map[string]interface{}
+
+	go servePlugin(clientIn, clientOut, func(req map[string]interface{}) string {
+		received = req
+		switch req["method"] {
+		case MethodDescribe:
+			return `{"jsonrpc":"2.0","id":1,"result":{"host":{"id":"vm-1","owner":"barney","state":"running"}}}`
+		default:
+			return `{"jsonrpc":"2.0","id":2,"error":{"code":-32601,"message":"method not found"}}`
+		}
+	})
+
+	client := createRPCClient(pluginIn, pluginOut)
+
+	//Assertions
+	var result DescribeResult
+
+	err := client.Call(context.Background(), MethodDescribe, &HostParams{Metadata: map[string]string{"owner": "barney"}}, &result)
+
+	assert.Nil(t, err)
+	assert.Equal(t, &Host{ID: "vm-1", Owner: "barney", State: "running"}, result.Host)
+	assert.Equal(t, "2.0", received["jsonrpc"])
+	assert.Equal(t, map[string]interface{}{"metadata": map[string]interface{}{"owner": "barney"}}, received["params"])
+
+	err = client.Call(context.Background(), MethodList, &ListParams{}, nil)
+
+	assert.EqualError(t, err, "plugin call list failed: plugin error -32601: method not found")
+	assert.Nil(t, client.Close())
+}
+
+func TestCallLargeResult(t *testing.T) {
+	// Tear up.
+	clientIn, pluginIn := io.Pipe()
+	pluginOut, clientOut := io.Pipe()
+
+	owner := strings.Repeat("b", 256*1024)
+
+	go servePlugin(clientIn, clientOut, func(req map[string]interface{}) string {
+		return `{"jsonrpc":"2.0","id":1,"result":{"host":{"id":"vm-1","owner":"` + owner + `"}}}`
+	})
+
+	client := createRPCClient(pluginIn, pluginOut)
+
+	//Assertions
+	var result DescribeResult
+
+	assert.Nil(t, client.Call(context.Background(), MethodDescribe, &HostParams{}, &result))
+	assert.Equal(t, owner, result.Host.Owner)
+	assert.Nil(t, client.Close())
+}
+
+func TestCallCancelled(t *testing.T) {
+	// Tear up.
+	clientIn, pluginIn := io.Pipe()
+	pluginOut, _ := io.Pipe()
+
+	go func() {
+		_, _ = ioutil.ReadAll(clientIn)
+	}()
+
+	client := createRPCClient(pluginIn, pluginOut)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	//Assertions
+	assert.Equal(t, context.Canceled, client.Call(ctx, MethodUp, &UpParams{}, nil))
+}
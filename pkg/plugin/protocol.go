@@ -0,0 +1,78 @@
+package plugin
+
+import (
+	"time"
+)
+
+//The version of the protocol, given to the plugins in the DOCKER_REMOTE_PLUGIN_PROTOCOL variable.
+const ProtocolVersion = "1"
+
+//The methods a plugin implements, see docs/plugins.md.
+const (
+	MethodUp         = "up"
+	MethodDown       = "down"
+	MethodDescribe   = "describe"
+	MethodList       = "list"
+	MethodConnection = "connection"
+)
+
+//What identifies the host of a user, given to every method but list.
+type HostParams struct {
+	//The metadata of the host: owner and managed_by.
+	Metadata map[string]string `json:"metadata"`
+}
+
+type UpParams struct {
+	HostParams
+	//The public key to authorize on the host, empty if none was found.
+	PublicKey string `json:"public_key,omitempty"`
+	//The driver specific options, given with --opt key=value.
+	Options map[string]string `json:"options"`
+}
+
+type UpResult struct {
+	Host Host `json:"host"`
+	//Tells if the host was created, rather than found.
+	Created bool `json:"created"`
+}
+
+type DownResult struct {
+	//The host removed, empty if there was none.
+	ID string `json:"id,omitempty"`
+}
+
+type DescribeResult struct {
+	//Nil when the user has no host.
+	Host *Host `json:"host"`
+}
+
+type ListParams struct {
+	//The metadata all the hosts have, managed_by.
+	Metadata map[string]string `json:"metadata"`
+}
+
+type ListResult struct {
+	Hosts []Host `json:"hosts"`
+}
+
+//A host, as described by a plugin.
+type Host struct {
+	ID           string     `json:"id"`
+	Owner        string     `json:"owner"`
+	State        string     `json:"state"`
+	InstanceType string     `json:"instance_type,omitempty"`
+	Address      string     `json:"address,omitempty"`
+	LaunchTime   *time.Time `json:"launch_time,omitempty"`
+}
+
+//How SSH reaches a host.
+type ConnectionResult struct {
+	User string `json:"user"`
+	Host string `json:"host"`
+	//22 when 0.
+	Port int `json:"port,omitempty"`
+	//Hosts to hop through, "user@host[:port]".
+	Jumps []string `json:"jumps,omitempty"`
+	//A command carrying the connection, as the ProxyCommand SSH option.
+	ProxyCommand string `json:"proxy_command,omitempty"`
+}
@@ -0,0 +1,176 @@
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+//A JSON-RPC 2.0 client of a plugin process, one message per line over its standard input and output.
+type Client interface {
+	//Calls a method and decodes its result, fails when the context is cancelled.
+	Call(ctx context.Context, method string, params interface{}, result interface{}) error
+	//Ends the plugin process.
+	Close() error
+}
+
+//Starts a plugin process, its standard error is forwarded to ours.
+func Start(plugin *Plugin) (Client, error) {
+	cmd := exec.Command(plugin.Path)
+	cmd.Env = append(os.Environ(), "DOCKER_REMOTE_PLUGIN_PROTOCOL="+ProtocolVersion)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open the plugin input")
+	}
+
+	stdout, err := cmd.StdoutPipe()
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open the plugin output")
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, errors.Wrapf(err, "failed to start plugin %s", plugin.Path)
+	}
+
+	client := createRPCClient(stdin, stdout)
+	client.process = cmd
+
+	return client, nil
+}
+
+//The largest message a plugin can answer, a line of JSON.
+const maxMessageSize = 16 * 1024 * 1024
+
+func createRPCClient(in io.WriteCloser, out io.Reader) *rpcClient {
+	scanner := bufio.NewScanner(out)
+	//The default 64 KiB limit is reached by the lists of large fleets.
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxMessageSize)
+
+	return &rpcClient{
+		in:      in,
+		encoder: json.NewEncoder(in),
+		scanner: scanner,
+	}
+}
+
+type rpcClient struct {
+	//Calls are sent one at a time.
+	mutex   sync.Mutex
+	nextID  int
+	in      io.WriteCloser
+	encoder *json.Encoder
+	scanner *bufio.Scanner
+	process *exec.Cmd
+}
+
+type request struct {
+	JSONRPC string      `json:"jsonrpc"`
+	ID      int         `json:"id"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      int             `json:"id"`
+	Result  json.RawMessage `json:"result"`
+	Error   *Error          `json:"error"`
+}
+
+//An error answered by a plugin.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("plugin error %d: %s", e.Code, e.Message)
+}
+
+func (c *rpcClient) Call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.nextID++
+	id := c.nextID
+
+	if err := c.encoder.Encode(&request{JSONRPC: "2.0", ID: id, Method: method, Params: params}); err != nil {
+		return errors.Wrapf(err, "failed to send %s to the plugin", method)
+	}
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- c.read(id, result)
+	}()
+
+	select {
+	case err := <-done:
+		return errors.Wrapf(err, "plugin call %s failed", method)
+	case <-ctx.Done():
+		//The answer will never be read, the plugin is stopped.
+		c.kill()
+		return ctx.Err()
+	}
+}
+
+//Reads the response of a call, skipping the ones of other calls.
+func (c *rpcClient) read(id int, result interface{}) error {
+	for c.scanner.Scan() {
+		var res response
+
+		if err := json.Unmarshal(c.scanner.Bytes(), &res); err != nil {
+			return errors.Wrap(err, "invalid message from the plugin")
+		}
+
+		if res.ID != id {
+			continue
+		}
+
+		if res.Error != nil {
+			return res.Error
+		}
+
+		if result != nil && len(res.Result) > 0 {
+			return errors.Wrap(json.Unmarshal(res.Result, result), "invalid result from the plugin")
+		}
+
+		return nil
+	}
+
+	if err := c.scanner.Err(); err != nil {
+		return errors.Wrap(err, "failed to read from the plugin")
+	}
+
+	return io.ErrUnexpectedEOF
+}
+
+//Closes the input of the plugin, which should then exit, and waits for it.
+func (c *rpcClient) Close() error {
+	if err := c.in.Close(); err != nil {
+		return err
+	}
+
+	if c.process == nil {
+		return nil
+	}
+
+	return errors.Wrap(c.process.Wait(), "the plugin failed")
+}
+
+func (c *rpcClient) kill() {
+	if c.process != nil && c.process.Process != nil {
+		_ = c.process.Process.Kill()
+	}
+}
@@ -0,0 +1,208 @@
+package pricing
+
+//On-demand Linux prices in USD, refresh them with bin/update_prices.sh.
+var embeddedPrices = `{
+  "regions": {
+    "ca-central-1": {
+      "instances": {
+        "c5.2xlarge": 0.374,
+        "c5.large": 0.0935,
+        "c5.xlarge": 0.187,
+        "c6g.large": 0.0748,
+        "c6g.xlarge": 0.1496,
+        "m5.2xlarge": 0.4224,
+        "m5.large": 0.1056,
+        "m5.xlarge": 0.2112,
+        "m6g.large": 0.0847,
+        "m6g.xlarge": 0.1694,
+        "t2.large": 0.1021,
+        "t2.medium": 0.051,
+        "t2.micro": 0.0128,
+        "t2.small": 0.0253,
+        "t2.xlarge": 0.2042,
+        "t3.2xlarge": 0.3661,
+        "t3.large": 0.0915,
+        "t3.medium": 0.0458,
+        "t3.micro": 0.0114,
+        "t3.small": 0.0229,
+        "t3.xlarge": 0.183,
+        "t3a.large": 0.0827,
+        "t3a.medium": 0.0414,
+        "t3a.xlarge": 0.1654,
+        "t4g.large": 0.0739,
+        "t4g.medium": 0.037,
+        "t4g.micro": 0.0092,
+        "t4g.small": 0.0184,
+        "t4g.xlarge": 0.1478
+      },
+      "volumes": {
+        "gp2": 0.11,
+        "gp3": 0.088,
+        "io1": 0.1375,
+        "st1": 0.0495,
+        "standard": 0.055
+      }
+    },
+    "eu-west-1": {
+      "instances": {
+        "c5.2xlarge": 0.384,
+        "c5.large": 0.096,
+        "c5.xlarge": 0.192,
+        "c6g.large": 0.0772,
+        "c6g.xlarge": 0.1544,
+        "m5.2xlarge": 0.428,
+        "m5.large": 0.107,
+        "m5.xlarge": 0.214,
+        "m6g.large": 0.086,
+        "m6g.xlarge": 0.172,
+        "t2.large": 0.1008,
+        "t2.medium": 0.05,
+        "t2.micro": 0.0126,
+        "t2.small": 0.025,
+        "t2.xlarge": 0.2016,
+        "t3.2xlarge": 0.3648,
+        "t3.large": 0.0912,
+        "t3.medium": 0.0456,
+        "t3.micro": 0.0114,
+        "t3.small": 0.0228,
+        "t3.xlarge": 0.1824,
+        "t3a.large": 0.0816,
+        "t3a.medium": 0.0408,
+        "t3a.xlarge": 0.1632,
+        "t4g.large": 0.0736,
+        "t4g.medium": 0.0368,
+        "t4g.micro": 0.0092,
+        "t4g.small": 0.0184,
+        "t4g.xlarge": 0.1472
+      },
+      "volumes": {
+        "gp2": 0.11,
+        "gp3": 0.088,
+        "io1": 0.138,
+        "st1": 0.05,
+        "standard": 0.055
+      }
+    },
+    "us-east-1": {
+      "instances": {
+        "c5.2xlarge": 0.34,
+        "c5.large": 0.085,
+        "c5.xlarge": 0.17,
+        "c6g.large": 0.068,
+        "c6g.xlarge": 0.136,
+        "m5.2xlarge": 0.384,
+        "m5.large": 0.096,
+        "m5.xlarge": 0.192,
+        "m6g.large": 0.077,
+        "m6g.xlarge": 0.154,
+        "t2.large": 0.0928,
+        "t2.medium": 0.0464,
+        "t2.micro": 0.0116,
+        "t2.small": 0.023,
+        "t2.xlarge": 0.1856,
+        "t3.2xlarge": 0.3328,
+        "t3.large": 0.0832,
+        "t3.medium": 0.0416,
+        "t3.micro": 0.0104,
+        "t3.small": 0.0208,
+        "t3.xlarge": 0.1664,
+        "t3a.large": 0.0752,
+        "t3a.medium": 0.0376,
+        "t3a.xlarge": 0.1504,
+        "t4g.large": 0.0672,
+        "t4g.medium": 0.0336,
+        "t4g.micro": 0.0084,
+        "t4g.small": 0.0168,
+        "t4g.xlarge": 0.1344
+      },
+      "volumes": {
+        "gp2": 0.1,
+        "gp3": 0.08,
+        "io1": 0.125,
+        "st1": 0.045,
+        "standard": 0.05
+      }
+    },
+    "us-east-2": {
+      "instances": {
+        "c5.2xlarge": 0.34,
+        "c5.large": 0.085,
+        "c5.xlarge": 0.17,
+        "c6g.large": 0.068,
+        "c6g.xlarge": 0.136,
+        "m5.2xlarge": 0.384,
+        "m5.large": 0.096,
+        "m5.xlarge": 0.192,
+        "m6g.large": 0.077,
+        "m6g.xlarge": 0.154,
+        "t2.large": 0.0928,
+        "t2.medium": 0.0464,
+        "t2.micro": 0.0116,
+        "t2.small": 0.023,
+        "t2.xlarge": 0.1856,
+        "t3.2xlarge": 0.3328,
+        "t3.large": 0.0832,
+        "t3.medium": 0.0416,
+        "t3.micro": 0.0104,
+        "t3.small": 0.0208,
+        "t3.xlarge": 0.1664,
+        "t3a.large": 0.0752,
+        "t3a.medium": 0.0376,
+        "t3a.xlarge": 0.1504,
+        "t4g.large": 0.0672,
+        "t4g.medium": 0.0336,
+        "t4g.micro": 0.0084,
+        "t4g.small": 0.0168,
+        "t4g.xlarge": 0.1344
+      },
+      "volumes": {
+        "gp2": 0.1,
+        "gp3": 0.08,
+        "io1": 0.125,
+        "st1": 0.045,
+        "standard": 0.05
+      }
+    },
+    "us-west-2": {
+      "instances": {
+        "c5.2xlarge": 0.34,
+        "c5.large": 0.085,
+        "c5.xlarge": 0.17,
+        "c6g.large": 0.068,
+        "c6g.xlarge": 0.136,
+        "m5.2xlarge": 0.384,
+        "m5.large": 0.096,
+        "m5.xlarge": 0.192,
+        "m6g.large": 0.077,
+        "m6g.xlarge": 0.154,
+        "t2.large": 0.0928,
+        "t2.medium": 0.0464,
+        "t2.micro": 0.0116,
+        "t2.small": 0.023,
+        "t2.xlarge": 0.1856,
+        "t3.2xlarge": 0.3328,
+        "t3.large": 0.0832,
+        "t3.medium": 0.0416,
+        "t3.micro": 0.0104,
+        "t3.small": 0.0208,
+        "t3.xlarge": 0.1664,
+        "t3a.large": 0.0752,
+        "t3a.medium": 0.0376,
+        "t3a.xlarge": 0.1504,
+        "t4g.large": 0.0672,
+        "t4g.medium": 0.0336,
+        "t4g.micro": 0.0084,
+        "t4g.small": 0.0168,
+        "t4g.xlarge": 0.1344
+      },
+      "volumes": {
+        "gp2": 0.1,
+        "gp3": 0.08,
+        "io1": 0.125,
+        "st1": 0.045,
+        "standard": 0.05
+      }
+    }
+  }
+}
+`
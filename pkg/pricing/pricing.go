@@ -0,0 +1,172 @@
+package pricing
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"sync"
+
+	"github.com/knlambert/docker-remote.git/pkg/std/ioutil"
+	"github.com/knlambert/docker-remote.git/pkg/std/os"
+	"github.com/knlambert/docker-remote.git/pkg/std/user"
+	"github.com/pkg/errors"
+)
+
+//Hours in a month, as used by AWS to bill volumes hourly.
+const HoursPerMonth = 730
+
+//Prices in USD, per region.
+type Table struct {
+	Regions map[string]RegionPrices `json:"regions"`
+}
+
+type RegionPrices struct {
+	//On-demand Linux price per hour, by instance type.
+	Instances map[string]float64 `json:"instances"`
+	//Price per GB and per month, by volume type.
+	Volumes map[string]float64 `json:"volumes"`
+}
+
+type Pricing interface {
+	//Returns the on-demand hourly price of an instance type, nil when unknown.
+	InstanceHourlyPrice(region string, instanceType string) (*float64, error)
+	//Returns the monthly price of a GB of a volume type, nil when unknown.
+	VolumeMonthlyPrice(region string, volumeType string) (*float64, error)
+}
+
+//Creates a pricing reading the embedded table, refreshed by ~/.docker-remote/prices.json when present.
+func CreatePricing() Pricing {
+	return &pricingImpl{
+		io:   ioutil.CreateIOUtil(),
+		os:   os.CreateOS(),
+		user: user.CreateUser(),
+	}
+}
+
+type pricingImpl struct {
+	io   ioutil.IOUtil
+	os   os.OS
+	user user.User
+
+	once  sync.Once
+	table *Table
+	err   error
+}
+
+//Returns the on-demand hourly price of an instance type, nil when unknown.
+func (p *pricingImpl) InstanceHourlyPrice(region string, instanceType string) (*float64, error) {
+	table, err := p.load()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if price, ok := table.Regions[region].Instances[instanceType]; ok {
+		return &price, nil
+	}
+
+	return nil, nil
+}
+
+//Returns the monthly price of a GB of a volume type, nil when unknown.
+func (p *pricingImpl) VolumeMonthlyPrice(region string, volumeType string) (*float64, error) {
+	table, err := p.load()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if price, ok := table.Regions[region].Volumes[volumeType]; ok {
+		return &price, nil
+	}
+
+	return nil, nil
+}
+
+//Loads the embedded table once, then merges the user's refreshed prices over it.
+func (p *pricingImpl) load() (*Table, error) {
+	p.once.Do(func() {
+		table, err := EmbeddedTable()
+
+		if err != nil {
+			p.err = err
+			return
+		}
+
+		currentUser, err := p.user.Current()
+
+		if err != nil {
+			p.err = errors.Wrap(err, "failed to determine current user")
+			return
+		}
+
+		overridePath := filepath.Join(currentUser.HomeDir, ".docker-remote", "prices.json")
+
+		if exists, err := p.os.PathExists(overridePath); err != nil || !exists {
+			p.table, p.err = table, err
+			return
+		}
+
+		content, err := p.io.ReadFile(overridePath)
+
+		if err != nil {
+			p.err = errors.Wrapf(err, "failed to read %s", overridePath)
+			return
+		}
+
+		override := Table{}
+
+		if err := json.Unmarshal(content, &override); err != nil {
+			p.err = errors.Wrapf(err, "failed to parse %s", overridePath)
+			return
+		}
+
+		table.merge(&override)
+		p.table = table
+	})
+
+	return p.table, p.err
+}
+
+//Parses the price table embedded in the binary.
+func EmbeddedTable() (*Table, error) {
+	table := Table{}
+
+	if err := json.Unmarshal([]byte(embeddedPrices), &table); err != nil {
+		return nil, errors.Wrap(err, "failed to parse the embedded price table")
+	}
+
+	return &table, nil
+}
+
+//Overwrites the prices of the table with the other's.
+func (t *Table) merge(other *Table) {
+	if t.Regions == nil {
+		t.Regions = map[string]RegionPrices{}
+	}
+
+	for region, prices := range other.Regions {
+		current, ok := t.Regions[region]
+
+		if !ok {
+			current = RegionPrices{}
+		}
+
+		if current.Instances == nil {
+			current.Instances = map[string]float64{}
+		}
+
+		if current.Volumes == nil {
+			current.Volumes = map[string]float64{}
+		}
+
+		for instanceType, price := range prices.Instances {
+			current.Instances[instanceType] = price
+		}
+
+		for volumeType, price := range prices.Volumes {
+			current.Volumes[volumeType] = price
+		}
+
+		t.Regions[region] = current
+	}
+}
@@ -0,0 +1,60 @@
+package pricing
+
+import (
+	"os/user"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	mock_ioutil "github.com/knlambert/docker-remote.git/pkg/mock/std/ioutil"
+	mock_os "github.com/knlambert/docker-remote.git/pkg/mock/std/os"
+	mock_user "github.com/knlambert/docker-remote.git/pkg/mock/std/user"
+	"github.com/stretchr/testify/assert"
+)
+
+func stubbedPricing(ctrl *gomock.Controller) (
+	*pricingImpl,
+	*mock_ioutil.MockIOUtil,
+	*mock_os.MockOS,
+) {
+	ioMock := mock_ioutil.NewMockIOUtil(ctrl)
+	osMock := mock_os.NewMockOS(ctrl)
+	userMock := mock_user.NewMockUser(ctrl)
+
+	userMock.EXPECT().Current().Return(&user.User{HomeDir: "/home/barney"}, nil)
+
+	return &pricingImpl{
+		io:   ioMock,
+		os:   osMock,
+		user: userMock,
+	}, ioMock, osMock
+}
+
+func TestPricesRefreshedByUserFile(t *testing.T) {
+	// Tear up.
+	ctrl := gomock.NewController(t)
+	s, ioMock, osMock := stubbedPricing(ctrl)
+
+	expectedOverridePath := filepath.Join("/home/barney", ".docker-remote", "prices.json")
+
+	osMock.EXPECT().PathExists(expectedOverridePath).Return(true, nil)
+	ioMock.EXPECT().ReadFile(expectedOverridePath).Return([]byte(
+		`{"regions": {"us-east-1": {"instances": {"t2.micro": 0.5}}, "ap-south-1": {"volumes": {"gp2": 0.2}}}}`,
+	), nil)
+
+	//Assertions
+	refreshed, err := s.InstanceHourlyPrice("us-east-1", "t2.micro")
+	assert.Nil(t, err)
+	assert.Equal(t, 0.5, *refreshed)
+
+	embedded, _ := s.InstanceHourlyPrice("us-east-1", "t3.large")
+	assert.Equal(t, 0.0832, *embedded)
+
+	added, _ := s.VolumeMonthlyPrice("ap-south-1", "gp2")
+	assert.Equal(t, 0.2, *added)
+
+	unknown, _ := s.InstanceHourlyPrice("us-east-1", "x9.huge")
+	assert.Nil(t, unknown)
+
+	ctrl.Finish()
+}
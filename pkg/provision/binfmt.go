@@ -0,0 +1,24 @@
+package provision
+
+//The systemd unit registering QEMU in binfmt_misc, which does not survive a reboot, at each boot.
+const BinfmtUnitPath = "/etc/systemd/system/docker-remote-binfmt.service"
+
+//Registers QEMU for the other architectures once docker runs, so that the host builds their images.
+var BinfmtUnit = `[Unit]
+Description=Registers QEMU for the other architectures
+After=docker.service
+Requires=docker.service
+
+[Service]
+Type=oneshot
+RemainAfterExit=yes
+ExecStart=/usr/bin/docker run --privileged --rm tonistiigi/binfmt --install all
+
+[Install]
+WantedBy=multi-user.target`
+
+//Enables the unit, registering QEMU right away too.
+var BinfmtCommands = []string{
+	"systemctl daemon-reload",
+	"systemctl enable --now docker-remote-binfmt.service",
+}
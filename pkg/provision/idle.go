@@ -0,0 +1,73 @@
+package provision
+
+import "fmt"
+
+//Where the watchdog stores the time (epoch seconds) of the last activity, reset at boot.
+const IdleStatePath = "/run/docker-remote/last-activity"
+
+//Records activity (running containers, docker CLI sessions, interactive SSH sessions used
+//within the last minute or load), warns logged users 5 minutes before shutting down.
+var idleWatchdogScript = `#!/bin/bash
+state=` + IdleStatePath + `
+timeout=$((IDLE_TIMEOUT_MINUTES * 60))
+now=$(date +%s)
+
+mkdir -p "$(dirname "$state")"
+[ -f "$state" ] || echo "$now" > "$state"
+
+active() {
+  [ -n "$(docker ps -q 2>/dev/null)" ] && return 0
+  pgrep -f "docker system dial-stdio" > /dev/null && return 0
+  [ -n "$(who -u | awk '$5 == "."')" ] && return 0
+  awk '{ exit !($1 >= 0.5) }' /proc/loadavg && return 0
+  return 1
+}
+
+if active; then
+  echo "$now" > "$state"
+  exit 0
+fi
+
+remaining=$((timeout - (now - $(cat "$state"))))
+
+if [ "$remaining" -le 0 ]; then
+  shutdown -h now "docker-remote: idle for ${IDLE_TIMEOUT_MINUTES} minutes"
+elif [ "$remaining" -le 300 ]; then
+  wall "docker-remote: host idle, shutting down in $(((remaining + 59) / 60)) minute(s)"
+fi`
+
+var idleWatchdogCommands = []string{
+	"systemctl daemon-reload",
+	"systemctl enable --now docker-remote-idle.timer",
+}
+
+//Files of the systemd timer running the idle watchdog every minute.
+func idleWatchdogFiles(idleTimeout int) []File {
+	return []File{{
+		Path:        "/usr/local/bin/docker-remote-idle",
+		Permissions: "0755",
+		Content:     idleWatchdogScript,
+	}, {
+		Path:        "/etc/systemd/system/docker-remote-idle.service",
+		Permissions: "0644",
+		Content: fmt.Sprintf(`[Unit]
+Description=Shuts the docker-remote host down when idle
+
+[Service]
+Type=oneshot
+Environment=IDLE_TIMEOUT_MINUTES=%d
+ExecStart=/usr/local/bin/docker-remote-idle`, idleTimeout),
+	}, {
+		Path:        "/etc/systemd/system/docker-remote-idle.timer",
+		Permissions: "0644",
+		Content: `[Unit]
+Description=Checks the docker-remote host activity every minute
+
+[Timer]
+OnBootSec=1min
+OnUnitActiveSec=1min
+
+[Install]
+WantedBy=timers.target`,
+	}}
+}
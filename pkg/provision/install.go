@@ -0,0 +1,76 @@
+package provision
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+//Commands installing docker, by distribution family.
+var dockerInstallCommands = map[string]string{
+	"debian": "sudo apt-get update -q && sudo DEBIAN_FRONTEND=noninteractive apt-get install -y -q docker.io",
+	"amzn":   "sudo yum install -y docker",
+	"fedora": "sudo dnf install -y moby-engine",
+	"rhel":   "curl -fsSL https://get.docker.com | sudo sh",
+	"alpine": "sudo apk add docker",
+	"arch":   "sudo pacman -Sy --noconfirm docker",
+	"suse":   "sudo zypper --non-interactive install docker",
+}
+
+//A Linux distribution, as described by /etc/os-release.
+type Distribution struct {
+	ID string
+	//The distributions this one derives from.
+	IDLike []string
+	Name   string
+}
+
+//Parses the content of /etc/os-release.
+func ParseOSRelease(content []byte) *Distribution {
+	values := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), "=", 2)
+
+		if len(parts) == 2 {
+			values[parts[0]] = strings.Trim(parts[1], `"'`)
+		}
+	}
+
+	return &Distribution{
+		ID:     values["ID"],
+		IDLike: strings.Fields(values["ID_LIKE"]),
+		Name:   values["PRETTY_NAME"],
+	}
+}
+
+//Returns a script installing docker if it is missing, starting it at boot and letting the user use it.
+func DockerInstallScript(distribution *Distribution, user string) (string, error) {
+	var install string
+
+	for _, family := range append([]string{distribution.ID}, distribution.IDLike...) {
+		if command, ok := dockerInstallCommands[family]; ok {
+			install = command
+			break
+		}
+	}
+
+	if install == "" {
+		return "", errors.Errorf("don't know how to install docker on %s", distribution.Name)
+	}
+
+	enable := "sudo systemctl enable --now docker"
+
+	if distribution.ID == "alpine" {
+		enable = "sudo rc-update add docker default && sudo service docker start"
+	}
+
+	return fmt.Sprintf(
+		"set -e\ncommand -v docker >/dev/null || %s\n%s\nsudo usermod -a -G docker %s\n",
+		install, enable, user,
+	), nil
+}
@@ -0,0 +1,209 @@
+package provision
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/knlambert/docker-remote.git/pkg/std/ioutil"
+	"github.com/knlambert/docker-remote.git/pkg/std/os"
+	"github.com/knlambert/docker-remote.git/pkg/std/user"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+type Format string
+
+const (
+	Bash        Format = "bash"
+	CloudConfig Format = "cloud-config"
+)
+
+//Parameters used to render the provisioning script of a host.
+type Params struct {
+	//The format of the default template (ignored when Template is set).
+	Format Format
+	//A template name (~/.docker-remote/templates/<name>.tmpl) or path overriding the default one.
+	Template string
+	//Variables made available to the template as .Vars.
+	Vars map[string]string
+	//A YAML file of variables, merged under Vars.
+	VarsFile string
+	//Files appended to the rendered script.
+	Snippets []string
+	//The user which will use docker on the host.
+	User string
+	//Minutes without activity after which the host shuts itself down, 0 to disable.
+	IdleTimeout int
+	//Registers QEMU at each boot to build images for the other architectures.
+	Binfmt bool
+}
+
+//Data given to the provisioning templates.
+type TemplateData struct {
+	User     string
+	Vars     map[string]string
+	Snippets []Snippet
+	//Files written on the host by docker-remote features.
+	Files []File
+	//Commands run as root once the files are written.
+	Commands []string
+}
+
+//A file written on the host.
+type File struct {
+	Path        string
+	Permissions string
+	Content     string
+}
+
+//A user supplied piece of script appended to the provisioning.
+type Snippet struct {
+	Name    string
+	Content string
+}
+
+type Provision interface {
+	//Renders the provisioning script (user-data) of a host.
+	Render(params *Params) (string, error)
+}
+
+func CreateProvision() Provision {
+	return &provisionImpl{
+		io:   ioutil.CreateIOUtil(),
+		os:   os.CreateOS(),
+		user: user.CreateUser(),
+	}
+}
+
+type provisionImpl struct {
+	io   ioutil.IOUtil
+	os   os.OS
+	user user.User
+}
+
+//Renders the provisioning script (user-data) of a host.
+func (p *provisionImpl) Render(params *Params) (string, error) {
+	source, err := p.templateSource(params)
+
+	if err != nil {
+		return "", err
+	}
+
+	data, err := p.templateData(params)
+
+	if err != nil {
+		return "", err
+	}
+
+	tpl, err := template.New("user-data").Funcs(template.FuncMap{
+		"fields": strings.Fields,
+		"indent": indent,
+	}).Parse(source)
+
+	if err != nil {
+		return "", errors.Wrap(err, "failed to parse the provisioning template")
+	}
+
+	var rendered bytes.Buffer
+
+	if err := tpl.Execute(&rendered, data); err != nil {
+		return "", errors.Wrap(err, "failed to render the provisioning template")
+	}
+
+	return rendered.String(), nil
+}
+
+//Returns the raw template to render, either a built-in one or the user's.
+func (p *provisionImpl) templateSource(params *Params) (string, error) {
+	if params.Template == "" {
+		switch params.Format {
+		case Bash, "":
+			return bashTemplate, nil
+		case CloudConfig:
+			return cloudConfigTemplate, nil
+		}
+		return "", errors.Errorf("unknown user-data format '%s'", params.Format)
+	}
+
+	templatePath := params.Template
+
+	if exists, err := p.os.PathExists(templatePath); err != nil {
+		return "", errors.Wrapf(err, "failed to check %s path existence", templatePath)
+	} else if !exists {
+		currentUser, err := p.user.Current()
+
+		if err != nil {
+			return "", errors.Wrap(err, "failed to determine current user")
+		}
+
+		templatePath = filepath.Join(
+			currentUser.HomeDir, ".docker-remote", "templates", fmt.Sprintf("%s.tmpl", params.Template),
+		)
+	}
+
+	content, err := p.io.ReadFile(templatePath)
+
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read template %s", params.Template)
+	}
+
+	return string(content), nil
+}
+
+//Gathers the variables and snippets given to the template.
+func (p *provisionImpl) templateData(params *Params) (*TemplateData, error) {
+	data := TemplateData{
+		User: params.User,
+		Vars: map[string]string{},
+	}
+
+	if params.VarsFile != "" {
+		content, err := p.io.ReadFile(params.VarsFile)
+
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read variables file %s", params.VarsFile)
+		}
+
+		if err := yaml.Unmarshal(content, &data.Vars); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse variables file %s", params.VarsFile)
+		}
+	}
+
+	for key, value := range params.Vars {
+		data.Vars[key] = value
+	}
+
+	if params.IdleTimeout > 0 {
+		data.Files = append(data.Files, idleWatchdogFiles(params.IdleTimeout)...)
+		data.Commands = append(data.Commands, idleWatchdogCommands...)
+	}
+
+	if params.Binfmt {
+		data.Files = append(data.Files, File{Path: BinfmtUnitPath, Permissions: "0644", Content: BinfmtUnit})
+		data.Commands = append(data.Commands, BinfmtCommands...)
+	}
+
+	for _, snippetPath := range params.Snippets {
+		content, err := p.io.ReadFile(snippetPath)
+
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read user-data file %s", snippetPath)
+		}
+
+		data.Snippets = append(data.Snippets, Snippet{
+			Name:    filepath.Base(snippetPath),
+			Content: strings.TrimRight(string(content), "\n"),
+		})
+	}
+
+	return &data, nil
+}
+
+//Indents every line of a text, used to embed files in cloud-config.
+func indent(spaces int, text string) string {
+	padding := strings.Repeat(" ", spaces)
+	return padding + strings.Replace(text, "\n", "\n"+padding, -1)
+}
@@ -0,0 +1,204 @@
+package provision
+
+import (
+	"os/user"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	mock_ioutil "github.com/knlambert/docker-remote.git/pkg/mock/std/ioutil"
+	mock_os "github.com/knlambert/docker-remote.git/pkg/mock/std/os"
+	mock_user "github.com/knlambert/docker-remote.git/pkg/mock/std/user"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func stubbedProvision(ctrl *gomock.Controller) (
+	*provisionImpl,
+	*mock_ioutil.MockIOUtil,
+	*mock_os.MockOS,
+	*mock_user.MockUser,
+) {
+	ioMock := mock_ioutil.NewMockIOUtil(ctrl)
+	osMock := mock_os.NewMockOS(ctrl)
+	userMock := mock_user.NewMockUser(ctrl)
+
+	return &provisionImpl{
+		io:   ioMock,
+		os:   osMock,
+		user: userMock,
+	}, ioMock, osMock, userMock
+}
+
+func TestRenderDefaultBash(t *testing.T) {
+	// Tear up.
+	ctrl := gomock.NewController(t)
+	s, ioMock, _, _ := stubbedProvision(ctrl)
+
+	ioMock.EXPECT().ReadFile("/tmp/ca.sh").Return([]byte("update-ca-trust\n"), nil)
+
+	//Assertions
+	script, err := s.Render(&Params{
+		Format:   Bash,
+		Vars:     map[string]string{"registry_mirror": "https://mirror.local"},
+		Snippets: []string{"/tmp/ca.sh"},
+		User:     "ec2-user",
+	})
+
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(script, "#!/bin/bash\n"))
+	assert.Contains(t, script, "usermod -a -G docker ec2-user")
+	assert.Contains(t, script, `{"registry-mirrors": ["https://mirror.local"]}`)
+	assert.Contains(t, script, "# ca.sh\nupdate-ca-trust\n")
+	assert.NotContains(t, script, "swapfile")
+
+	ctrl.Finish()
+}
+
+func TestRenderDefaultCloudConfig(t *testing.T) {
+	// Tear up.
+	ctrl := gomock.NewController(t)
+	s, ioMock, _, _ := stubbedProvision(ctrl)
+
+	ioMock.EXPECT().ReadFile("/tmp/vars.yaml").Return([]byte("extra_packages: git htop\nswap_size: 2G\n"), nil)
+	ioMock.EXPECT().ReadFile("/tmp/ca.sh").Return([]byte("#!/bin/bash\nupdate-ca-trust\n"), nil)
+
+	//Assertions
+	script, err := s.Render(&Params{
+		Format:   CloudConfig,
+		VarsFile: "/tmp/vars.yaml",
+		Snippets: []string{"/tmp/ca.sh"},
+		User:     "ec2-user",
+	})
+
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(script, "#cloud-config\n"))
+
+	var document struct {
+		Packages   []string `yaml:"packages"`
+		WriteFiles []struct {
+			Path    string `yaml:"path"`
+			Content string `yaml:"content"`
+		} `yaml:"write_files"`
+		RunCmd []string `yaml:"runcmd"`
+	}
+
+	assert.Nil(t, yaml.Unmarshal([]byte(script), &document), "cloud-config should be valid YAML")
+	assert.Equal(t, []string{"git", "htop"}, document.Packages)
+	assert.Equal(t, "#!/bin/bash\nupdate-ca-trust\n", document.WriteFiles[0].Content)
+	assert.Contains(t, document.RunCmd, "fallocate -l 2G /swapfile")
+	assert.Contains(t, document.RunCmd, "bash /var/lib/docker-remote/snippets/0-ca.sh")
+
+	ctrl.Finish()
+}
+
+func TestRenderNamedTemplate(t *testing.T) {
+	// Tear up.
+	ctrl := gomock.NewController(t)
+	s, ioMock, osMock, userMock := stubbedProvision(ctrl)
+
+	expectedTemplatePath := filepath.Join("/home/barney", ".docker-remote", "templates", "team.tmpl")
+
+	osMock.EXPECT().PathExists("team").Return(false, nil)
+	userMock.EXPECT().Current().Return(&user.User{HomeDir: "/home/barney"}, nil)
+	ioMock.EXPECT().ReadFile(expectedTemplatePath).Return([]byte("#!/bin/sh\necho {{ .Vars.team }}\n"), nil)
+
+	//Assertions
+	script, err := s.Render(&Params{
+		Template: "team",
+		Vars:     map[string]string{"team": "platform"},
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "#!/bin/sh\necho platform\n", script)
+
+	ctrl.Finish()
+}
+
+func TestRenderEnablesDockerAtBoot(t *testing.T) {
+	// Tear up.
+	ctrl := gomock.NewController(t)
+	s, _, _, _ := stubbedProvision(ctrl)
+
+	//Assertions
+	for _, format := range []Format{Bash, CloudConfig} {
+		script, err := s.Render(&Params{Format: format, User: "ec2-user"})
+
+		assert.Nil(t, err)
+		assert.Contains(t, script, "systemctl enable --now docker\n", "docker should start again with a stopped host")
+	}
+
+	ctrl.Finish()
+}
+
+func TestRenderIdleWatchdog(t *testing.T) {
+	// Tear up.
+	ctrl := gomock.NewController(t)
+	s, _, _, _ := stubbedProvision(ctrl)
+
+	//Assertions
+	for _, format := range []Format{Bash, CloudConfig} {
+		script, err := s.Render(&Params{
+			Format:      format,
+			User:        "ec2-user",
+			IdleTimeout: 30,
+		})
+
+		assert.Nil(t, err)
+		assert.Contains(t, script, "Environment=IDLE_TIMEOUT_MINUTES=30")
+		assert.Contains(t, script, "systemctl enable --now docker-remote-idle.timer")
+	}
+
+	script, _ := s.Render(&Params{Format: CloudConfig, User: "ec2-user", IdleTimeout: 30})
+
+	var document struct {
+		WriteFiles []struct {
+			Path string `yaml:"path"`
+		} `yaml:"write_files"`
+	}
+
+	assert.Nil(t, yaml.Unmarshal([]byte(script), &document), "cloud-config should be valid YAML")
+	assert.Equal(t, "/usr/local/bin/docker-remote-idle", document.WriteFiles[0].Path)
+
+	ctrl.Finish()
+}
+
+func TestRenderBinfmt(t *testing.T) {
+	// Tear up.
+	ctrl := gomock.NewController(t)
+	s, _, _, _ := stubbedProvision(ctrl)
+
+	//Assertions
+	for _, format := range []Format{Bash, CloudConfig} {
+		script, err := s.Render(&Params{Format: format, User: "ec2-user", Binfmt: true})
+
+		assert.Nil(t, err)
+		assert.Contains(t, script, BinfmtUnitPath)
+		assert.Contains(t, script, "WantedBy=multi-user.target", "the registration should be made at each boot")
+		assert.Contains(t, script, "systemctl enable --now docker-remote-binfmt.service")
+	}
+
+	script, _ := s.Render(&Params{Format: Bash, User: "ec2-user"})
+
+	assert.NotContains(t, script, "binfmt")
+
+	ctrl.Finish()
+}
+
+func TestDockerInstallScript(t *testing.T) {
+	distribution := ParseOSRelease([]byte("NAME=\"Ubuntu\"\nID=ubuntu\nID_LIKE=debian\nPRETTY_NAME=\"Ubuntu 20.04.1 LTS\"\n"))
+
+	//Assertions
+	assert.Equal(t, &Distribution{ID: "ubuntu", IDLike: []string{"debian"}, Name: "Ubuntu 20.04.1 LTS"}, distribution)
+
+	script, err := DockerInstallScript(distribution, "barney")
+
+	assert.Nil(t, err)
+	assert.Contains(t, script, "command -v docker >/dev/null || sudo apt-get update")
+	assert.Contains(t, script, "sudo usermod -a -G docker barney")
+
+	_, err = DockerInstallScript(&Distribution{ID: "plan9", Name: "Plan 9"}, "barney")
+
+	assert.EqualError(t, err, "don't know how to install docker on Plan 9")
+}
@@ -0,0 +1,97 @@
+package provision
+
+//Default bash provisioning, supports the extra_packages, registry_mirror,
+//docker_compose_version and swap_size variables.
+var bashTemplate = `#!/bin/bash
+sudo yum update -y
+sudo amazon-linux-extras install docker -y
+sudo yum install docker -y
+{{- with .Vars.extra_packages }}
+sudo yum install -y {{ . }}
+{{- end }}
+{{- with .Vars.registry_mirror }}
+sudo mkdir -p /etc/docker
+echo '{"registry-mirrors": ["{{ . }}"]}' | sudo tee /etc/docker/daemon.json
+{{- end }}
+sudo systemctl enable --now docker
+sudo usermod -a -G docker {{ .User }}
+{{- with .Vars.docker_compose_version }}
+sudo curl -L "https://github.com/docker/compose/releases/download/{{ . }}/docker-compose-$(uname -s)-$(uname -m)" -o /usr/local/bin/docker-compose
+sudo chmod +x /usr/local/bin/docker-compose
+{{- end }}
+{{- range .Files }}
+sudo mkdir -p "$(dirname {{ .Path }})"
+cat <<'DOCKER_REMOTE_EOF' | sudo tee {{ .Path }} > /dev/null
+{{ .Content }}
+DOCKER_REMOTE_EOF
+sudo chmod {{ .Permissions }} {{ .Path }}
+{{- end }}
+{{- range .Commands }}
+sudo {{ . }}
+{{- end }}
+{{- with .Vars.swap_size }}
+sudo fallocate -l {{ . }} /swapfile
+sudo chmod 600 /swapfile
+sudo mkswap /swapfile
+sudo swapon /swapfile
+echo '/swapfile none swap defaults 0 0' | sudo tee -a /etc/fstab
+{{- end }}
+{{- range .Snippets }}
+
+# {{ .Name }}
+{{ .Content }}
+{{- end }}
+`
+
+//Default cloud-config provisioning, same variables as the bash one.
+var cloudConfigTemplate = `#cloud-config
+repo_update: true
+{{- with .Vars.extra_packages }}
+packages:
+{{- range fields . }}
+  - {{ . }}
+{{- end }}
+{{- end }}
+{{- if or .Vars.registry_mirror .Snippets .Files }}
+write_files:
+{{- with .Vars.registry_mirror }}
+  - path: /etc/docker/daemon.json
+    content: |
+      {"registry-mirrors": ["{{ . }}"]}
+{{- end }}
+{{- range .Files }}
+  - path: {{ .Path }}
+    permissions: '{{ .Permissions }}'
+    content: |
+{{ indent 6 .Content }}
+{{- end }}
+{{- range $i, $snippet := .Snippets }}
+  - path: /var/lib/docker-remote/snippets/{{ $i }}-{{ $snippet.Name }}
+    permissions: '0755'
+    content: |
+{{ indent 6 $snippet.Content }}
+{{- end }}
+{{- end }}
+runcmd:
+  - amazon-linux-extras install docker -y
+  - yum install docker -y
+  - systemctl enable --now docker
+  - usermod -a -G docker {{ .User }}
+{{- with .Vars.docker_compose_version }}
+  - curl -L "https://github.com/docker/compose/releases/download/{{ . }}/docker-compose-$(uname -s)-$(uname -m)" -o /usr/local/bin/docker-compose
+  - chmod +x /usr/local/bin/docker-compose
+{{- end }}
+{{- range .Commands }}
+  - {{ . }}
+{{- end }}
+{{- with .Vars.swap_size }}
+  - fallocate -l {{ . }} /swapfile
+  - chmod 600 /swapfile
+  - mkswap /swapfile
+  - swapon /swapfile
+  - echo '/swapfile none swap defaults 0 0' >> /etc/fstab
+{{- end }}
+{{- range $i, $snippet := .Snippets }}
+  - bash /var/lib/docker-remote/snippets/{{ $i }}-{{ $snippet.Name }}
+{{- end }}
+`
@@ -0,0 +1,94 @@
+package sshutil
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+//Adds or replaces a Host entry managed by docker-remote in ~/.ssh/config, so that
+//the ssh command line (used by docker contexts) reaches the target through its alias.
+func (s *sshUtilsImpl) SSHConfigSet(alias string, target *Target) error {
+	var entry bytes.Buffer
+
+	fmt.Fprintf(&entry, "Host %s\n", alias)
+	fmt.Fprintf(&entry, "  HostName %s\n", target.Host)
+	fmt.Fprintf(&entry, "  User %s\n", target.User)
+
+	if target.Port != 0 {
+		fmt.Fprintf(&entry, "  Port %s\n", strconv.Itoa(target.Port))
+	}
+
+	if len(target.Jumps) > 0 {
+		fmt.Fprintf(&entry, "  ProxyJump %s\n", target.ProxyJump())
+	}
+
+	if target.ProxyCommand != "" {
+		fmt.Fprintf(&entry, "  ProxyCommand %s\n", target.ProxyCommand)
+	}
+
+	return s.sshConfigUpdate(alias, entry.Bytes())
+}
+
+//Removes the Host entry managed by docker-remote from ~/.ssh/config.
+func (s *sshUtilsImpl) SSHConfigRemove(alias string) error {
+	return s.sshConfigUpdate(alias, nil)
+}
+
+//Replaces the block delimited by docker-remote markers for an alias, appends it if missing.
+//Removing an alias that has no block touches nothing.
+func (s *sshUtilsImpl) sshConfigUpdate(alias string, entry []byte) error {
+	currentUser, err := s.user.Current()
+
+	if err != nil {
+		return errors.Wrap(err, "failed to determine current user")
+	}
+
+	sshFolderPath := filepath.Join(currentUser.HomeDir, ".ssh")
+	configPath := filepath.Join(sshFolderPath, "config")
+
+	var content []byte
+
+	if exists, err := s.os.PathExists(configPath); err != nil {
+		return errors.Wrapf(err, "failed to check %s path existence", configPath)
+	} else if exists {
+		if content, err = s.io.ReadFile(configPath); err != nil {
+			return errors.Wrapf(err, "failed to read %s", configPath)
+		}
+	} else if entry == nil {
+		return nil
+	} else if err := s.os.MkdirAll(sshFolderPath, 0700); err != nil {
+		return errors.Wrapf(err, "failed to create %s", sshFolderPath)
+	}
+
+	begin := fmt.Sprintf("# BEGIN docker-remote %s", alias)
+	end := fmt.Sprintf("# END docker-remote %s", alias)
+	block := regexp.MustCompile(fmt.Sprintf(`(?s)\n?%s\n.*?%s\n`, regexp.QuoteMeta(begin), regexp.QuoteMeta(end)))
+
+	updated := block.ReplaceAll(content, nil)
+
+	//Removing an alias without entry leaves the file as the user wrote it.
+	if entry == nil && bytes.Equal(updated, content) {
+		return nil
+	}
+
+	content = updated
+
+	if entry != nil {
+		if len(content) > 0 && !bytes.HasSuffix(content, []byte("\n")) {
+			content = append(content, '\n')
+		}
+
+		content = append(content, []byte(fmt.Sprintf("\n%s\n%s%s\n", begin, entry, end))...)
+	}
+
+	if err := s.io.WriteFile(configPath, content, 0600); err != nil {
+		return errors.Wrapf(err, "failed to write %s", configPath)
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package sshutil
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	mock_ioutil "github.com/knlambert/docker-remote.git/pkg/mock/std/ioutil"
+	mock_os "github.com/knlambert/docker-remote.git/pkg/mock/std/os"
+	mock_user "github.com/knlambert/docker-remote.git/pkg/mock/std/user"
+	"github.com/stretchr/testify/assert"
+)
+
+func stubbedSSHUtils(ctrl *gomock.Controller) (
+	*sshUtilsImpl,
+	*mock_ioutil.MockIOUtil,
+	*mock_os.MockOS,
+) {
+	ioMock := mock_ioutil.NewMockIOUtil(ctrl)
+	osMock := mock_os.NewMockOS(ctrl)
+	userMock := mock_user.NewMockUser(ctrl)
+
+	userMock.EXPECT().Current().Return(&user.User{HomeDir: "/home/barney"}, nil)
+
+	return &sshUtilsImpl{
+		io:   ioMock,
+		os:   osMock,
+		user: userMock,
+	}, ioMock, osMock
+}
+
+func TestSSHConfigSetReplacesManagedEntry(t *testing.T) {
+	// Tear up.
+	ctrl := gomock.NewController(t)
+	s, ioMock, osMock := stubbedSSHUtils(ctrl)
+
+	expectedConfigPath := filepath.Join("/home/barney", ".ssh", "config")
+	existing := "Host work\n  User barney\n" +
+		"\n# BEGIN docker-remote docker-remote-ec2\nHost docker-remote-ec2\n  HostName 10.0.0.1\n# END docker-remote docker-remote-ec2\n"
+	expected := "Host work\n  User barney\n" +
+		"\n# BEGIN docker-remote docker-remote-ec2\nHost docker-remote-ec2\n  HostName 10.0.0.9\n  User ec2-user\n" +
+		"  ProxyJump barney@bastion:2222\n# END docker-remote docker-remote-ec2\n"
+
+	osMock.EXPECT().PathExists(expectedConfigPath).Return(true, nil)
+	ioMock.EXPECT().ReadFile(expectedConfigPath).Return([]byte(existing), nil)
+	ioMock.EXPECT().WriteFile(expectedConfigPath, []byte(expected), os.FileMode(0600)).Return(nil)
+
+	//Assertions
+	err := s.SSHConfigSet("docker-remote-ec2", &Target{
+		Endpoint: Endpoint{User: "ec2-user", Host: "10.0.0.9"},
+		Jumps:    []Endpoint{{User: "barney", Host: "bastion", Port: 2222}},
+	})
+
+	assert.Nil(t, err)
+
+	ctrl.Finish()
+}
+
+func TestSSHConfigRemoveLeavesConfigWithoutEntry(t *testing.T) {
+	// Tear up.
+	ctrl := gomock.NewController(t)
+	s, ioMock, osMock := stubbedSSHUtils(ctrl)
+
+	expectedConfigPath := filepath.Join("/home/barney", ".ssh", "config")
+
+	osMock.EXPECT().PathExists(expectedConfigPath).Return(true, nil)
+	ioMock.EXPECT().ReadFile(expectedConfigPath).Return([]byte("Host work\n  User barney\n"), nil)
+
+	//Assertions
+	assert.Nil(t, s.SSHConfigRemove("docker-remote-ec2"))
+
+	ctrl.Finish()
+}
+
+func TestSSHConfigRemoveDoesNotCreateConfig(t *testing.T) {
+	// Tear up.
+	ctrl := gomock.NewController(t)
+	s, _, osMock := stubbedSSHUtils(ctrl)
+
+	osMock.EXPECT().PathExists(filepath.Join("/home/barney", ".ssh", "config")).Return(false, nil)
+
+	//Assertions
+	assert.Nil(t, s.SSHConfigRemove("docker-remote-ec2"))
+
+	ctrl.Finish()
+}
+
+func TestSSHConfigRemoveDropsManagedEntry(t *testing.T) {
+	// Tear up.
+	ctrl := gomock.NewController(t)
+	s, ioMock, osMock := stubbedSSHUtils(ctrl)
+
+	expectedConfigPath := filepath.Join("/home/barney", ".ssh", "config")
+	existing := "Host work\n  User barney\n" +
+		"\n# BEGIN docker-remote docker-remote-ec2\nHost docker-remote-ec2\n  HostName 10.0.0.1\n# END docker-remote docker-remote-ec2\n"
+
+	osMock.EXPECT().PathExists(expectedConfigPath).Return(true, nil)
+	ioMock.EXPECT().ReadFile(expectedConfigPath).Return([]byte(existing), nil)
+	ioMock.EXPECT().WriteFile(expectedConfigPath, []byte("Host work\n  User barney\n"), os.FileMode(0600)).Return(nil)
+
+	//Assertions
+	assert.Nil(t, s.SSHConfigRemove("docker-remote-ec2"))
+
+	ctrl.Finish()
+}
@@ -0,0 +1,84 @@
+package sshutil
+
+import (
+	"bytes"
+	"net"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+//Records the host key of a target in ~/.ssh/known_hosts, so that the ssh command line
+//(used by docker contexts) trusts it. Nothing is written when the key is already known.
+func (s *sshUtilsImpl) SSHKnownHostsAdd(target *Target) error {
+	a, err := s.SSHAgent()
+
+	if err != nil {
+		return err
+	}
+
+	var hostKey ssh.PublicKey
+
+	client, err := dialTarget(target, func(user string) *ssh.ClientConfig {
+		return &ssh.ClientConfig{
+			User: user,
+			Auth: []ssh.AuthMethod{
+				ssh.PublicKeysCallback(a.Signers),
+			},
+			//The hops are dialed in order, the last key seen is the target's.
+			HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+				hostKey = key
+				return nil
+			},
+		}
+	})
+
+	if err != nil {
+		return err
+	}
+
+	client.Close()
+
+	currentUser, err := s.user.Current()
+
+	if err != nil {
+		return errors.Wrap(err, "failed to determine current user")
+	}
+
+	sshFolderPath := filepath.Join(currentUser.HomeDir, ".ssh")
+	knownHostsPath := filepath.Join(sshFolderPath, "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(target.Address())}, hostKey)
+
+	var content []byte
+
+	if exists, err := s.os.PathExists(knownHostsPath); err != nil {
+		return errors.Wrapf(err, "failed to check %s path existence", knownHostsPath)
+	} else if exists {
+		if content, err = s.io.ReadFile(knownHostsPath); err != nil {
+			return errors.Wrapf(err, "failed to read %s", knownHostsPath)
+		}
+	} else if err := s.os.MkdirAll(sshFolderPath, 0700); err != nil {
+		return errors.Wrapf(err, "failed to create %s", sshFolderPath)
+	}
+
+	for _, existing := range strings.Split(string(content), "\n") {
+		if strings.TrimSpace(existing) == line {
+			return nil
+		}
+	}
+
+	if len(content) > 0 && !bytes.HasSuffix(content, []byte("\n")) {
+		content = append(content, '\n')
+	}
+
+	content = append(content, []byte(line+"\n")...)
+
+	if err := s.io.WriteFile(knownHostsPath, content, 0600); err != nil {
+		return errors.Wrapf(err, "failed to write %s", knownHostsPath)
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package sshutil
+
+import (
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	goruntime "runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+//Replaces the %h, %p and %r tokens of a ProxyCommand, as ssh does.
+func expandProxyCommand(command string, endpoint Endpoint) string {
+	port := endpoint.Port
+
+	if port == 0 {
+		port = 22
+	}
+
+	return strings.NewReplacer(
+		"%%", "%",
+		"%h", endpoint.Host,
+		"%p", strconv.Itoa(port),
+		"%r", endpoint.User,
+	).Replace(command)
+}
+
+//Starts a ProxyCommand and returns a connection over its standard input and output.
+func dialProxyCommand(command string, endpoint Endpoint) (net.Conn, error) {
+	expanded := expandProxyCommand(command, endpoint)
+
+	var cmd *exec.Cmd
+
+	if goruntime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", expanded)
+	} else {
+		cmd = exec.Command("sh", "-c", expanded)
+	}
+
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create the proxy command stdin pipe")
+	}
+
+	stdout, err := cmd.StdoutPipe()
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create the proxy command stdout pipe")
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, errors.Wrapf(err, "failed to start the proxy command '%s'", expanded)
+	}
+
+	return &commandConn{cmd: cmd, stdin: stdin, stdout: stdout}, nil
+}
+
+//A net.Conn reading from and writing to a command.
+type commandConn struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+}
+
+func (c *commandConn) Read(b []byte) (int, error) {
+	return c.stdout.Read(b)
+}
+
+func (c *commandConn) Write(b []byte) (int, error) {
+	return c.stdin.Write(b)
+}
+
+//Closes the pipes and stops the command.
+func (c *commandConn) Close() error {
+	_ = c.stdin.Close()
+
+	if c.cmd.Process != nil {
+		_ = c.cmd.Process.Kill()
+	}
+
+	_ = c.cmd.Wait()
+	return nil
+}
+
+func (c *commandConn) LocalAddr() net.Addr {
+	return commandAddr{}
+}
+
+func (c *commandConn) RemoteAddr() net.Addr {
+	return commandAddr{}
+}
+
+func (c *commandConn) SetDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *commandConn) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *commandConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+type commandAddr struct{}
+
+func (commandAddr) Network() string {
+	return "proxy-command"
+}
+
+func (commandAddr) String() string {
+	return "proxy-command"
+}
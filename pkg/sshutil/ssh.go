@@ -1,93 +1,122 @@
 package sshutil
 
 import (
-	"crypto/x509"
+	"bufio"
+	"bytes"
 	"encoding/pem"
 	"fmt"
+	stdioutil "github.com/knlambert/docker-remote.git/pkg/std/ioutil"
+	stdos "github.com/knlambert/docker-remote.git/pkg/std/os"
 	"github.com/knlambert/docker-remote.git/pkg/std/runtime"
+	"github.com/knlambert/docker-remote.git/pkg/std/user"
 	"github.com/pkg/errors"
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/agent"
 	"golang.org/x/crypto/ssh/terminal"
 	"io"
 	"io/ioutil"
-	"log"
 	"net"
+	"net/http"
 	"os"
+	"time"
 )
 
 const (
-	dockerRemoteEC2KeyID = "docker-remote-ec2-key"
+	dockerSocketPath = "/var/run/docker.sock"
 )
 
+//Notifications sent while port-forwarding. The first one, with neither client nor error,
+//tells the local port is listening.
+type ForwardEvent struct {
+	//The address of the local client, empty when the event is not about a connection.
+	Client string
+	//Set when the forwarding of a connection failed.
+	Err error
+}
+
 type SSHUtils interface {
+	//Calls the docker daemon /_ping endpoint through the host's docker socket.
+	DockerPing(
+		target *Target,
+	) error
 	LocalPortForward(
 		localPort uint,
 		remoteAddr string,
 		remotePort uint,
-		host string,
-		username string,
+		target *Target,
+		onEvent func(event ForwardEvent),
 	) error
 	//Adds a private key to the SSH Agent.
 	SSHAgent() (agent.Agent, error)
-	//Adds a private key to the SSH Agent.
+	//Adds a private key to the SSH Agent, under a comment identifying it.
 	SSHAgentAddKey(
 		privateKeyPath string,
+		comment string,
 	) error
-	//Removes a private key to the SSH Agent.
-	SSHAgentRemoveKey() error
+	//Removes the key added under a comment from the SSH Agent.
+	SSHAgentRemoveKey(comment string) error
+	//Records the host key of a target in ~/.ssh/known_hosts.
+	SSHKnownHostsAdd(target *Target) error
+	//Adds or replaces the ~/.ssh/config entry reaching a target through an alias.
+	SSHConfigSet(alias string, target *Target) error
+	//Removes the ~/.ssh/config entry of an alias.
+	SSHConfigRemove(alias string) error
 	//Opens an SSH connection to a host.
 	SSHConnection(
-		host string,
-		username string,
+		target *Target,
 	) error
+	//Runs a command on a host and returns its output.
+	SSHRun(
+		target *Target,
+		command string,
+	) ([]byte, error)
+	//Runs a command on a host with the given standard input and returns its output.
+	SSHRunInput(
+		target *Target,
+		command string,
+		input []byte,
+	) ([]byte, error)
 }
 
 func CreateSSHUtils() SSHUtils {
 	return &sshUtilsImpl{
+		io:      stdioutil.CreateIOUtil(),
+		os:      stdos.CreateOS(),
 		runtime: runtime.CreateRuntime(),
+		user:    user.CreateUser(),
 	}
 }
 
 type sshUtilsImpl struct{
+	io      stdioutil.IOUtil
+	os      stdos.OS
 	runtime runtime.Runtime
+	user    user.User
 }
 
 func (s *sshUtilsImpl) LocalPortForward(
 	localPort uint,
 	remoteAddr string,
 	remotePort uint,
-	host string,
-	username string,
+	target *Target,
+	onEvent func(event ForwardEvent),
 ) error {
-	a, err := s.SSHAgent()
-
-	if err != nil {
-		return err
-	}
-
-	config := &ssh.ClientConfig{
-		User:            username,
-		Auth:            []ssh.AuthMethod{
-			ssh.PublicKeysCallback(a.Signers),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-	}
-
 	localListener, err := net.Listen("tcp", fmt.Sprintf("%s:%d", remoteAddr, localPort))
 
 	if err != nil {
 		return errors.Wrapf(err, "failed to listen on %s:%d", remoteAddr, localPort)
 	}
 
-	errChan := make(chan error)
+	events := make(chan ForwardEvent)
 
 	go func() {
-		for err := range errChan {
-			log.Println(err)
+		for event := range events {
+			onEvent(event)
 		}
 	}()
 
+	events <- ForwardEvent{}
+
 	for {
 		//For each local connection
 		localConn, err := localListener.Accept()
@@ -96,13 +125,14 @@ func (s *sshUtilsImpl) LocalPortForward(
 			return errors.Wrapf(err, "failed to accept connection on %s", remoteAddr)
 		}
 
+		events <- ForwardEvent{Client: localConn.RemoteAddr().String()}
+
 		go s.forward(
 			localConn,
 			remoteAddr,
 			remotePort,
-			config,
-			host,
-			errChan,
+			target,
+			events,
 		)
 	}
 }
@@ -126,9 +156,10 @@ func (s *sshUtilsImpl) SSHAgent() (agent.Agent, error) {
 	return agent.NewClient(conn), nil
 }
 
-//Adds a private key to the SSH Agent.
+//Adds a private key to the SSH Agent, under a comment identifying it.
 func (s *sshUtilsImpl) SSHAgentAddKey(
 	keyPairPath string,
+	comment string,
 ) error {
 	a, err := s.SSHAgent()
 
@@ -142,17 +173,19 @@ func (s *sshUtilsImpl) SSHAgentAddKey(
 		return err
 	}
 
-	block, _ := pem.Decode(keyPairPEM)
+	if block, _ := pem.Decode(keyPairPEM); block == nil {
+		return errors.Errorf("%s is not a PEM encoded private key", keyPairPath)
+	}
 
-	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	key, err := ssh.ParseRawPrivateKey(keyPairPEM)
 
 	if err != nil {
-		return err
+		return errors.Wrapf(err, "failed to parse %s", keyPairPath)
 	}
 
-	if err:= a.Add(agent.AddedKey{
-		Comment: dockerRemoteEC2KeyID,
-		PrivateKey:           key,
+	if err := a.Add(agent.AddedKey{
+		Comment:    comment,
+		PrivateKey: key,
 	}); err != nil {
 		return errors.Wrap(err, "failed to add the key to the agent")
 	}
@@ -160,8 +193,8 @@ func (s *sshUtilsImpl) SSHAgentAddKey(
 	return nil
 }
 
-//Removes a private key to the SSH Agent.
-func (s *sshUtilsImpl) SSHAgentRemoveKey() error {
+//Removes the key added under a comment from the SSH Agent.
+func (s *sshUtilsImpl) SSHAgentRemoveKey(comment string) error {
 	a, err := s.SSHAgent()
 
 	if err != nil {
@@ -176,7 +209,7 @@ func (s *sshUtilsImpl) SSHAgentRemoveKey() error {
 
 	for _, key := range keys {
 
-		if key.Comment == dockerRemoteEC2KeyID {
+		if key.Comment == comment {
 			publicKey, err := ssh.ParsePublicKey(key.Blob)
 
 			if err != nil {
@@ -191,28 +224,15 @@ func (s *sshUtilsImpl) SSHAgentRemoveKey() error {
 }
 //Opens an SSH connection to a host.
 func (s *sshUtilsImpl) SSHConnection(
-	host string,
-	username string,
+	target *Target,
 ) error {
-	a, err := s.SSHAgent()
+	conn, err := s.dial(target)
 
 	if err != nil {
 		return err
 	}
 
-	config := &ssh.ClientConfig{
-		User:            username,
-		Auth:            []ssh.AuthMethod{
-			ssh.PublicKeysCallback(a.Signers),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-	}
-
-	conn, err := ssh.Dial("tcp", fmt.Sprintf("%s:22", host), config)
-
-	if err != nil {
-		return errors.Wrap(err, "failed to dial with the host")
-	}
+	defer conn.Close()
 
 	session, err := conn.NewSession()
 
@@ -289,18 +309,125 @@ func (s *sshUtilsImpl) SSHConnection(
 	return nil
 }
 
+//Runs a command on a host and returns its output.
+func (s *sshUtilsImpl) SSHRun(
+	target *Target,
+	command string,
+) ([]byte, error) {
+	return s.SSHRunInput(target, command, nil)
+}
+
+//Runs a command on a host with the given standard input and returns its output.
+func (s *sshUtilsImpl) SSHRunInput(
+	target *Target,
+	command string,
+	input []byte,
+) ([]byte, error) {
+	conn, err := s.dial(target)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	session, err := conn.NewSession()
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create the SSH session")
+	}
+
+	defer session.Close()
+
+	if input != nil {
+		session.Stdin = bytes.NewReader(input)
+	}
+
+	output, err := session.CombinedOutput(command)
+
+	if err != nil {
+		return output, errors.Wrapf(err, "failed to run '%s'", command)
+	}
+
+	return output, nil
+}
+
+//Calls the docker daemon /_ping endpoint through the host's docker socket.
+func (s *sshUtilsImpl) DockerPing(
+	target *Target,
+) error {
+	conn, err := s.dial(target)
+
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	socket, err := conn.Dial("unix", dockerSocketPath)
+
+	if err != nil {
+		return errors.Wrapf(err, "failed to open %s", dockerSocketPath)
+	}
+
+	defer socket.Close()
+
+	if _, err := socket.Write([]byte("GET /_ping HTTP/1.0\r\nHost: docker\r\n\r\n")); err != nil {
+		return errors.Wrap(err, "failed to send the ping request")
+	}
+
+	res, err := http.ReadResponse(bufio.NewReader(socket), nil)
+
+	if err != nil {
+		return errors.Wrap(err, "failed to read the ping response")
+	}
+
+	defer res.Body.Close()
+
+	body, _ := ioutil.ReadAll(res.Body)
+
+	if res.StatusCode != http.StatusOK {
+		return errors.Errorf("docker daemon answered %d: %s", res.StatusCode, bytes.TrimSpace(body))
+	}
+
+	return nil
+}
+
+//Opens an SSH client authenticated with the agent keys, through the target jump hosts.
+func (s *sshUtilsImpl) dial(
+	target *Target,
+) (*ssh.Client, error) {
+	a, err := s.SSHAgent()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return dialTarget(target, func(user string) *ssh.ClientConfig {
+		return &ssh.ClientConfig{
+			User: user,
+			Auth: []ssh.AuthMethod{
+				ssh.PublicKeysCallback(a.Signers),
+			},
+			HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+			Timeout:         10 * time.Second,
+		}
+	})
+}
+
 func (s *sshUtilsImpl) forward(
 	localConn net.Conn,
 	remoteAddr string,
 	remotePort uint,
-	config *ssh.ClientConfig,
-	host string,
-	errChan chan error,
+	target *Target,
+	events chan ForwardEvent,
 ) {
-	sshClientConn, err := ssh.Dial("tcp", fmt.Sprintf("%s:22", host), config)
+	client := localConn.RemoteAddr().String()
+
+	sshClientConn, err := s.dial(target)
 
 	if err != nil {
-		errChan <- errors.Wrapf(err, "failed to open ssh connection with %s", host)
+		events <- ForwardEvent{Client: client, Err: errors.Wrapf(err, "failed to open ssh connection with %s", target.Host)}
 		return
 	}
 
@@ -309,19 +436,19 @@ func (s *sshUtilsImpl) forward(
 	remoteConn, err := sshClientConn.Dial("tcp", remoteAddr)
 
 	if err != nil {
-		errChan <- errors.Wrapf(err, "failed to open connection with %s", remoteAddr)
+		events <- ForwardEvent{Client: client, Err: errors.Wrapf(err, "failed to open connection with %s", remoteAddr)}
 		return
 	}
 
 	go func() {
 		if _, err := io.Copy(remoteConn, localConn); err != nil {
-			errChan <- errors.Wrap(err, "failed to forward connection (remote -> local)")
+			events <- ForwardEvent{Client: client, Err: errors.Wrap(err, "failed to forward connection (remote -> local)")}
 		}
 	}()
 
 	go func() {
 		if _, err := io.Copy(localConn, remoteConn); err != nil {
-			errChan <- errors.Wrap(err, "failed to forward connection (local -> remote)")
+			events <- ForwardEvent{Client: client, Err: errors.Wrap(err, "failed to forward connection (local -> remote)")}
 		}
 	}()
 
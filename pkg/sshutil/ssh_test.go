@@ -0,0 +1,115 @@
+package sshutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	mock_runtime "github.com/knlambert/docker-remote.git/pkg/mock/std/runtime"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+//Serves an in-memory agent on SSH_AUTH_SOCK until the returned function is called.
+func servedAgent(t *testing.T, dir string) (agent.Agent, func()) {
+	keyring := agent.NewKeyring()
+	socket := filepath.Join(dir, "agent.sock")
+	listener, err := net.Listen("unix", socket)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+
+			if err != nil {
+				return
+			}
+
+			go agent.ServeAgent(keyring, conn)
+		}
+	}()
+
+	previous := os.Getenv("SSH_AUTH_SOCK")
+	os.Setenv("SSH_AUTH_SOCK", socket)
+
+	return keyring, func() {
+		os.Setenv("SSH_AUTH_SOCK", previous)
+		listener.Close()
+	}
+}
+
+func writePEM(t *testing.T, path string, blockType string, bytes []byte) {
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: bytes}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSSHAgentKeysAreRemovedByComment(t *testing.T) {
+	// Tear up.
+	ctrl := gomock.NewController(t)
+	dir, _ := ioutil.TempDir("", "sshutil")
+	defer os.RemoveAll(dir)
+
+	keyring, stop := servedAgent(t, dir)
+	defer stop()
+
+	runtimeMock := mock_runtime.NewMockRuntime(ctrl)
+	runtimeMock.EXPECT().CurrentOS().Return("linux").AnyTimes()
+	s := &sshUtilsImpl{runtime: runtimeMock}
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	pkcs8, _ := x509.MarshalPKCS8PrivateKey(rsaKey)
+	writePEM(t, filepath.Join(dir, "pkcs8"), "PRIVATE KEY", pkcs8)
+
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	sec1, _ := x509.MarshalECPrivateKey(ecKey)
+	writePEM(t, filepath.Join(dir, "ec"), "EC PRIVATE KEY", sec1)
+
+	//Assertions
+	assert.Nil(t, s.SSHAgentAddKey(filepath.Join(dir, "pkcs8"), "docker-remote-ec2-key"))
+	assert.Nil(t, s.SSHAgentAddKey(filepath.Join(dir, "ec"), "docker-remote-gce-key"))
+
+	assert.Nil(t, s.SSHAgentRemoveKey("docker-remote-gce-key"))
+
+	keys, err := keyring.List()
+
+	assert.Nil(t, err)
+	assert.Len(t, keys, 1)
+	assert.Equal(t, "docker-remote-ec2-key", keys[0].Comment, "the keys of other drivers should be kept")
+
+	ctrl.Finish()
+}
+
+func TestSSHAgentAddKeyRejectsNonPEMFiles(t *testing.T) {
+	// Tear up.
+	ctrl := gomock.NewController(t)
+	dir, _ := ioutil.TempDir("", "sshutil")
+	defer os.RemoveAll(dir)
+
+	_, stop := servedAgent(t, dir)
+	defer stop()
+
+	runtimeMock := mock_runtime.NewMockRuntime(ctrl)
+	runtimeMock.EXPECT().CurrentOS().Return("linux").AnyTimes()
+	s := &sshUtilsImpl{runtime: runtimeMock}
+
+	path := filepath.Join(dir, "id_rsa.pub")
+	ioutil.WriteFile(path, []byte("ssh-rsa AAAA barney@laptop\n"), 0600)
+
+	//Assertions
+	assert.EqualError(t, s.SSHAgentAddKey(path, "docker-remote-ec2-key"), path+" is not a PEM encoded private key")
+
+	ctrl.Finish()
+}
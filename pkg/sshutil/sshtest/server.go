@@ -0,0 +1,263 @@
+//Package sshtest provides a local SSH server standing for the docker hosts in tests.
+package sshtest
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+)
+
+//The docker socket, answered with a healthy /_ping.
+const dockerSocketPath = "/var/run/docker.sock"
+
+//An SSH server accepting an authorized key for any user. Commands succeed silently unless
+//Exec says otherwise, shells exit right away, the docker socket answers pings and TCP
+//forwarding (jump hosts) goes through Dial.
+type Server struct {
+	//The address the server listens on, host:port.
+	Addr string
+	//Runs the command of an exec request, returns its output and exit status.
+	Exec func(command string) ([]byte, uint32)
+	//Opens the connections forwarded through the server, net.Dial when nil.
+	Dial func(addr string) (net.Conn, error)
+
+	listener net.Listener
+	config   *ssh.ServerConfig
+	mutex    sync.Mutex
+	commands []string
+}
+
+//Starts a server on a random local port, Close stops it.
+func CreateServer(authorizedKey ssh.PublicKey) (*Server, error) {
+	hostKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate the host key")
+	}
+
+	signer, err := ssh.NewSignerFromKey(hostKey)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load the host key")
+	}
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if !bytes.Equal(key.Marshal(), authorizedKey.Marshal()) {
+				return nil, errors.Errorf("unknown key for %s", conn.User())
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to listen")
+	}
+
+	s := &Server{
+		Addr:     listener.Addr().String(),
+		listener: listener,
+		config:   config,
+	}
+
+	go s.accept()
+
+	return s, nil
+}
+
+func (s *Server) Close() error {
+	return s.listener.Close()
+}
+
+//Returns the commands run so far, "shell" for interactive sessions.
+func (s *Server) Commands() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]string{}, s.commands...)
+}
+
+func (s *Server) accept() {
+	for {
+		conn, err := s.listener.Accept()
+
+		if err != nil {
+			return
+		}
+
+		go s.serve(conn)
+	}
+}
+
+func (s *Server) serve(conn net.Conn) {
+	serverConn, channels, requests, err := ssh.NewServerConn(conn, s.config)
+
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	defer serverConn.Close()
+
+	go ssh.DiscardRequests(requests)
+
+	for channel := range channels {
+		switch channel.ChannelType() {
+		case "session":
+			go s.session(channel)
+		case "direct-tcpip":
+			go s.forward(channel)
+		case "direct-streamlocal@openssh.com":
+			go s.streamLocal(channel)
+		default:
+			_ = channel.Reject(ssh.UnknownChannelType, "unsupported channel type")
+		}
+	}
+}
+
+func (s *Server) session(newChannel ssh.NewChannel) {
+	channel, requests, err := newChannel.Accept()
+
+	if err != nil {
+		return
+	}
+
+	defer channel.Close()
+
+	for req := range requests {
+		var command string
+
+		switch req.Type {
+		case "exec":
+			var payload struct{ Command string }
+
+			if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+				_ = req.Reply(false, nil)
+				continue
+			}
+
+			command = payload.Command
+		case "shell":
+			command = "shell"
+		default:
+			//pty-req, env...
+			_ = req.Reply(true, nil)
+			continue
+		}
+
+		_ = req.Reply(true, nil)
+
+		s.mutex.Lock()
+		s.commands = append(s.commands, command)
+		s.mutex.Unlock()
+
+		var status uint32
+
+		if s.Exec != nil && command != "shell" {
+			var output []byte
+			output, status = s.Exec(command)
+			_, _ = channel.Write(output)
+		}
+
+		_, _ = channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+
+		return
+	}
+}
+
+func (s *Server) forward(newChannel ssh.NewChannel) {
+	var payload struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+
+	if err := ssh.Unmarshal(newChannel.ExtraData(), &payload); err != nil {
+		_ = newChannel.Reject(ssh.ConnectionFailed, "invalid payload")
+		return
+	}
+
+	dial := s.Dial
+
+	if dial == nil {
+		dial = func(addr string) (net.Conn, error) {
+			return net.Dial("tcp", addr)
+		}
+	}
+
+	conn, err := dial(net.JoinHostPort(payload.Host, strconv.Itoa(int(payload.Port))))
+
+	if err != nil {
+		_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+
+	channel, requests, err := newChannel.Accept()
+
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	go ssh.DiscardRequests(requests)
+
+	go func() {
+		_, _ = io.Copy(conn, channel)
+		conn.Close()
+	}()
+
+	_, _ = io.Copy(channel, conn)
+	channel.Close()
+}
+
+//Answers the requests sent to the docker socket with a successful ping.
+func (s *Server) streamLocal(newChannel ssh.NewChannel) {
+	var payload struct {
+		SocketPath string
+		Reserved0  string
+		Reserved1  uint32
+	}
+
+	if err := ssh.Unmarshal(newChannel.ExtraData(), &payload); err != nil || payload.SocketPath != dockerSocketPath {
+		_ = newChannel.Reject(ssh.ConnectionFailed, "no such socket")
+		return
+	}
+
+	channel, requests, err := newChannel.Accept()
+
+	if err != nil {
+		return
+	}
+
+	defer channel.Close()
+
+	go ssh.DiscardRequests(requests)
+
+	//The request headers end with an empty line.
+	buffer := make([]byte, 0, 512)
+	chunk := make([]byte, 512)
+
+	for !bytes.Contains(buffer, []byte("\r\n\r\n")) {
+		n, err := channel.Read(chunk)
+
+		if err != nil {
+			return
+		}
+
+		buffer = append(buffer, chunk[:n]...)
+	}
+
+	_, _ = channel.Write([]byte("HTTP/1.0 200 OK\r\nContent-Type: text/plain\r\nContent-Length: 2\r\n\r\nOK"))
+}
@@ -0,0 +1,174 @@
+package sshutil
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+)
+
+//An SSH server, "user@host[:port]".
+type Endpoint struct {
+	User string
+	Host string
+	//22 when 0.
+	Port int
+}
+
+//Where and how to reach a host over SSH.
+type Target struct {
+	Endpoint
+	//Hosts to hop through before reaching the target, in order (ProxyJump).
+	Jumps []Endpoint
+	//A command whose standard input and output carry the connection to the first
+	//hop instead of TCP (ProxyCommand, %h, %p and %r are replaced).
+	ProxyCommand string
+}
+
+//Parses a "user@host[:port]" endpoint.
+func ParseEndpoint(value string) (*Endpoint, error) {
+	at := strings.LastIndex(value, "@")
+
+	if at <= 0 || at == len(value)-1 {
+		return nil, errors.Errorf("invalid SSH endpoint '%s', expected user@host[:port]", value)
+	}
+
+	endpoint := Endpoint{
+		User: value[:at],
+		Host: value[at+1:],
+	}
+
+	if host, port, err := net.SplitHostPort(endpoint.Host); err == nil {
+		endpoint.Host = host
+		endpoint.Port, err = strconv.Atoi(port)
+
+		if err != nil {
+			return nil, errors.Errorf("invalid port in SSH endpoint '%s'", value)
+		}
+	}
+
+	return &endpoint, nil
+}
+
+//Parses a comma separated chain of jump hosts, as ssh -J does.
+func ParseJumps(values []string) ([]Endpoint, error) {
+	var jumps []Endpoint
+
+	for _, value := range values {
+		for _, hop := range strings.Split(value, ",") {
+			if hop = strings.TrimSpace(hop); hop == "" {
+				continue
+			}
+
+			endpoint, err := ParseEndpoint(hop)
+
+			if err != nil {
+				return nil, err
+			}
+
+			jumps = append(jumps, *endpoint)
+		}
+	}
+
+	return jumps, nil
+}
+
+//The "host:port" address of the endpoint.
+func (e *Endpoint) Address() string {
+	port := e.Port
+
+	if port == 0 {
+		port = 22
+	}
+
+	return net.JoinHostPort(e.Host, strconv.Itoa(port))
+}
+
+//Formats the endpoint as "user@host[:port]".
+func (e Endpoint) String() string {
+	if e.Port == 0 || e.Port == 22 {
+		return fmt.Sprintf("%s@%s", e.User, e.Host)
+	}
+	return fmt.Sprintf("%s@%s", e.User, e.Address())
+}
+
+//Formats the jump hosts as a ProxyJump value.
+func (t *Target) ProxyJump() string {
+	var hops []string
+
+	for _, jump := range t.Jumps {
+		hops = append(hops, jump.String())
+	}
+
+	return strings.Join(hops, ",")
+}
+
+//Opens an SSH client to the target, hopping through its jump hosts.
+func dialTarget(target *Target, config func(user string) *ssh.ClientConfig) (*ssh.Client, error) {
+	hops := append(append([]Endpoint{}, target.Jumps...), target.Endpoint)
+
+	client, err := dialFirstHop(target.ProxyCommand, hops[0], config(hops[0].User))
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, hop := range hops[1:] {
+		conn, err := client.Dial("tcp", hop.Address())
+
+		if err != nil {
+			client.Close()
+			return nil, errors.Wrapf(err, "failed to reach %s through the jump host", hop.Address())
+		}
+
+		clientConn, channels, requests, err := ssh.NewClientConn(conn, hop.Address(), config(hop.User))
+
+		if err != nil {
+			conn.Close()
+			client.Close()
+			return nil, errors.Wrapf(err, "failed to dial with %s", hop.Address())
+		}
+
+		jump := client
+		client = ssh.NewClient(clientConn, channels, requests)
+
+		//Closes the jump connection along with the one going through it.
+		go func(client *ssh.Client) {
+			_ = client.Wait()
+			jump.Close()
+		}(client)
+	}
+
+	return client, nil
+}
+
+//Opens an SSH client to the first hop, over TCP or through the proxy command.
+func dialFirstHop(proxyCommand string, hop Endpoint, config *ssh.ClientConfig) (*ssh.Client, error) {
+	if proxyCommand == "" {
+		client, err := ssh.Dial("tcp", hop.Address(), config)
+
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to dial with %s", hop.Address())
+		}
+
+		return client, nil
+	}
+
+	conn, err := dialProxyCommand(proxyCommand, hop)
+
+	if err != nil {
+		return nil, err
+	}
+
+	clientConn, channels, requests, err := ssh.NewClientConn(conn, hop.Address(), config)
+
+	if err != nil {
+		conn.Close()
+		return nil, errors.Wrapf(err, "failed to dial with %s through the proxy command", hop.Address())
+	}
+
+	return ssh.NewClient(clientConn, channels, requests), nil
+}
@@ -0,0 +1,36 @@
+package sshutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseJumps(t *testing.T) {
+	jumps, err := ParseJumps([]string{"alice@bastion.corp:2222,bob@10.0.0.4", "carol@[fd00::1]:22"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []Endpoint{
+		{User: "alice", Host: "bastion.corp", Port: 2222},
+		{User: "bob", Host: "10.0.0.4"},
+		{User: "carol", Host: "fd00::1", Port: 22},
+	}, jumps)
+
+	target := Target{Jumps: jumps}
+	assert.Equal(t, "alice@bastion.corp:2222,bob@10.0.0.4,carol@fd00::1", target.ProxyJump())
+}
+
+func TestParseEndpointRequiresUser(t *testing.T) {
+	_, err := ParseEndpoint("bastion.corp")
+
+	assert.EqualError(t, err, "invalid SSH endpoint 'bastion.corp', expected user@host[:port]")
+}
+
+func TestExpandProxyCommand(t *testing.T) {
+	command := expandProxyCommand(
+		"/usr/local/bin/docker-remote ec2 ssm-proxy %h %p # %r 100%%",
+		Endpoint{User: "ec2-user", Host: "i-0123"},
+	)
+
+	assert.Equal(t, "/usr/local/bin/docker-remote ec2 ssm-proxy i-0123 22 # ec2-user 100%", command)
+}
@@ -0,0 +1,122 @@
+package state
+
+import (
+	"encoding/json"
+	"path/filepath"
+
+	"github.com/knlambert/docker-remote.git/pkg/std/ioutil"
+	"github.com/knlambert/docker-remote.git/pkg/std/os"
+	"github.com/knlambert/docker-remote.git/pkg/std/user"
+	"github.com/pkg/errors"
+)
+
+//Values recorded per driver, so that later commands find them without re-specifying them.
+type Store interface {
+	//Returns a value recorded for a driver, empty if there is none.
+	Get(driver string, key string) (string, error)
+	//Records a value for a driver.
+	Set(driver string, key string, value string) error
+}
+
+func CreateStore() Store {
+	return &storeImpl{
+		io:   ioutil.CreateIOUtil(),
+		os:   os.CreateOS(),
+		user: user.CreateUser(),
+	}
+}
+
+type storeImpl struct {
+	io   ioutil.IOUtil
+	os   os.OS
+	user user.User
+}
+
+//The content of ~/.docker-remote/state.json, values by key by driver.
+type data map[string]map[string]string
+
+//Returns a value recorded for a driver, empty if there is none.
+func (s *storeImpl) Get(driver string, key string) (string, error) {
+	content, err := s.load()
+
+	if err != nil {
+		return "", err
+	}
+
+	return content[driver][key], nil
+}
+
+//Records a value for a driver.
+func (s *storeImpl) Set(driver string, key string, value string) error {
+	content, err := s.load()
+
+	if err != nil {
+		return err
+	}
+
+	if content[driver] == nil {
+		content[driver] = map[string]string{}
+	}
+
+	content[driver][key] = value
+
+	statePath, err := s.path()
+
+	if err != nil {
+		return err
+	}
+
+	if err := s.os.MkdirAll(filepath.Dir(statePath), 0700); err != nil {
+		return errors.Wrapf(err, "failed to create %s", filepath.Dir(statePath))
+	}
+
+	encoded, err := json.MarshalIndent(content, "", "  ")
+
+	if err != nil {
+		return errors.Wrap(err, "failed to encode the state")
+	}
+
+	if err := s.io.WriteFile(statePath, encoded, 0600); err != nil {
+		return errors.Wrapf(err, "failed to write %s", statePath)
+	}
+
+	return nil
+}
+
+func (s *storeImpl) load() (data, error) {
+	statePath, err := s.path()
+
+	if err != nil {
+		return nil, err
+	}
+
+	content := data{}
+
+	if exists, err := s.os.PathExists(statePath); err != nil {
+		return nil, errors.Wrapf(err, "failed to check %s path existence", statePath)
+	} else if !exists {
+		return content, nil
+	}
+
+	encoded, err := s.io.ReadFile(statePath)
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", statePath)
+	}
+
+	if err := json.Unmarshal(encoded, &content); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", statePath)
+	}
+
+	return content, nil
+}
+
+func (s *storeImpl) path() (string, error) {
+	currentUser, err := s.user.Current()
+
+	if err != nil {
+		return "", errors.Wrap(err, "failed to determine current user")
+	}
+
+	return filepath.Join(currentUser.HomeDir, ".docker-remote", "state.json"), nil
+}
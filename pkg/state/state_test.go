@@ -0,0 +1,40 @@
+package state
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	mock_ioutil "github.com/knlambert/docker-remote.git/pkg/mock/std/ioutil"
+	mock_os "github.com/knlambert/docker-remote.git/pkg/mock/std/os"
+	mock_user "github.com/knlambert/docker-remote.git/pkg/mock/std/user"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetKeepsOtherValues(t *testing.T) {
+	// Tear up.
+	ctrl := gomock.NewController(t)
+	ioMock := mock_ioutil.NewMockIOUtil(ctrl)
+	osMock := mock_os.NewMockOS(ctrl)
+	userMock := mock_user.NewMockUser(ctrl)
+
+	s := storeImpl{io: ioMock, os: osMock, user: userMock}
+	expectedStatePath := filepath.Join("/home/barney", ".docker-remote", "state.json")
+
+	userMock.EXPECT().Current().Return(&user.User{HomeDir: "/home/barney"}, nil).Times(2)
+	osMock.EXPECT().PathExists(expectedStatePath).Return(true, nil)
+	ioMock.EXPECT().ReadFile(expectedStatePath).Return([]byte(`{"gce": {"zone": "europe-west1-b"}}`), nil)
+	osMock.EXPECT().MkdirAll(filepath.Join("/home/barney", ".docker-remote"), os.FileMode(0700)).Return(nil)
+	ioMock.EXPECT().WriteFile(
+		expectedStatePath,
+		[]byte("{\n  \"ec2\": {\n    \"region\": \"ca-central-1\"\n  },\n  \"gce\": {\n    \"zone\": \"europe-west1-b\"\n  }\n}"),
+		os.FileMode(0600),
+	).Return(nil)
+
+	//Assertions
+	assert.Nil(t, s.Set("ec2", "region", "ca-central-1"))
+
+	ctrl.Finish()
+}
@@ -6,6 +6,7 @@ import (
 )
 
 type IOUtil interface {
+	ReadFile(filename string) ([]byte, error)
 	WriteFile(filename string, data []byte, perm os.FileMode) error
 }
 
@@ -15,6 +16,10 @@ func CreateIOUtil() IOUtil {
 
 type ioUtilImpl struct {}
 
+func (i *ioUtilImpl) ReadFile(filename string) ([]byte, error) {
+	return ioutil.ReadFile(filename)
+}
+
 func (i * ioUtilImpl) WriteFile(filename string, data []byte, perm os.FileMode) error {
 	return ioutil.WriteFile(filename, data, perm)
 }
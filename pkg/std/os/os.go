@@ -6,6 +6,7 @@ import "os"
 type OS interface {
 	MkdirAll(path string, perm os.FileMode) error
 	PathExists(path string) (bool, error)
+	RemoveAll(path string) error
 }
 
 func CreateOS() OS {
@@ -29,3 +30,8 @@ func (o *osImpl) PathExists(path string) (bool, error) {
 	}
 	return true, nil
 }
+
+//Removes a path and its children.
+func (o *osImpl) RemoveAll(path string) error {
+	return os.RemoveAll(path)
+}
@@ -3,6 +3,7 @@ package runtime
 import "runtime"
 
 type Runtime interface {
+	CurrentArch() string
 	CurrentOS() string
 }
 
@@ -12,6 +13,10 @@ func CreateRuntime() Runtime {
 
 type runtimeImpl struct {}
 
+func (r *runtimeImpl) CurrentArch() string {
+	return runtime.GOARCH
+}
+
 func (r *runtimeImpl) CurrentOS() string {
 	return runtime.GOOS
 }